@@ -0,0 +1,77 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/asdine/storm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func NewDBCmd(config *cmdutil.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Maintain the server's event database.",
+		Long: `Maintain the server's event database.
+
+	Unless otherwise noted these commands open the database directly, so the
+	server must be stopped first.`,
+	}
+
+	cmd.PersistentFlags().String("database", "", "database file to operate on (default is the server's database)")
+
+	cmd.AddCommand(NewDBBackupCmd(config))
+	cmd.AddCommand(NewDBCheckCmd())
+	cmd.AddCommand(NewDBCompactCmd())
+	cmd.AddCommand(NewDBMigrateCmd())
+
+	return cmd
+}
+
+// databasePath returns the database file for db commands, defaulting to the
+// server's configured database.
+func databasePath(cmd *cobra.Command) string {
+	if database, _ := cmd.Flags().GetString("database"); database != "" {
+		return database
+	}
+	if database := viper.GetString("database"); database != "" {
+		return database
+	}
+	return cmdutil.GetDefaults().Database
+}
+
+// openDatabase opens an existing database for db commands.
+func openDatabase(database string) (*storm.DB, error) {
+	if _, err := os.Stat(database); err != nil {
+		return nil, err
+	}
+
+	keyring, err := loadKeyring()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := persistentqueue.OpenDB(database, keyring)
+	if err != nil {
+		return nil, fmt.Errorf("error opening database %v, is the server stopped? %v", database, err)
+	}
+	return db, nil
+}
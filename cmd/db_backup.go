@@ -0,0 +1,78 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/spf13/cobra"
+)
+
+func NewDBBackupCmd(config *cmdutil.Config) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Write a consistent backup of a running server's database.",
+		Long: `Write a consistent backup of a running server's database.
+
+	Unlike other db commands this is performed by the server itself while it
+	continues to run, so the output path must be writable by the server. If no
+	output is provided the backup is written alongside the database.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDBBackupCommand(config, output)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "file to write the backup to")
+
+	return cmd
+}
+
+func runDBBackupCommand(config *cmdutil.Config, output string) error {
+	if output != "" {
+		abs, err := filepath.Abs(output)
+		if err != nil {
+			return err
+		}
+		output = abs
+	}
+
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	resp, err := c.DBBackup(output)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println(string(respBody))
+	return nil
+}
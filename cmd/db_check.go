@@ -0,0 +1,67 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/spf13/cobra"
+)
+
+func NewDBCheckCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Verify the integrity of the database.",
+		Long: `Verify the integrity of the database.
+
+	Checks that every stored event can be decoded, that indexes are consistent
+	with events, and that queue status counters are accurate. The database is
+	not modified; status counter problems can be fixed with
+	"pdagent queue rebuild-status" once the server is running.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDBCheckCommand(databasePath(cmd))
+		},
+	}
+
+	return cmd
+}
+
+func runDBCheckCommand(database string) error {
+	db, err := openDatabase(database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	report, err := persistentqueue.Check(db)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Checked %v events in %v.\n", report.Events, database)
+
+	for _, problem := range report.Problems {
+		fmt.Printf("  [%v] %v\n", problem.Kind, problem.Message)
+	}
+
+	if len(report.Problems) > 0 {
+		return fmt.Errorf("found %v problems", len(report.Problems))
+	}
+
+	fmt.Println("No problems found.")
+	return nil
+}
@@ -0,0 +1,49 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/spf13/cobra"
+)
+
+func NewDBCompactCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compact",
+		Short: "Rewrite the database to reclaim unused space.",
+		Long: `Rewrite the database to reclaim unused space.
+
+	The database file never shrinks on its own, even as old events are removed.
+	Compacting rewrites it to only the space currently in use.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDBCompactCommand(databasePath(cmd))
+		},
+	}
+
+	return cmd
+}
+
+func runDBCompactCommand(database string) error {
+	before, after, err := persistentqueue.Compact(database)
+	if err != nil {
+		return fmt.Errorf("error compacting database %v, is the server stopped? %v", database, err)
+	}
+
+	fmt.Printf("Compacted %v from %v to %v bytes.\n", database, before, after)
+	return nil
+}
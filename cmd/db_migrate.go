@@ -0,0 +1,90 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/spf13/cobra"
+)
+
+func NewDBMigrateCmd() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrate the database to the latest schema version.",
+		Long: `Migrate the database to the latest schema version.
+
+	Migrations also run automatically when the server starts. In either case
+	a backup of the database is written alongside it before migrating.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDBMigrateCommand(databasePath(cmd), dryRun)
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "list pending migrations without applying them")
+
+	return cmd
+}
+
+func runDBMigrateCommand(database string, dryRun bool) error {
+	db, err := openDatabase(database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	version, err := persistentqueue.SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	pending, err := persistentqueue.PendingMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Database: %v\n", database)
+	fmt.Printf("Current schema version: %v\n", version)
+	fmt.Printf("Latest schema version: %v\n", persistentqueue.LatestSchemaVersion())
+
+	if len(pending) == 0 {
+		fmt.Println("Database is up to date.")
+		return nil
+	}
+
+	if dryRun {
+		fmt.Println("Pending migrations:")
+		for _, m := range pending {
+			fmt.Printf("  %v: %v\n", m.Version, m.Description)
+		}
+		return nil
+	}
+
+	applied, err := persistentqueue.Migrate(db, database, common.Logger.Named("Migrate"))
+	for _, m := range applied {
+		fmt.Printf("Applied %v: %v\n", m.Version, m.Description)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Database migrated.")
+	return nil
+}
@@ -16,6 +16,12 @@ limitations under the License.
 package cmd
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
 	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
 	"github.com/spf13/cobra"
@@ -23,6 +29,8 @@ import (
 
 func NewEnqueueCmd(config *cmdutil.Config) *cobra.Command {
 	var customDetails map[string]string
+	var batchFile string
+	var sendOptions func() []cmdutil.SendOption
 
 	var sendEvent = eventsapi.EventV2{
 		Payload: eventsapi.PayloadV2{},
@@ -31,9 +39,17 @@ func NewEnqueueCmd(config *cmdutil.Config) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "enqueue",
 		Short: "Queue up a trigger, acknowledge, or resolve v2 event to PagerDuty",
+		Long: `Queue up a trigger, acknowledge, or resolve v2 event to PagerDuty.
+
+With --batch-file, instead queues many v1 or v2 events at once from a file
+containing either a JSON array of events or one JSON event per line, or from
+standard input if the file is "-".`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if batchFile != "" {
+				return runEnqueueBatchCommand(config, batchFile)
+			}
 			sendEvent.Payload.CustomDetails = cmdutil.StringMapToInterfaceMap(customDetails)
-			return cmdutil.RunSendCommand(config, &sendEvent)
+			return cmdutil.RunSendCommand(config, &sendEvent, sendOptions()...)
 		},
 	}
 
@@ -47,6 +63,45 @@ func NewEnqueueCmd(config *cmdutil.Config) *cobra.Command {
 	cmd.Flags().StringVarP(&sendEvent.Payload.Group, "group", "g", "", "Logical grouping of components of a service")
 	cmd.Flags().StringVar(&sendEvent.Payload.Class, "class", "", "The class/type of the event")
 	cmd.Flags().StringToStringVarP(&customDetails, "field", "f", map[string]string{}, "Add given KEY=VALUE pair to the event details")
+	cmd.Flags().StringVar(&batchFile, "batch-file", "", "Queue the events in the given file (or - for standard input) instead")
+	sendOptions = cmdutil.AddWaitFlags(cmd)
 
 	return cmd
 }
+
+func runEnqueueBatchCommand(config *cmdutil.Config, file string) error {
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	resp, err := c.SendBatchFrom(r)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println(string(respBody))
+	if resp.StatusCode != http.StatusOK {
+		os.Exit(1)
+	}
+	return nil
+}
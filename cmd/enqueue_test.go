@@ -16,7 +16,9 @@ limitations under the License.
 package cmd
 
 import (
+	"io/ioutil"
 	"net/http"
+	"os"
 	"testing"
 	"time"
 
@@ -112,3 +114,85 @@ func TestEnqueue_validInput(t *testing.T) {
 
 	assert.Contains(t, out, `{"key":"xyz"}`)
 }
+
+func TestEnqueue_batchFile(t *testing.T) {
+	defer gock.Off()
+
+	defaultHTTPClient := &http.Client{
+		Timeout: 5 * time.Minute,
+	}
+
+	realConfig := cmdutil.NewConfig()
+	realConfig.HttpClient = func() (*http.Client, error) {
+		return defaultHTTPClient, nil
+	}
+
+	const batch = `{"routing_key":"abc","event_action":"trigger"}
+{"service_key":"abc","event_type":"trigger"}
+`
+
+	file, err := ioutil.TempFile("", "pdagent-batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(batch); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	cmd := NewEnqueueCmd(realConfig)
+	cmd.SetArgs([]string{"--batch-file", file.Name()})
+
+	gock.New(cmdutil.GetDefaults().Address).
+		Post("/send/batch").
+		BodyString(batch).
+		Reply(200).
+		BodyString(`{"results":[{"key":"xyz"},{"key":"uvw"}]}`)
+
+	gock.InterceptClient(defaultHTTPClient)
+
+	out, err := test.CaptureStdout(func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+
+	if err != nil {
+		t.Errorf("error running command `enqueue`: %v", err)
+	}
+
+	assert.Contains(t, out, `{"results":[{"key":"xyz"},{"key":"uvw"}]}`)
+}
+
+func TestEnqueue_wait(t *testing.T) {
+	defer gock.Off()
+
+	defaultHTTPClient := &http.Client{
+		Timeout: 5 * time.Minute,
+	}
+
+	realConfig := cmdutil.NewConfig()
+	realConfig.HttpClient = func() (*http.Client, error) {
+		return defaultHTTPClient, nil
+	}
+
+	cmd := NewEnqueueCmd(realConfig)
+	cmd.SetArgs([]string{"-k", "abc", "-t", "trigger", "--wait", "--timeout", "5s"})
+
+	gock.New(cmdutil.GetDefaults().Address).
+		Post("/send").
+		MatchParam("wait", "true").
+		MatchParam("timeout", "5s").
+		Reply(200).
+		BodyString(`{"key":"xyz","status":"error","response":{"status":"invalid event"}}`)
+
+	gock.InterceptClient(defaultHTTPClient)
+
+	out, err := test.CaptureStdout(func() error {
+		_, err := cmd.ExecuteC()
+		return err
+	})
+
+	assert.Equal(t, cmdutil.ErrEventNotSent, err)
+	assert.Contains(t, out, `{"key":"xyz","status":"error","response":{"status":"invalid event"}}`)
+}
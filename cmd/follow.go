@@ -0,0 +1,139 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/spf13/cobra"
+)
+
+func NewQueueFollowCmd(config *cmdutil.Config) *cobra.Command {
+	var routingKey string
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "follow",
+		Short: "Print events' progress through the queue as it happens.",
+		Long: `Prints a line each time a queued event is accepted, dispatched, retried,
+succeeded, failed, expired or skipped, until interrupted. Failed and
+succeeded events include a summary of PagerDuty's response.
+
+The server's stream is resumed whenever it ends, without missing transitions.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runFollowCommand(config, routingKey, asJSON)
+		},
+	}
+
+	cmd.Flags().StringVarP(&routingKey, "routing-key", "k", "", "Only follow events for this Events API Key")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print each transition as JSON")
+
+	return cmd
+}
+
+func runFollowCommand(config *cmdutil.Config, routingKey string, asJSON bool) error {
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var lastEventID string
+	for {
+		resp, err := c.QueueStream(routingKey, lastEventID)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if resp.StatusCode != 200 {
+			respBody, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			fmt.Println(string(respBody))
+			os.Exit(1)
+		}
+
+		// The server ends streams periodically, so they're resumed from the
+		// last transition received.
+		lastEventID, err = followStream(resp.Body, os.Stdout, lastEventID, asJSON)
+		resp.Body.Close()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+}
+
+// followStream prints the transitions in a stream of server-sent events
+// until it ends, returning the last event ID received.
+func followStream(r io.Reader, w io.Writer, lastEventID string, asJSON bool) (string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 {
+				if err := printTransition(w, strings.Join(data, "\n"), asJSON); err != nil {
+					return lastEventID, err
+				}
+			}
+			data = nil
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "id":
+			lastEventID = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	return lastEventID, scanner.Err()
+}
+
+func printTransition(w io.Writer, data string, asJSON bool) error {
+	if asJSON {
+		_, err := fmt.Fprintln(w, data)
+		return err
+	}
+
+	var t persistentqueue.Transition
+	if err := json.Unmarshal([]byte(data), &t); err != nil {
+		return fmt.Errorf("invalid transition %q: %v", data, err)
+	}
+
+	line := fmt.Sprintf("%v  %-10v  %v  %v", t.Time.Local().Format(time.RFC3339), t.Type, t.Key, t.RoutingKey)
+	if t.Response != "" {
+		line += "  " + t.Response
+	}
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
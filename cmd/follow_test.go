@@ -0,0 +1,50 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFollowStream(t *testing.T) {
+	stream := "retry: 1000\nid: 3\n\n" +
+		": keep-alive\n\n" +
+		"id: 4\nevent: accepted\ndata: {\"id\":4,\"type\":\"accepted\",\"key\":\"abc\",\"routing_key\":\"rk1\",\"status\":\"pending\",\"time\":\"2020-06-01T12:00:00Z\"}\n\n" +
+		"id: 5\nevent: failed\ndata: {\"id\":5,\"type\":\"failed\",\"key\":\"abc\",\"routing_key\":\"rk1\",\"status\":\"error\",\"response\":\"400 invalid event\",\"time\":\"2020-06-01T12:00:01Z\"}\n\n"
+
+	var out bytes.Buffer
+	lastEventID, err := followStream(strings.NewReader(stream), &out, "", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "5", lastEventID)
+
+	accepted := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC).Local().Format(time.RFC3339)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.Equal(t, accepted+"  accepted    abc  rk1", lines[0])
+		assert.True(t, strings.HasSuffix(lines[1], "  failed      abc  rk1  400 invalid event"))
+	}
+
+	out.Reset()
+	lastEventID, err = followStream(strings.NewReader(": keep-alive\n\n"), &out, "5", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "5", lastEventID)
+	assert.Empty(t, out.String())
+}
@@ -18,6 +18,7 @@ package cmd
 import (
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 
 	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
@@ -25,21 +26,39 @@ import (
 )
 
 func NewHealthCmd(config *cmdutil.Config) *cobra.Command {
+	var live bool
+
 	cmd := &cobra.Command{
 		Use:   "health",
 		Short: "Check the health of the server.",
+		Long: `Prints the results of the server's health checks, exiting with an error
+if any fail.
+
+By default checks whether the server is ready to send events, or with --live
+only whether it's alive.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runHealthCommand(config)
+			return runHealthCommand(config, live)
 		},
 	}
 
+	cmd.Flags().BoolVar(&live, "live", false, "Only check whether the server is alive")
+
 	return cmd
 }
 
-func runHealthCommand(config *cmdutil.Config) error {
-	c, _ := config.Client()
+func runHealthCommand(config *cmdutil.Config, live bool) error {
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-	resp, err := c.HealthCheck()
+	var resp *http.Response
+	if live {
+		resp, err = c.LivenessCheck()
+	} else {
+		resp, err = c.HealthCheck()
+	}
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -52,5 +71,8 @@ func runHealthCommand(config *cmdutil.Config) error {
 	}
 
 	fmt.Println(string(respBody))
+	if resp.StatusCode != http.StatusOK {
+		os.Exit(1)
+	}
 	return nil
 }
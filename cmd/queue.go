@@ -26,7 +26,11 @@ func NewQueueCmd(config *cmdutil.Config) *cobra.Command {
 		Short: "Access the daemon's event queue.",
 	}
 
+	cmd.AddCommand(NewQueueFollowCmd(config))
+	cmd.AddCommand(NewQueueRebuildStatusCmd(config))
+	cmd.AddCommand(NewQueueReencryptCmd(config))
 	cmd.AddCommand(NewQueueRetryCmd(config))
+	cmd.AddCommand(NewQueueSkipCmd(config))
 	cmd.AddCommand(NewQueueStatusCmd(config))
 
 	return cmd
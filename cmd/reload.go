@@ -0,0 +1,70 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/spf13/cobra"
+)
+
+func NewServerReloadCmd(config *cmdutil.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reload",
+		Short: "Reload a running pdagent server's configuration.",
+		Long: `Reloads a running server's config file, as sending it SIGHUP does.
+
+Secrets, API tokens, log level, region, expiry, health, Events API,
+Alertmanager, webhook and API limit settings are applied immediately. Any other changed settings are listed, and only take
+effect once the server restarts. An invalid config file is rejected, leaving the
+running configuration unchanged.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReloadCommand(config)
+		},
+	}
+
+	return cmd
+}
+
+func runReloadCommand(config *cmdutil.Config) error {
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	resp, err := c.ServerReload()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println(string(respBody))
+	if resp.StatusCode != http.StatusOK {
+		os.Exit(1)
+	}
+	return nil
+}
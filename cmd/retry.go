@@ -41,7 +41,11 @@ func NewQueueRetryCmd(config *cmdutil.Config) *cobra.Command {
 }
 
 func runRetryCommand(config *cmdutil.Config, routingKey string) error {
-	c, _ := config.Client()
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
 	resp, err := c.QueueRetry(routingKey)
 	if err != nil {
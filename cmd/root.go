@@ -68,7 +68,7 @@ func NewRootCmd(config *cmdutil.Config) *cobra.Command {
 
 	pflags := rootCmd.PersistentFlags()
 	pflags.StringVar(&cmdutil.CfgFile, "config", "", "config file (default is $HOME/.go-pdagent.yaml)")
-	pflags.StringP("address", "a", defaults.Address, "address to run and access the agent server on.")
+	pflags.StringP("address", "a", defaults.Address, "address to run and access the agent server on, either host:port or unix:///path/to/socket.")
 	pflags.String("pidfile", defaults.Pidfile, "pidfile for the currently running pdagent instance, if any.")
 	pflags.StringP("secret", "s", defaults.Secret, "secret used to authorize agent access.")
 
@@ -85,13 +85,15 @@ func NewRootCmd(config *cmdutil.Config) *cobra.Command {
 	}
 
 	// All top-level commands go here
+	rootCmd.AddCommand(NewDBCmd(config))
 	rootCmd.AddCommand(NewEnqueueCmd(config))
 	rootCmd.AddCommand(NewHealthCmd(config))
 	rootCmd.AddCommand(NewInitCmd())
 	rootCmd.AddCommand(NewQueueCmd(config))
 	rootCmd.AddCommand(NewSendCmd(config))
-	rootCmd.AddCommand(NewServerCmd())
+	rootCmd.AddCommand(NewServerCmd(config))
 	rootCmd.AddCommand(NewVersionCmd())
+	rootCmd.AddCommand(NewWebhookCmd())
 	rootCmd.AddCommand(nagios.NewNagiosCmd(config))
 	rootCmd.AddCommand(sensu.NewSensuCmd(config))
 	rootCmd.AddCommand(zabbix.NewZabbixCmd(config))
@@ -23,6 +23,7 @@ import (
 
 func NewSendCmd(config *cmdutil.Config) *cobra.Command {
 	var customDetails map[string]string
+	var sendOptions func() []cmdutil.SendOption
 
 	var sendEvent = eventsapi.EventV1{
 		Details: eventsapi.DetailsV1{},
@@ -38,7 +39,7 @@ func NewSendCmd(config *cmdutil.Config) *cobra.Command {
 
 		RunE: func(cmd *cobra.Command, args []string) error {
 			sendEvent.Details = cmdutil.StringMapToInterfaceMap(customDetails)
-			return cmdutil.RunSendCommand(config, &sendEvent)
+			return cmdutil.RunSendCommand(config, &sendEvent, sendOptions()...)
 		},
 	}
 
@@ -50,6 +51,8 @@ func NewSendCmd(config *cmdutil.Config) *cobra.Command {
 	cmd.Flags().StringVarP(&sendEvent.ClientURL, "client-url", "u", "", "Client URL")
 	cmd.Flags().StringToStringVarP(&customDetails, "field", "f", map[string]string{}, "Add given KEY=VALUE pair to the event details")
 
+	sendOptions = cmdutil.AddWaitFlags(cmd)
+
 	cmd.MarkFlagRequired("service-key")
 	cmd.MarkFlagRequired("event-type")
 
@@ -19,17 +19,27 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"reflect"
+	"sort"
 
 	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
+	"github.com/PagerDuty/go-pdagent/pkg/common"
 	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
 	"github.com/PagerDuty/go-pdagent/pkg/server"
+	"github.com/PagerDuty/go-pdagent/pkg/smtp"
+	"github.com/PagerDuty/go-pdagent/pkg/snmp"
+	"github.com/PagerDuty/go-pdagent/pkg/syslog"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+var allowedRegions = []string{"us", "eu"}
+
 var errInvalidRegion = errors.New(`region must be either "us" or "eu"`)
+var errInvalidOrderingOnFailure = errors.New(`ordering.on_failure must be either "skip" or "block"`)
+var errInvalidOrderingScope = errors.New(`ordering.scope must be either "routing_key" or "dedup_key"`)
 
-func NewServerCmd() *cobra.Command {
+func NewServerCmd(config *cmdutil.Config) *cobra.Command {
 
 	cmd := &cobra.Command{
 		Use:   "server",
@@ -52,7 +62,8 @@ func NewServerCmd() *cobra.Command {
 		fmt.Println(err)
 	}
 
-	cmd.AddCommand(NewServerStopCmd())
+	cmd.AddCommand(NewServerReloadCmd(config))
+	cmd.AddCommand(NewServerStopCmd(config))
 
 	return cmd
 }
@@ -61,18 +72,102 @@ func runServerCommand() error {
 	address := viper.GetString("address")
 	database := viper.GetString("database")
 	pidfile := viper.GetString("pidfile")
-	secret := viper.GetString("secret")
-	region := viper.GetString("region")
 
-	allowedRegions := []string{"us", "eu"}
-	if err := cmdutil.ValidateEnumField(region, allowedRegions, errInvalidRegion); err != nil {
+	settings, err := loadSettings()
+	if err != nil {
+		return err
+	}
+
+	var ordering persistentqueue.OrderingPolicy
+	if err := viper.UnmarshalKey("ordering", &ordering); err != nil {
+		return err
+	}
+
+	allowedOnFailure := []string{"", persistentqueue.OrderingSkip, persistentqueue.OrderingBlock}
+	if err := cmdutil.ValidateEnumField(ordering.OnFailure, allowedOnFailure, errInvalidOrderingOnFailure); err != nil {
+		return err
+	}
+
+	allowedScopes := []string{"", persistentqueue.OrderingScopeRoutingKey, persistentqueue.OrderingScopeDedupKey}
+	if err := cmdutil.ValidateEnumField(ordering.Scope, allowedScopes, errInvalidOrderingScope); err != nil {
+		return err
+	}
+
+	tlsConfig, err := cmdutil.GetTLSConfig()
+	if err != nil {
+		return err
+	}
+	serverTLS, err := tlsConfig.ServerTLS()
+	if err != nil {
+		return err
+	}
+
+	keyring, err := loadKeyring()
+	if err != nil {
 		return err
 	}
 
-	queue := persistentqueue.NewPersistentQueue(persistentqueue.WithFile(database))
+	queue := persistentqueue.NewPersistentQueue(
+		persistentqueue.WithFile(database),
+		persistentqueue.WithKeyring(keyring),
+		persistentqueue.WithOrdering(ordering),
+	)
+
+	var socket server.SocketConfig
+	if err := viper.UnmarshalKey("socket", &socket); err != nil {
+		return err
+	}
+
+	options := []server.Option{
+		server.WithSocket(socket),
+		server.WithReload(reloadConfig()),
+	}
+	if serverTLS != nil {
+		options = append(options, server.WithTLS(serverTLS))
+	}
+
+	if viper.IsSet("syslog") {
+		var syslogConfig syslog.Config
+		if err := viper.UnmarshalKey("syslog", &syslogConfig); err != nil {
+			return err
+		}
+		receiver, err := syslog.NewReceiver(syslogConfig, queue)
+		if err != nil {
+			return err
+		}
+		options = append(options, server.WithReceivers(receiver))
+	}
+
+	if viper.IsSet("snmp") {
+		var snmpConfig snmp.Config
+		if err := viper.UnmarshalKey("snmp", &snmpConfig); err != nil {
+			return err
+		}
+		receiver, err := snmp.NewReceiver(snmpConfig, queue)
+		if err != nil {
+			return err
+		}
+		options = append(options, server.WithReceivers(receiver))
+	}
+
+	if viper.IsSet("smtp") {
+		var smtpConfig smtp.Config
+		if err := viper.UnmarshalKey("smtp", &smtpConfig); err != nil {
+			return err
+		}
+		receiver, err := smtp.NewReceiver(smtpConfig, queue)
+		if err != nil {
+			return err
+		}
+		options = append(options, server.WithReceivers(receiver))
+	}
+
+	server := server.NewServer(address, settings.Secret, pidfile, queue, options...)
+	if err := server.Reconfigure(settings); err != nil {
+		return err
+	}
 
-	server := server.NewServer(address, secret, pidfile, queue)
-	err := server.Start()
+	err = server.Start()
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -80,3 +175,124 @@ func runServerCommand() error {
 
 	return nil
 }
+
+// Settings applied when the server reloads its configuration.
+var reloadableSettings = []string{"secret", "tokens", "log_level", "region", "expiry", "health", "events_api", "alertmanager", "webhooks", "api"}
+
+// Settings that only take effect when the server starts.
+var restartSettings = []string{"address", "database", "pidfile", "socket", "tls", "encryption", "ordering", "syslog", "snmp", "smtp"}
+
+// loadSettings returns the configured server settings that may be changed
+// while it's running.
+func loadSettings() (server.Settings, error) {
+	settings := server.Settings{
+		Secret: viper.GetString("secret"),
+		Region: viper.GetString("region"),
+	}
+
+	if err := cmdutil.ValidateEnumField(settings.Region, allowedRegions, errInvalidRegion); err != nil {
+		return settings, err
+	}
+
+	var err error
+	if settings.LogLevel, err = common.ParseLogLevel(viper.GetString("log_level")); err != nil {
+		return settings, err
+	}
+
+	if err := viper.UnmarshalKey("expiry", &settings.Expiry); err != nil {
+		return settings, err
+	}
+
+	if err := viper.UnmarshalKey("health", &settings.Health); err != nil {
+		return settings, err
+	}
+	if err := viper.UnmarshalKey("events_api", &settings.EventsAPI); err != nil {
+		return settings, err
+	}
+	if err := viper.UnmarshalKey("alertmanager", &settings.Alertmanager); err != nil {
+		return settings, err
+	}
+	if err := viper.UnmarshalKey("webhooks", &settings.Webhooks); err != nil {
+		return settings, err
+	}
+	if err := viper.UnmarshalKey("api", &settings.API); err != nil {
+		return settings, err
+	}
+
+	settings.Tokens, err = loadTokens()
+	return settings, err
+}
+
+// loadTokens returns the configured API tokens.
+func loadTokens() ([]server.Token, error) {
+	var tokens []server.Token
+	err := viper.UnmarshalKey("tokens", &tokens)
+	return tokens, err
+}
+
+// reloadConfig returns a function that re-reads the config file, applying
+// reloadable settings to the server and its queue and reporting which other
+// settings changed since it started.
+//
+// Settings are all validated, then applied together by `Reconfigure`, so an
+// invalid config file leaves the running configuration unchanged.
+//
+// Other than when starting, viper is only used by reloads, which the server
+// runs one at a time, as it isn't safe for concurrent use.
+func reloadConfig() server.ReloadFunc {
+	loaded := configValues(reloadableSettings)
+	started := configValues(restartSettings)
+
+	return func(s *server.Server) (server.ReloadResult, error) {
+		var result server.ReloadResult
+
+		if viper.ConfigFileUsed() != "" {
+			if err := viper.ReadInConfig(); err != nil {
+				return result, err
+			}
+		}
+
+		settings, err := loadSettings()
+		if err != nil {
+			return result, err
+		}
+		if err := s.Reconfigure(settings); err != nil {
+			return result, err
+		}
+
+		result.Applied = changedSettings(loaded, configValues(reloadableSettings))
+		result.RestartRequired = changedSettings(started, configValues(restartSettings))
+		loaded = configValues(reloadableSettings)
+		return result, nil
+	}
+}
+
+// configValues returns the current values of the given settings.
+func configValues(keys []string) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, key := range keys {
+		values[key] = viper.Get(key)
+	}
+	return values
+}
+
+// changedSettings returns the names of settings whose values differ, in
+// sorted order.
+func changedSettings(before, after map[string]interface{}) []string {
+	changed := []string{}
+	for key, value := range after {
+		if !reflect.DeepEqual(before[key], value) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// loadKeyring returns the configured encryption keys, if any.
+func loadKeyring() (*persistentqueue.Keyring, error) {
+	return persistentqueue.LoadKeyring(
+		viper.GetString("encryption.key_file"),
+		viper.GetString("encryption.key_env"),
+	)
+}
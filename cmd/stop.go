@@ -17,22 +17,43 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"time"
 
+	"github.com/PagerDuty/go-pdagent/pkg/cmdutil"
 	"github.com/PagerDuty/go-pdagent/pkg/common"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-func NewServerStopCmd() *cobra.Command {
+// How long to wait for a draining server to stop, beyond its drain timeout.
+const stopGracePeriod = 15 * time.Second
+
+func NewServerStopCmd(config *cmdutil.Config) *cobra.Command {
+	var drain bool
+	var timeout time.Duration
+
 	cmd := &cobra.Command{
 		Use:   "stop",
 		Short: "Gracefully stop a running pdagent server.",
+		Long: `Gracefully stop a running pdagent server.
+
+Events that haven't been sent when the server stops remain queued, and are
+sent once it next starts. With --drain, the server stops accepting events then
+keeps sending those already queued for up to --timeout before stopping.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if drain {
+				return runDrainCommand(config, timeout)
+			}
 			return runStopCommand()
 		},
 	}
 
+	cmd.Flags().BoolVar(&drain, "drain", false, "Send queued events before stopping")
+	cmd.Flags().DurationVar(&timeout, "timeout", 60*time.Second, "How long to drain queued events for, with --drain")
+
 	return cmd
 }
 
@@ -53,3 +74,43 @@ func runStopCommand() error {
 	os.Exit(0)
 	return nil
 }
+
+func runDrainCommand(config *cmdutil.Config, timeout time.Duration) error {
+	c, err := config.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	resp, err := c.ServerStop(timeout)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println(string(respBody))
+	if resp.StatusCode != http.StatusOK {
+		os.Exit(1)
+	}
+
+	// The server removes its pidfile once it has stopped.
+	pidfile := viper.GetString("pidfile")
+	deadline := time.Now().Add(timeout + stopGracePeriod)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(pidfile); os.IsNotExist(err) {
+			fmt.Println("Server stopped.")
+			os.Exit(0)
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+
+	fmt.Printf("Server didn't stop within %v.\n", timeout+stopGracePeriod)
+	os.Exit(1)
+	return nil
+}
@@ -0,0 +1,126 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/PagerDuty/go-pdagent/pkg/webhook"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func NewWebhookCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "webhook",
+		Short: "Work with the server's generic webhooks.",
+	}
+
+	cmd.AddCommand(NewWebhookTestCmd())
+
+	return cmd
+}
+
+func NewWebhookTestCmd() *cobra.Command {
+	var body string
+
+	cmd := &cobra.Command{
+		Use:   "test [name]",
+		Short: "Show the event a webhook would produce from a body.",
+		Long: `Show the event a configured webhook would produce from a body, without
+sending it.
+
+The webhook is read from the config file's "webhooks", and may be omitted if
+only one is configured. The body is read from the given file, or from stdin if
+it's "-".`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := ""
+			if len(args) > 0 {
+				name = args[0]
+			}
+			return runWebhookTestCommand(name, body)
+		},
+	}
+
+	cmd.Flags().StringVar(&body, "body", "-", "file containing the webhook's JSON body, or - for stdin")
+
+	return cmd
+}
+
+func runWebhookTestCommand(name, bodyFile string) error {
+	var configs []webhook.Config
+	if err := viper.UnmarshalKey("webhooks", &configs); err != nil {
+		return err
+	}
+
+	config, err := findWebhook(configs, name)
+	if err != nil {
+		return err
+	}
+
+	w, err := webhook.New(config)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	if bodyFile == "-" {
+		body, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		body, err = ioutil.ReadFile(bodyFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	event, err := w.Event(body)
+	if err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(event, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+
+	if err := event.Validate(); err != nil {
+		return fmt.Errorf("event would be rejected: %v", err)
+	}
+	return nil
+}
+
+// findWebhook returns the named webhook's configuration, or the only
+// configured webhook if no name is given.
+func findWebhook(configs []webhook.Config, name string) (webhook.Config, error) {
+	if name == "" {
+		if len(configs) != 1 {
+			return webhook.Config{}, fmt.Errorf("%v webhooks are configured, specify which to test", len(configs))
+		}
+		return configs[0], nil
+	}
+
+	for _, config := range configs {
+		if config.Name == name {
+			return config, nil
+		}
+	}
+	return webhook.Config{}, fmt.Errorf("no webhook named %q is configured", name)
+}
@@ -2,37 +2,89 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"time"
 
+	"github.com/PagerDuty/go-pdagent/pkg/common"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
 )
 
+// Host used in requests over a Unix socket, where there's no real host.
+const socketHost = "localhost"
+
 type Client struct {
 	HTTPClient    *http.Client
 	ServerAddress string
 
+	// Scheme used to connect to the server, either "http" (the default) or
+	// "https".
+	Scheme string
+
 	secret string
 }
 
+// NewClient creates a client for the agent server at the given address,
+// either a TCP host and port or a Unix socket path prefixed with `unix://`.
 func NewClient(httpClient *http.Client, serverAddress, secret string) *Client {
+	if network, path := common.SplitAddress(serverAddress); network == "unix" {
+		httpClient = socketClient(httpClient, path)
+	}
+
 	return &Client{
 		HTTPClient:    httpClient,
 		ServerAddress: serverAddress,
+		Scheme:        "http",
 		secret:        secret,
 	}
 }
 
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	return c.do(c.HTTPClient, req)
+}
+
+func (c *Client) do(httpClient *http.Client, req *http.Request) (*http.Response, error) {
 	req.Header.Add("Authorization", fmt.Sprintf("token %v", c.secret))
-	return c.HTTPClient.Do(req)
+	return httpClient.Do(req)
 }
 
 // Send an event to the agent daemon server.
 func (c *Client) Send(event eventsapi.Event) (*http.Response, error) {
-	url := generateURL(c.ServerAddress, "/send")
+	req, err := c.sendRequest(event)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// SendAndWait sends an event to the agent daemon server, waiting up to the
+// timeout for it to be sent to PagerDuty.
+//
+// The server responds with a 202 if the event is still queued once the
+// timeout elapses.
+func (c *Client) SendAndWait(event eventsapi.Event, timeout time.Duration) (*http.Response, error) {
+	req, err := c.sendRequest(event)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = url.Values{"wait": {"true"}, "timeout": {timeout.String()}}.Encode()
+
+	// Allow for the wait on top of the client's usual timeout.
+	httpClient := *c.HTTPClient
+	if httpClient.Timeout > 0 {
+		httpClient.Timeout += timeout
+	}
+
+	return c.do(&httpClient, req)
+}
+
+func (c *Client) sendRequest(event eventsapi.Event) (*http.Request, error) {
+	url := c.generateURL("/send")
 
 	body, err := json.Marshal(event)
 	if err != nil {
@@ -48,11 +100,47 @@ func (c *Client) Send(event eventsapi.Event) (*http.Response, error) {
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("Pd-Event-Version", event.Version().String())
 
+	return req, nil
+}
+
+// SendBatch sends several events to the agent daemon server at once.
+func (c *Client) SendBatch(events []eventsapi.Event) (*http.Response, error) {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return nil, err
+	}
+	return c.SendBatchFrom(bytes.NewBuffer(body))
+}
+
+// SendBatchFrom sends a batch of events read from r, either a JSON array or
+// newline-delimited JSON, to the agent daemon server.
+func (c *Client) SendBatchFrom(r io.Reader) (*http.Response, error) {
+	url := c.generateURL("/send/batch")
+
+	req, err := http.NewRequest("POST", url.String(), r)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/json")
+
 	return c.Do(req)
 }
 
 func (c *Client) QueueRetry(routingKey string) (*http.Response, error) {
-	url := generateURL(c.ServerAddress, "/queue/retry")
+	url := c.generateURL("/queue/retry")
+	url.RawQuery = fmt.Sprintf("rk=%v", routingKey)
+
+	req, err := http.NewRequest("POST", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+func (c *Client) QueueSkip(routingKey string) (*http.Response, error) {
+	url := c.generateURL("/queue/skip")
 	url.RawQuery = fmt.Sprintf("rk=%v", routingKey)
 
 	req, err := http.NewRequest("POST", url.String(), nil)
@@ -63,18 +151,102 @@ func (c *Client) QueueRetry(routingKey string) (*http.Response, error) {
 }
 
 func (c *Client) QueueStatus(routingKey string) (*http.Response, error) {
-	url := generateURL(c.ServerAddress, "/queue/status")
+	url := c.generateURL("/queue/status")
+	url.RawQuery = fmt.Sprintf("rk=%v", routingKey)
+
+	req, err := http.NewRequest("GET", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Allowance on top of the client's usual timeout for reading a stream,
+// which the server ends after a minute.
+const streamTimeout = 90 * time.Second
+
+// QueueStream opens a stream of server-sent events for transitions of queued
+// events, for a routing key if given, resuming after `lastEventID` if given.
+func (c *Client) QueueStream(routingKey, lastEventID string) (*http.Response, error) {
+	url := c.generateURL("/queue/stream")
 	url.RawQuery = fmt.Sprintf("rk=%v", routingKey)
 
 	req, err := http.NewRequest("GET", url.String(), nil)
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Add("Accept", "text/event-stream")
+	if lastEventID != "" {
+		req.Header.Add("Last-Event-ID", lastEventID)
+	}
+
+	httpClient := *c.HTTPClient
+	if httpClient.Timeout > 0 {
+		httpClient.Timeout += streamTimeout
+	}
+	return c.do(&httpClient, req)
+}
+
+func (c *Client) QueueRebuildStatus() (*http.Response, error) {
+	url := c.generateURL("/queue/status/rebuild")
+
+	req, err := http.NewRequest("POST", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+func (c *Client) QueueReencrypt() (*http.Response, error) {
+	url := c.generateURL("/queue/reencrypt")
+
+	req, err := http.NewRequest("POST", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// DBBackup asks the server to write a consistent backup of its database to
+// the given path, or alongside the database if empty.
+func (c *Client) DBBackup(path string) (*http.Response, error) {
+	query := url.Values{"path": {path}}
+	url := c.generateURL("/db/backup")
+	url.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("POST", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// ServerReload asks the server to reload its configuration.
+func (c *Client) ServerReload() (*http.Response, error) {
+	url := c.generateURL("/server/reload")
+
+	req, err := http.NewRequest("POST", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// ServerStop asks the server to stop, first draining queued events for up to
+// the given duration.
+func (c *Client) ServerStop(drain time.Duration) (*http.Response, error) {
+	url := c.generateURL("/server/stop")
+	url.RawQuery = fmt.Sprintf("drain=%v", drain)
+
+	req, err := http.NewRequest("POST", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
 	return c.Do(req)
 }
 
 func (c *Client) HealthCheck() (*http.Response, error) {
-	url := generateURL(c.ServerAddress, "/health")
+	url := c.generateURL("/health")
 
 	req, err := http.NewRequest("GET", url.String(), nil)
 
@@ -84,10 +256,51 @@ func (c *Client) HealthCheck() (*http.Response, error) {
 	return c.Do(req)
 }
 
-func generateURL(serverAddress, path string) *url.URL {
+// LivenessCheck checks only whether the server is alive, rather than ready to
+// send events.
+func (c *Client) LivenessCheck() (*http.Response, error) {
+	url := c.generateURL("/health/live")
+
+	req, err := http.NewRequest("GET", url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+func (c *Client) generateURL(path string) *url.URL {
+	host := c.ServerAddress
+	if network, _ := common.SplitAddress(host); network == "unix" {
+		host = socketHost
+	}
+
 	return &url.URL{
-		Scheme: "http",
-		Host:   serverAddress,
+		Scheme: c.Scheme,
+		Host:   host,
 		Path:   path,
 	}
 }
+
+// socketClient returns a copy of httpClient that connects to the Unix socket
+// at path, whatever the requested host.
+func socketClient(httpClient *http.Client, path string) *http.Client {
+	transport := httpClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	t, ok := transport.(*http.Transport)
+	if !ok {
+		return httpClient
+	}
+
+	t = t.Clone()
+	t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, "unix", path)
+	}
+
+	socketClient := *httpClient
+	socketClient.Transport = t
+	return &socketClient
+}
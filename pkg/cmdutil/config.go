@@ -32,8 +32,25 @@ type Config struct {
 
 func NewConfig() *Config {
 	httpClientFunc := func() (*http.Client, error) {
+		tlsConfig, err := GetTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		clientTLS, err := tlsConfig.ClientTLS()
+		if err != nil {
+			return nil, err
+		}
+
+		transport := http.DefaultTransport
+		if clientTLS != nil {
+			t := http.DefaultTransport.(*http.Transport).Clone()
+			t.TLSClientConfig = clientTLS
+			transport = t
+		}
+
 		client := &http.Client{
-			Transport: http.DefaultTransport,
+			Transport: transport,
 			Timeout:   5 * time.Second,
 		}
 		return client, nil
@@ -42,8 +59,16 @@ func NewConfig() *Config {
 	return &Config{
 		HttpClient: httpClientFunc,
 		Client: func() (*client.Client, error) {
-			httpClient, _ := httpClientFunc()
+			httpClient, err := httpClientFunc()
+			if err != nil {
+				return nil, err
+			}
+
 			c := client.NewClient(httpClient, viper.GetString("address"), viper.GetString("secret"))
+
+			if tlsConfig, _ := GetTLSConfig(); tlsConfig.ClientEnabled() {
+				c.Scheme = "https"
+			}
 			return c, nil
 		},
 	}
@@ -16,16 +16,71 @@ limitations under the License.
 package cmdutil
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/spf13/cobra"
 )
 
-func RunSendCommand(config *Config, sendEvent eventsapi.Event) error {
-	c, _ := config.Client()
+// ErrEventNotSent is returned when waiting for an event to be sent, if it
+// failed or remained queued once the wait timed out.
+var ErrEventNotSent = errors.New("event wasn't sent successfully")
 
-	resp, err := c.Send(sendEvent)
+type sendConfig struct {
+	wait    bool
+	timeout time.Duration
+}
+
+type SendOption func(*sendConfig)
+
+// WithWait waits up to the timeout for the event to be sent to PagerDuty,
+// rather than returning once it's queued.
+func WithWait(timeout time.Duration) SendOption {
+	return func(sc *sendConfig) {
+		sc.wait = true
+		sc.timeout = timeout
+	}
+}
+
+// AddWaitFlags adds the `--wait` and `--timeout` flags to a send command,
+// returning a function giving the corresponding options.
+func AddWaitFlags(cmd *cobra.Command) func() []SendOption {
+	var wait bool
+	var timeout time.Duration
+
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait for the event to be sent to PagerDuty, printing its response")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "How long to wait for the event to be sent, with --wait")
+
+	return func() []SendOption {
+		if !wait {
+			return nil
+		}
+		return []SendOption{WithWait(timeout)}
+	}
+}
+
+func RunSendCommand(config *Config, sendEvent eventsapi.Event, options ...SendOption) error {
+	var sc sendConfig
+	for _, option := range options {
+		option(&sc)
+	}
+
+	c, err := config.Client()
+	if err != nil {
+		return err
+	}
+
+	var resp *http.Response
+	if sc.wait {
+		resp, err = c.SendAndWait(sendEvent, sc.timeout)
+	} else {
+		resp, err = c.Send(sendEvent)
+	}
 	if err != nil {
 		return err
 	}
@@ -37,5 +92,14 @@ func RunSendCommand(config *Config, sendEvent eventsapi.Event) error {
 	}
 
 	fmt.Println(string(respBody))
+
+	if sc.wait {
+		var sent struct {
+			Status string `json:"status"`
+		}
+		if resp.StatusCode != http.StatusOK || json.Unmarshal(respBody, &sent) != nil || sent.Status != "success" {
+			return ErrEventNotSent
+		}
+	}
 	return nil
 }
@@ -0,0 +1,161 @@
+/*
+Copyright © 2020 PagerDuty, Inc. <info@pagerduty.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmdutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/viper"
+)
+
+var errTLSKeyPair = errors.New("tls.cert_file and tls.key_file must be set together")
+
+var errTLSClientKeyPair = errors.New("tls.client_cert_file and tls.client_key_file must be set together")
+
+var errTLSClientCA = errors.New("tls.client_ca_file requires tls.cert_file and tls.key_file, as client certificates are only verified over HTTPS")
+
+// TLSConfig holds the `tls` configuration shared by the server and the
+// commands that talk to it.
+//
+// The server serves HTTPS when given a certificate, and additionally requires
+// client certificates signed by `ClientCAFile` if set. Clients use HTTPS when
+// either a certificate or `CAFile` is configured, presenting their own
+// certificate if `ClientCertFile` is set.
+type TLSConfig struct {
+	CertFile     string `mapstructure:"cert_file"`
+	KeyFile      string `mapstructure:"key_file"`
+	ClientCAFile string `mapstructure:"client_ca_file"`
+
+	CAFile         string `mapstructure:"ca_file"`
+	ClientCertFile string `mapstructure:"client_cert_file"`
+	ClientKeyFile  string `mapstructure:"client_key_file"`
+	ServerName     string `mapstructure:"server_name"`
+}
+
+// GetTLSConfig reads the TLS configuration, returning an error if it's
+// invalid.
+func GetTLSConfig() (TLSConfig, error) {
+	var c TLSConfig
+	if err := viper.UnmarshalKey("tls", &c); err != nil {
+		return c, err
+	}
+	return c, c.validate()
+}
+
+// validate rejects configurations that would silently serve less securely
+// than intended, such as requiring client certificates without HTTPS.
+func (c TLSConfig) validate() error {
+	if c.ClientCAFile != "" && !c.ServerEnabled() {
+		return errTLSClientCA
+	}
+	return nil
+}
+
+// ServerEnabled returns true if the server should serve HTTPS.
+func (c TLSConfig) ServerEnabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// ClientEnabled returns true if clients should connect using HTTPS.
+func (c TLSConfig) ClientEnabled() bool {
+	return c.ServerEnabled() || c.CAFile != "" || c.ClientCertFile != ""
+}
+
+// ServerTLS builds the server's TLS configuration, or nil if TLS isn't
+// enabled.
+func (c TLSConfig) ServerTLS() (*tls.Config, error) {
+	if !c.ServerEnabled() {
+		return nil, nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errTLSKeyPair
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.ClientCAFile != "" {
+		pool, err := loadCertPool(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return &config, nil
+}
+
+// ClientTLS builds the TLS configuration used to connect to the server, or
+// nil if TLS isn't enabled.
+//
+// The server's certificate is verified against `CAFile` if set, otherwise the
+// system's roots.
+func (c TLSConfig) ClientTLS() (*tls.Config, error) {
+	if !c.ClientEnabled() {
+		return nil, nil
+	}
+
+	config := tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.ServerName,
+	}
+
+	if c.CAFile != "" {
+		pool, err := loadCertPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+
+	if c.ClientCertFile != "" || c.ClientKeyFile != "" {
+		if c.ClientCertFile == "" || c.ClientKeyFile == "" {
+			return nil, errTLSClientKeyPair
+		}
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return &config, nil
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %v", file)
+	}
+	return pool, nil
+}
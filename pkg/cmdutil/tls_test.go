@@ -0,0 +1,161 @@
+package cmdutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// writeCert creates a certificate signed by parent (or self-signed if nil),
+// writing it and its key as PEM files named after `name` in dir.
+func writeCert(t *testing.T, dir, name string, parent *testCert, template *x509.Certificate) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	signer := &testCert{template, key}
+	if parent != nil {
+		signer = parent
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer.cert, &key.PublicKey, signer.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{cert, key}
+}
+
+func TestTLSConfigMutualAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pdagent-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := writeCert(t, dir, "ca", nil, &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	writeCert(t, dir, "server", ca, &x509.Certificate{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	})
+	writeCert(t, dir, "client", ca, &x509.Certificate{
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+
+	file := func(name string) string { return filepath.Join(dir, name) }
+
+	config := TLSConfig{
+		CertFile:     file("server.crt"),
+		KeyFile:      file("server.key"),
+		ClientCAFile: file("ca.crt"),
+	}
+	serverTLS, err := config.ServerTLS()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	server.TLS = serverTLS
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(config TLSConfig) error {
+		clientTLS, err := config.ClientTLS()
+		if err != nil {
+			return err
+		}
+		client := http.Client{Transport: &http.Transport{TLSClientConfig: clientTLS}}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	assert.NoError(t, get(TLSConfig{
+		CAFile:         file("ca.crt"),
+		ClientCertFile: file("client.crt"),
+		ClientKeyFile:  file("client.key"),
+	}))
+
+	// Without a client certificate.
+	assert.Error(t, get(TLSConfig{CAFile: file("ca.crt")}))
+
+	// Without trusting the server's CA.
+	assert.Error(t, get(TLSConfig{
+		ClientCertFile: file("client.crt"),
+		ClientKeyFile:  file("client.key"),
+	}))
+}
+
+func TestTLSConfigEnabled(t *testing.T) {
+	assert.False(t, TLSConfig{}.ServerEnabled())
+	assert.False(t, TLSConfig{}.ClientEnabled())
+	assert.True(t, TLSConfig{CertFile: "a", KeyFile: "b"}.ClientEnabled())
+	assert.True(t, TLSConfig{CAFile: "a"}.ClientEnabled())
+	assert.False(t, TLSConfig{CAFile: "a"}.ServerEnabled())
+
+	_, err := TLSConfig{CertFile: "a"}.ServerTLS()
+	assert.Equal(t, errTLSKeyPair, err)
+
+	_, err = TLSConfig{CAFile: "", ClientCertFile: "a"}.ClientTLS()
+	assert.Equal(t, errTLSClientKeyPair, err)
+
+	// Client certificates can only be required over HTTPS.
+	viper.Set("tls", map[string]interface{}{"client_ca_file": "a"})
+	defer viper.Set("tls", nil)
+	_, err = GetTLSConfig()
+	assert.Equal(t, errTLSClientCA, err)
+	assert.NoError(t, TLSConfig{CertFile: "a", KeyFile: "b", ClientCAFile: "c"}.validate())
+}
@@ -0,0 +1,15 @@
+package common
+
+import "strings"
+
+const unixAddressPrefix = "unix://"
+
+// SplitAddress returns the network and address of an agent server address,
+// which is either a TCP host and port (e.g. `127.0.0.1:49463`) or the path of
+// a Unix domain socket prefixed with `unix://`.
+func SplitAddress(address string) (network, addr string) {
+	if strings.HasPrefix(address, unixAddressPrefix) {
+		return "unix", strings.TrimPrefix(address, unixAddressPrefix)
+	}
+	return "tcp", address
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"sync/atomic"
 	"time"
 
 	"github.com/spf13/viper"
@@ -41,8 +42,24 @@ func UserAgent() string {
 	return fmt.Sprintf("go-pdagent/%v (%v, commit: %v, date: %v)", version, system, commit, date)
 }
 
+// region is the PagerDuty region set by `SetRegion`, if any.
+var region atomic.Value
+
+// SetRegion sets the PagerDuty region to send to, which may be changed while
+// running. Until set, the `region` setting is used.
+func SetRegion(name string) {
+	region.Store(name)
+}
+
+func currentRegion() string {
+	if name, ok := region.Load().(string); ok {
+		return name
+	}
+	return viper.GetString("region")
+}
+
 func PdEventsUrl() string {
-	region := viper.GetString("region")
+	region := currentRegion()
 	if region == "eu" {
 		return "https://events.eu.pagerduty.com"
 	}
@@ -50,7 +67,7 @@ func PdEventsUrl() string {
 }
 
 func PdApiUrl() string {
-	region := viper.GetString("region")
+	region := currentRegion()
 	if region == "eu" {
 		return "https://api.eu.pagerduty.com"
 	}
@@ -2,12 +2,16 @@ package common
 
 import (
 	"math/rand"
+	"sync"
 	"time"
 )
 
 var rkChars = []byte("abcdefghijklmnopqrstuvwxyz0123456789")
 var r *rand.Rand
 
+// Guards r, as keys are generated concurrently, e.g. by API requests.
+var rMu sync.Mutex
+
 func init() {
 	r = rand.New(rand.NewSource(time.Now().UnixNano()))
 }
@@ -17,6 +21,9 @@ func init() {
 // These keys approximate the Events API's routing keys for use in testing,
 // but may be useful more generally.
 func GenerateKey() string {
+	rMu.Lock()
+	defer rMu.Unlock()
+
 	rk := make([]byte, 32)
 	for i := range rk {
 		rk[i] = randChar()
@@ -9,3 +9,15 @@ func TestGenerateRoutingKey(t *testing.T) {
 		t.Error("Expected routing key to be exactly 32 characters.")
 	}
 }
+
+func TestSplitAddress(t *testing.T) {
+	network, addr := SplitAddress("127.0.0.1:49463")
+	if network != "tcp" || addr != "127.0.0.1:49463" {
+		t.Errorf("Expected TCP address, got %v %v.", network, addr)
+	}
+
+	network, addr = SplitAddress("unix:///var/run/pdagent/pdagent.sock")
+	if network != "unix" || addr != "/var/run/pdagent/pdagent.sock" {
+		t.Errorf("Expected Unix socket address, got %v %v.", network, addr)
+	}
+}
@@ -2,22 +2,45 @@ package common
 
 import (
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 var BaseLogger *zap.Logger
 var Logger *zap.SugaredLogger
 
+// LogLevel is the minimum level logged, which may be changed while running.
+var LogLevel = zap.NewAtomicLevel()
+
 // TODO: Eventually move configuration to config files.
 func init() {
 	if IsProduction() {
 		config := zap.NewProductionConfig()
+		config.Level = LogLevel
 		config.OutputPaths = []string{
 			"/var/log/pdagent/pdagent.log",
 		}
 		BaseLogger, _ = config.Build()
 	} else {
-		BaseLogger, _ = zap.NewDevelopment()
+		config := zap.NewDevelopmentConfig()
+		LogLevel.SetLevel(zapcore.DebugLevel)
+		config.Level = LogLevel
+		BaseLogger, _ = config.Build()
 	}
 
 	Logger = BaseLogger.Sugar()
 }
+
+// ParseLogLevel parses a level name, e.g. "debug" or "warn", returning the
+// default level if it's empty.
+func ParseLogLevel(name string) (zapcore.Level, error) {
+	if name == "" {
+		if IsProduction() {
+			return zapcore.InfoLevel, nil
+		}
+		return zapcore.DebugLevel, nil
+	}
+
+	var level zapcore.Level
+	err := level.UnmarshalText([]byte(name))
+	return level, err
+}
@@ -4,8 +4,10 @@ import (
 	"math"
 	"net"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/PagerDuty/go-pdagent/pkg/metrics"
 	"go.uber.org/zap"
 	"golang.org/x/net/http2"
 )
@@ -58,7 +60,13 @@ func (r RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	ctx := req.Context()
 
 	for tries := 0; tries < r.MaxRetries; tries++ {
+		if tries > 0 {
+			metrics.APIRetries.WithLabelValues(req.URL.Path).Inc()
+		}
+
+		start := time.Now()
 		resp, err = r.Transport.RoundTrip(req)
+		observeRequest(req, resp, time.Since(start))
 
 		if r.IsSuccess(resp, err) {
 			r.log.Debugf("Successful or non-retryable response.")
@@ -98,6 +106,15 @@ func (r RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return nil, err
 }
 
+// observeRequest records the duration of a single request attempt.
+func observeRequest(req *http.Request, resp *http.Response, duration time.Duration) {
+	code := "error"
+	if resp != nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	metrics.APIRequestDuration.WithLabelValues(req.URL.Path, code).Observe(duration.Seconds())
+}
+
 // calculateBackoff returns an exponential duration based on the try count.
 //
 // Currently back-off looks like: 1s, 2s, 4s, 8s, 16s, then capping at
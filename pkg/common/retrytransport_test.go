@@ -6,6 +6,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/PagerDuty/go-pdagent/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"gopkg.in/h2non/gock.v1"
 )
 
@@ -33,6 +35,9 @@ func TestRetryTransportSuccess(t *testing.T) {
 		Timeout:   10 * time.Second,
 	}
 
+	retries := metrics.APIRetries.WithLabelValues("/test")
+	before := testutil.ToFloat64(retries)
+
 	resp, err := client.Post("https://events.pagerduty.com/test", "application/json", bytes.NewBuffer([]byte("Hello")))
 	if err != nil {
 		t.Errorf("Unexpected error %v", err)
@@ -41,6 +46,10 @@ func TestRetryTransportSuccess(t *testing.T) {
 	if resp.StatusCode != 200 {
 		t.Errorf("Expected a success response, response was %+v.", resp)
 	}
+
+	if count := testutil.ToFloat64(retries) - before; count != 2 {
+		t.Errorf("Expected 2 retries to be counted, counted %v.", count)
+	}
 }
 
 func TestRetryTransportLimited(t *testing.T) {
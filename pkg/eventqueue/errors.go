@@ -9,6 +9,8 @@ var ErrAPIError = errors.New("an API error was encountered while processing even
 
 var ErrJobStopped = errors.New("job stopped while retrying")
 
+var ErrJobExpired = errors.New("job expired before it could be processed")
+
 type ErrBufferOverflow struct {
 	key  string
 	size int
@@ -2,9 +2,11 @@ package eventqueue
 
 import (
 	"sync"
+	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/common"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/metrics"
 	"go.uber.org/zap"
 )
 
@@ -76,15 +78,16 @@ func NewEventQueue() *EventQueue {
 
 // Shutdown the queue and all associated workers.
 //
-// There may be a blocking delay while any running workers or processors
-// attempt to complete their current tasks.
+// Processors are stopped, and jobs that haven't started yet are skipped with
+// an `ErrJobStopped` response. There may be a blocking delay while running
+// processors give up on their current tasks.
 func (q *EventQueue) Shutdown() {
 	q.logger.Info("Shutting down EventQueue.")
+	close(q.stop)
 	for _, w := range q.queues {
 		close(w)
 	}
 	q.wg.Wait()
-	close(q.stop)
 	q.logger.Info("Shut down EventQueue.")
 }
 
@@ -94,7 +97,9 @@ func (q *EventQueue) Shutdown() {
 // come in two flavors: Synchronous errors (e.g. event is invalid and never
 // queued) as a return value and asynchronous errors (e.g. server error) that
 // are part of the channel Response.
-func (q *EventQueue) Enqueue(eventContainer *eventsapi.EventContainer, respChan chan<- Response) error {
+//
+// Options may be provided to further configure the job, e.g. `WithDeadline`.
+func (q *EventQueue) Enqueue(eventContainer *eventsapi.EventContainer, respChan chan<- Response, options ...JobOption) error {
 	event, err := eventContainer.UnmarshalEvent()
 	if err != nil {
 		return err
@@ -108,8 +113,18 @@ func (q *EventQueue) Enqueue(eventContainer *eventsapi.EventContainer, respChan
 
 	q.ensureWorker(key)
 
+	job := Job{
+		EventContainer: eventContainer,
+		ResponseChan:   respChan,
+		Logger:         q.logger.Named(key),
+	}
+	for _, option := range options {
+		option(&job)
+	}
+
 	select {
-	case q.queues[key] <- Job{eventContainer, respChan, q.logger.Named(key)}:
+	case q.queues[key] <- job:
+		metrics.QueueDepth.WithLabelValues(metrics.RoutingKey(key)).Inc()
 		return nil
 	default:
 		respChan <- Response{Error: &ErrBufferOverflow{key, DefaultBufferSize}}
@@ -137,16 +152,78 @@ func (q *EventQueue) worker(key string, c <-chan Job) {
 
 	logger.Infof("Worker started.")
 	for job := range c {
+		metrics.QueueDepth.WithLabelValues(metrics.RoutingKey(key)).Dec()
+		if q.stopped() {
+			job.ResponseChan <- Response{Error: ErrJobStopped}
+			continue
+		}
 		logger.Infof("Job started, %v pending.", len(c))
+		if job.Precondition != nil {
+			if err := job.Precondition(); err != nil {
+				logger.Infof("Job precondition failed, skipping: %v", err)
+				job.ResponseChan <- Response{Error: err}
+				continue
+			}
+		}
+		if job.Expired() {
+			logger.Infof("Job expired at %v, skipping.", job.Deadline)
+			job.ResponseChan <- Response{Error: ErrJobExpired}
+			continue
+		}
 		q.Processor(job, q.stop)
 	}
 	logger.Infof("Worker stopped.")
 }
 
+func (q *EventQueue) stopped() bool {
+	select {
+	case <-q.stop:
+		return true
+	default:
+		return false
+	}
+}
+
 type Job struct {
 	EventContainer *eventsapi.EventContainer
 	ResponseChan   chan<- Response
 	Logger         *zap.SugaredLogger
+
+	// Deadline after which the job is no longer worth processing. A zero
+	// value means the job never expires.
+	Deadline time.Time
+
+	// Precondition, if set, is called by the worker before any other checks.
+	// If it returns an error the job isn't processed and the error is
+	// returned as its response.
+	Precondition func() error
+}
+
+// Expired returns true if the job has a deadline that has passed.
+func (j *Job) Expired() bool {
+	return !j.Deadline.IsZero() && time.Now().After(j.Deadline)
+}
+
+type JobOption func(*Job)
+
+// WithDeadline is an option for use in conjunction with Enqueue, causing the
+// job to be skipped with an `ErrJobExpired` response if it hasn't started
+// processing by the given time.
+func WithDeadline(deadline time.Time) JobOption {
+	return func(j *Job) {
+		j.Deadline = deadline
+	}
+}
+
+// WithPrecondition is an option for use in conjunction with Enqueue, checking
+// the given function before the job is processed.
+//
+// As workers process jobs for a routing key in order, a precondition may
+// safely block until earlier jobs for the same routing key have completed.
+func WithPrecondition(precondition func() error) JobOption {
+	return func(j *Job) {
+		j.Precondition = precondition
+	}
 }
 
 type Response struct {
@@ -1,6 +1,7 @@
 package eventqueue
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -118,3 +119,83 @@ func TestEventQueueMultiOrdering(t *testing.T) {
 		t.Error("Expected first event, but instead out of order..")
 	}
 }
+
+func TestEventQueueExpiredJob(t *testing.T) {
+	eq := NewEventQueue()
+	defer eq.Shutdown()
+
+	respChan := make(chan Response)
+	event := test.BuildV2EventContainer(common.GenerateKey())
+
+	eq.Processor = func(job Job, _ chan bool) {
+		t.Error("Expected expired job not to be processed.")
+		job.ResponseChan <- Response{}
+	}
+
+	err := eq.Enqueue(&event, respChan, WithDeadline(time.Now().Add(-time.Second)))
+	if err != nil {
+		t.Error(err)
+	}
+
+	resp := <-respChan
+	if resp.Error != ErrJobExpired {
+		t.Errorf("Expected expired error, got %v.", resp.Error)
+	}
+}
+
+func TestEventQueuePrecondition(t *testing.T) {
+	eq := NewEventQueue()
+	defer eq.Shutdown()
+
+	respChan := make(chan Response)
+	event := test.BuildV2EventContainer(common.GenerateKey())
+	preconditionErr := errors.New("precondition failed")
+
+	eq.Processor = func(job Job, _ chan bool) {
+		t.Error("Expected job failing precondition not to be processed.")
+		job.ResponseChan <- Response{}
+	}
+
+	err := eq.Enqueue(&event, respChan, WithPrecondition(func() error { return preconditionErr }))
+	if err != nil {
+		t.Error(err)
+	}
+
+	resp := <-respChan
+	if resp.Error != preconditionErr {
+		t.Errorf("Expected precondition error, got %v.", resp.Error)
+	}
+}
+
+// Shutting down stops the running job, and skips those that haven't started.
+func TestEventQueueShutdown(t *testing.T) {
+	eq := NewEventQueue()
+
+	started := make(chan bool)
+	eq.Processor = func(job Job, stop chan bool) {
+		started <- true
+		<-stop
+		job.ResponseChan <- Response{Error: ErrJobStopped}
+	}
+
+	key := common.GenerateKey()
+	event1 := test.BuildV2EventContainer(key)
+	event2 := test.BuildV2EventContainer(key)
+
+	respChan := make(chan Response, 2)
+	if err := eq.Enqueue(&event1, respChan); err != nil {
+		t.Fatal(err)
+	}
+	if err := eq.Enqueue(&event2, respChan); err != nil {
+		t.Fatal(err)
+	}
+
+	<-started
+	eq.Shutdown()
+
+	for i := 0; i < 2; i++ {
+		if resp := <-respChan; resp.Error != ErrJobStopped {
+			t.Errorf("Expected job to be stopped, got %v.", resp.Error)
+		}
+	}
+}
@@ -16,10 +16,24 @@ type Processor func(Job, chan bool)
 // EventProcessor is a Job processor for use by an EventQueue specifically
 // designed to send and receive from the PagerDuty Events V1 or V2 API.
 //
-// It accepts a Job containing an EventContainer
+// It accepts a Job containing an EventContainer. If the queue is stopped while
+// the event is being sent or retried, it gives up with `ErrJobStopped`.
 func EventProcessor(job Job, stop chan bool) {
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	resp, err := eventsapi.Enqueue(ctx, job.EventContainer)
+	if err != nil && ctx.Err() != nil {
+		err = ErrJobStopped
+	}
 
 	job.ResponseChan <- Response{resp, err}
 }
@@ -23,3 +23,12 @@ func (ec *EventContainer) UnmarshalEvent() (Event, error) {
 		return nil, ErrUnrecognizedEventType
 	}
 }
+
+// NewEventContainer wraps an event for queuing.
+func NewEventContainer(event Event) (*EventContainer, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return &EventContainer{EventVersion: event.Version(), EventData: data}, nil
+}
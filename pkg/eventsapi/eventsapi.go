@@ -32,7 +32,7 @@ type Response interface {
 
 // BaseResponse is a minimal implementation of the `Response` interface.
 type BaseResponse struct {
-	HTTPResponse *http.Response
+	HTTPResponse *http.Response `json:"-"`
 	retryable    bool
 }
 
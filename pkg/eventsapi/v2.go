@@ -2,13 +2,19 @@ package eventsapi
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"unicode/utf8"
 
 	"github.com/PagerDuty/go-pdagent/pkg/common"
 )
 
 const endpointV2 = "/v2/enqueue"
 
+// MaxSummaryLength is the longest summary accepted by the Events API, in
+// bytes.
+const MaxSummaryLength = 1024
+
 // EventV2 corresponds to a V2 event object.
 type EventV2 struct {
 	RoutingKey  string    `json:"routing_key"`
@@ -49,6 +55,37 @@ type PayloadV2 struct {
 	CustomDetails map[string]interface{} `json:"custom_details,omitempty"`
 }
 
+// ValidSeverity returns whether the Events API accepts a payload's severity.
+func ValidSeverity(severity string) bool {
+	switch severity {
+	case "critical", "error", "warning", "info":
+		return true
+	}
+	return false
+}
+
+// CheckSeverity returns an error describing the accepted severities if
+// severity isn't one of them.
+func CheckSeverity(severity string) error {
+	if !ValidSeverity(severity) {
+		return fmt.Errorf("unknown severity %q, expected critical, error, warning or info", severity)
+	}
+	return nil
+}
+
+// TruncateSummary shortens a summary to at most `MaxSummaryLength` bytes,
+// without splitting a UTF-8 character.
+func TruncateSummary(summary string) string {
+	if len(summary) <= MaxSummaryLength {
+		return summary
+	}
+	end := MaxSummaryLength
+	for end > 0 && !utf8.RuneStart(summary[end]) {
+		end--
+	}
+	return summary[:end]
+}
+
 // ImageV2 corresponds to a V2 image object.
 type ImageV2 struct {
 	Source string `json:"src"`
@@ -68,7 +105,7 @@ type ResponseV2 struct {
 
 	Status   string   `json:"status,omitempty"`
 	Message  string   `json:"message,omitempty"`
-	DedupKey string   `json:"dedupkey,omitempty"`
+	DedupKey string   `json:"dedup_key,omitempty"`
 	Errors   []string `json:"errors,omitempty"`
 }
 
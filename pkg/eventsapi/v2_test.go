@@ -3,7 +3,9 @@ package eventsapi
 import (
 	"context"
 	"net/http"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"gopkg.in/h2non/gock.v1"
 )
@@ -111,3 +113,44 @@ func TestEnqueueV2TooManyRequests(t *testing.T) {
 		t.Errorf("Expected status code to be 429, was %v", resp.Status)
 	}
 }
+
+func TestValidSeverity(t *testing.T) {
+	for _, severity := range []string{"critical", "error", "warning", "info"} {
+		if !ValidSeverity(severity) {
+			t.Errorf("Expected severity %q to be valid", severity)
+		}
+		if err := CheckSeverity(severity); err != nil {
+			t.Errorf("Unexpected error checking severity %q: %v", severity, err)
+		}
+	}
+
+	for _, severity := range []string{"", "Error", "page"} {
+		if ValidSeverity(severity) {
+			t.Errorf("Expected severity %q to be invalid", severity)
+		}
+	}
+
+	expected := `unknown severity "page", expected critical, error, warning or info`
+	if err := CheckSeverity("page"); err == nil || err.Error() != expected {
+		t.Errorf("Expected error %q, was %v", expected, err)
+	}
+}
+
+func TestTruncateSummary(t *testing.T) {
+	short := "Disk full on db01"
+	if summary := TruncateSummary(short); summary != short {
+		t.Errorf("Expected short summary to be unchanged, was %q", summary)
+	}
+
+	long := strings.Repeat("a", MaxSummaryLength+10)
+	if summary := TruncateSummary(long); len(summary) != MaxSummaryLength {
+		t.Errorf("Expected summary to be truncated to %v bytes, was %v", MaxSummaryLength, len(summary))
+	}
+
+	// A three byte character straddling the limit is dropped, not split.
+	multibyte := strings.Repeat("a", MaxSummaryLength-1) + "€"
+	summary := TruncateSummary(multibyte)
+	if summary != strings.Repeat("a", MaxSummaryLength-1) || !utf8.ValidString(summary) {
+		t.Errorf("Expected summary to end before the split character, was %v bytes", len(summary))
+	}
+}
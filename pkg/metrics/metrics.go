@@ -0,0 +1,101 @@
+// Package metrics defines the Prometheus metrics recorded across agent
+// packages and served at the server's `/metrics` endpoint.
+package metrics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "pdagent"
+
+var (
+	// SendRequests counts events received at `/send`, by whether they were
+	// accepted or rejected.
+	SendRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "send_requests_total",
+		Help:      "Events received by the server, by result (accepted or rejected).",
+	}, []string{"result"})
+
+	// ReceivedMessages counts messages received by the server's other
+	// receivers, such as syslog, by receiver and what became of them.
+	ReceivedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "received_messages_total",
+		Help:      "Messages received other than via the API, by receiver and result (queued, unmatched, rate_limited, invalid, unauthorized or rejected).",
+	}, []string{"receiver", "result"})
+
+	// EventsProcessed counts queued events reaching a final status, per
+	// routing key as labelled by `RoutingKey`.
+	EventsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_processed_total",
+		Help:      "Queued events reaching a final status, by routing key hash and status.",
+	}, []string{"routing_key_hash", "status"})
+
+	// QueueDepth is the number of events waiting to be sent per routing key,
+	// as labelled by `RoutingKey`.
+	QueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "Events waiting to be sent, by routing key hash.",
+	}, []string{"routing_key_hash"})
+
+	// ServerRequestDuration observes requests to the agent's own API, by
+	// route, method and status code.
+	ServerRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "server_request_duration_seconds",
+		Help:      "Duration of requests to the agent's API, by path, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"path", "method", "code"})
+
+	// APIRequestDuration observes each attempt at a request to PagerDuty's
+	// APIs, by path and status code (or "error").
+	APIRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "Duration of requests to PagerDuty's APIs, by path and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"path", "code"})
+
+	// APIRetries counts retried requests to PagerDuty's APIs, by path.
+	APIRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_retries_total",
+		Help:      "Retried requests to PagerDuty's APIs, by path.",
+	}, []string{"path"})
+
+	// Heartbeats counts heartbeats sent, by whether they succeeded.
+	Heartbeats = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "heartbeats_total",
+		Help:      "Heartbeats sent to PagerDuty, by result (success or failure).",
+	}, []string{"result"})
+
+	// DBSize is the size of the event database, updated when scraped.
+	DBSize = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_size_bytes",
+		Help:      "Size of the event database in bytes.",
+	})
+)
+
+// RoutingKey returns the label identifying a routing key in metrics: the
+// first 12 hex digits of its SHA-256 hash, as routing keys allow anyone to
+// send events.
+func RoutingKey(routingKey string) string {
+	sum := sha256.Sum256([]byte(routingKey))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Handler serves all registered metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,59 @@
+package persistentqueue
+
+import (
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// EnqueueBatch adds several events to the persistent queue in a single
+// transaction, returning their keys in order.
+//
+// Either all events are queued or none are, so events should be validated
+// beforehand; any invalid event fails the whole batch.
+func (q *PersistentQueue) EnqueueBatch(eventContainers []*eventsapi.EventContainer, options ...EventOption) ([]string, error) {
+	events := make([]*Event, len(eventContainers))
+	for i, eventContainer := range eventContainers {
+		event, err := eventContainer.UnmarshalEvent()
+		if err != nil {
+			return nil, err
+		}
+		if err := event.Validate(); err != nil {
+			return nil, err
+		}
+
+		e, err := NewEvent(eventContainer)
+		if err != nil {
+			return nil, err
+		}
+		for _, option := range options {
+			option(e)
+		}
+		events[i] = e
+	}
+
+	// Created under the lock, like `Enqueue`.
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	err := withTx(q.DB, func(tx *eventTx) error {
+		for _, e := range events {
+			if err := e.create(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	q.recordWrite(err)
+	if err != nil {
+		q.logger.Errorf("Failed to create batch of %v events: %v.", len(events), err)
+		return nil, err
+	}
+	q.logger.Infof("Batch of %v events enqueued.", len(events))
+
+	keys := make([]string, len(events))
+	for i, e := range events {
+		keys[i] = e.Key
+		q.publish(TransitionAccepted, e, "")
+		q.dispatch(e)
+	}
+	return keys, nil
+}
@@ -0,0 +1,99 @@
+package persistentqueue
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersistentQueueEnqueueBatch(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	r := newRecorder()
+	q := newOrderingQueue(t, r, OrderingPolicy{})
+	defer q.Shutdown()
+
+	keys, err := q.EnqueueBatch([]*eventsapi.EventContainer{
+		newDedupEventContainer(orderingRoutingKey, "1"),
+		newDedupEventContainer(orderingRoutingKey, "2"),
+		newDedupEventContainer(orderingRoutingKey, "3"),
+	}, WithToken("ci"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.wg.Wait()
+
+	assert.Len(t, keys, 3)
+	assert.Equal(t, []string{"1", "2", "3"}, r.sentKeys())
+	assertStatus(t, q, StatusItem{Success: 3})
+
+	e, err := FindEventByKey(q.Events, keys[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "ci", e.Token)
+
+	// An invalid event fails the whole batch.
+	_, err = q.EnqueueBatch([]*eventsapi.EventContainer{
+		newDedupEventContainer(orderingRoutingKey, "4"),
+		newDedupEventContainer("short", "5"),
+	})
+	assert.Equal(t, eventsapi.ErrInvalidRoutingKey, err)
+	q.wg.Wait()
+	assertStatus(t, q, StatusItem{Success: 3})
+}
+
+func TestPersistentQueueEnqueueBatchBackPressure(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	// Holds events in the EventQueue until released.
+	r := newRecorder()
+	release := make(chan struct{})
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = func(job eventqueue.Job, stop chan bool) {
+		<-release
+		r.process(job, stop)
+	}
+
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	// Together more than the EventQueue's buffer holds for a routing key.
+	var expected []string
+	for batch := 0; batch < 2; batch++ {
+		var eventContainers []*eventsapi.EventContainer
+		for i := 0; i < eventqueue.DefaultBufferSize; i++ {
+			dedupKey := fmt.Sprint(len(expected))
+			expected = append(expected, dedupKey)
+			eventContainers = append(eventContainers, newDedupEventContainer(orderingRoutingKey, dedupKey))
+		}
+		if _, err := q.EnqueueBatch(eventContainers); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := q.Enqueue(newDedupEventContainer(orderingRoutingKey, "last")); err != nil {
+		t.Fatal(err)
+	}
+	expected = append(expected, "last")
+
+	q.mu.Lock()
+	assert.Equal(t, replayKeyLimit, q.inflight[orderingRoutingKey])
+	q.mu.Unlock()
+
+	close(release)
+	if err := q.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, expected, r.sentKeys())
+	assertStatus(t, q, StatusItem{Success: len(expected)})
+}
@@ -0,0 +1,95 @@
+package persistentqueue
+
+import (
+	"encoding/json"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// eventCodec is a storm codec that optionally encrypts the payload of events.
+//
+// When a keyring is configured, `Event.Event` and `Event.ResponseBody` are
+// sealed in an envelope while the remaining (indexed) fields stay readable.
+// Routing keys in particular remain in plain text in events, their indexes,
+// and status counters, as they're needed to order and count events.
+// All other records, and events written without encryption, are plain JSON,
+// so the codec shares the "json" name to remain compatible with existing
+// databases.
+type eventCodec struct {
+	keyring *Keyring
+}
+
+// eventRecord has the same fields as Event, but avoids infinite recursion
+// when embedded below.
+type eventRecord Event
+
+type sealedEventRecord struct {
+	*eventRecord
+	Sealed *envelope `json:",omitempty"`
+}
+
+// sealedFields are the Event fields protected by encryption.
+type sealedFields struct {
+	Event        *eventsapi.EventContainer
+	ResponseBody []byte
+}
+
+func (c eventCodec) Marshal(v interface{}) ([]byte, error) {
+	e, ok := v.(*Event)
+	if !ok || c.keyring == nil {
+		return json.Marshal(v)
+	}
+
+	plaintext, err := json.Marshal(sealedFields{e.Event, e.ResponseBody})
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := c.keyring.seal(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	record := eventRecord(*e)
+	record.Event = nil
+	record.ResponseBody = nil
+
+	return json.Marshal(sealedEventRecord{&record, env})
+}
+
+func (c eventCodec) Unmarshal(b []byte, v interface{}) error {
+	e, ok := v.(*Event)
+	if !ok {
+		return json.Unmarshal(b, v)
+	}
+
+	record := sealedEventRecord{eventRecord: (*eventRecord)(e)}
+	if err := json.Unmarshal(b, &record); err != nil {
+		return err
+	}
+	if record.Sealed == nil {
+		return nil
+	}
+
+	if c.keyring == nil {
+		return ErrEncryptionKeyMissing
+	}
+
+	plaintext, err := c.keyring.open(record.Sealed)
+	if err != nil {
+		return err
+	}
+
+	var fields sealedFields
+	if err := json.Unmarshal(plaintext, &fields); err != nil {
+		return err
+	}
+	e.Event = fields.Event
+	e.ResponseBody = fields.ResponseBody
+
+	return nil
+}
+
+func (c eventCodec) Name() string {
+	return "json"
+}
@@ -0,0 +1,51 @@
+package persistentqueue
+
+import (
+	"context"
+)
+
+// Drain waits until replay has finished and no events are waiting to be sent
+// or in flight, or the context is done, in which case it returns the
+// context's error.
+//
+// Callers should first stop enqueuing new events. Events held behind failed
+// events aren't in flight, so don't prevent the queue from draining.
+func (q *PersistentQueue) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	defer close(done)
+
+	// Wakes the wait below once the context is done.
+	go func() {
+		select {
+		case <-ctx.Done():
+			q.mu.Lock()
+			q.room.Broadcast()
+			q.mu.Unlock()
+		case <-done:
+		}
+	}()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for q.replaying || len(q.backlog) > 0 || len(q.inflight) > 0 {
+		if err := ctx.Err(); err != nil {
+			q.logger.Infof("Stopped draining with %v events in flight.", q.inflightCount())
+			return err
+		}
+		q.room.Wait()
+	}
+
+	q.logger.Info("Drained, no events in flight.")
+	return nil
+}
+
+// inflightCount returns the number of events in flight. Callers must hold
+// `q.mu`.
+func (q *PersistentQueue) inflightCount() int {
+	count := 0
+	for _, c := range q.inflight {
+		count += c
+	}
+	return count
+}
@@ -0,0 +1,84 @@
+package persistentqueue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersistentQueueDrain(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := &heldEventQueue{held: make(chan chan<- eventqueue.Response, 1)}
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	if _, err := q.Enqueue(newTestEventContainer("11863b592c824bfc8989d9cba76abcde")); err != nil {
+		t.Fatal(err)
+	}
+	respChan := <-eq.held
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, q.Drain(ctx))
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		respChan <- eventqueue.Response{}
+	}()
+	assert.Nil(t, q.Drain(context.Background()))
+}
+
+// Events the EventQueue stops before sending remain pending.
+func TestPersistentQueueShutdownLeavesPending(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	started := make(chan bool, 1)
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = func(job eventqueue.Job, stop chan bool) {
+		started <- true
+		<-stop
+		job.ResponseChan <- eventqueue.Response{Error: eventqueue.ErrJobStopped}
+	}
+
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(eq), WithOrdering(OrderingPolicy{OnFailure: OrderingBlock}))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	key1, err := q.Enqueue(newTestEventContainer("11863b592c824bfc8989d9cba76abcde"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key2, err := q.Enqueue(newTestEventContainer("11863b592c824bfc8989d9cba76abcde"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-started
+	if err := q.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := OpenDB(tmpDbFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, key := range []string{key1, key2} {
+		e, err := FindEventByKey(db.From("events"), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, StatusPending, e.Status)
+	}
+}
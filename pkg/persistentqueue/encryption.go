@@ -0,0 +1,214 @@
+package persistentqueue
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const encryptionKeySize = 32
+
+var ErrEncryptionKeyMissing = errors.New("event is encrypted but no encryption key is configured")
+
+var ErrNoEncryptionKeys = errors.New("no encryption keys found")
+
+type ErrUnknownEncryptionKey struct {
+	keyID string
+}
+
+func (e *ErrUnknownEncryptionKey) Error() string {
+	return fmt.Sprintf("event is encrypted with unknown key %v", e.keyID)
+}
+
+// Keyring holds the master keys used for envelope encryption of queued
+// events.
+//
+// New records are always encrypted with the primary key, while any other keys
+// remain available for decrypting records written before a key rotation.
+type Keyring struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// NewKeyring creates a keyring from one or more 32-byte keys, the first of
+// which becomes the primary key.
+func NewKeyring(keys ...[]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoEncryptionKeys
+	}
+
+	k := Keyring{keys: map[string][]byte{}}
+	for i, key := range keys {
+		if len(key) != encryptionKeySize {
+			return nil, fmt.Errorf("encryption key %v must be %v bytes, was %v", i+1, encryptionKeySize, len(key))
+		}
+
+		id := keyID(key)
+		if i == 0 {
+			k.primary = id
+		}
+		k.keys[id] = key
+	}
+
+	return &k, nil
+}
+
+// ParseKeyring parses base64-encoded keys separated by newlines or commas,
+// with the first key being primary. Blank lines and lines starting with `#`
+// are ignored.
+//
+// Rotating keys is then a matter of adding a new key to the start of the list
+// and re-encrypting existing events, after which old keys may be removed.
+func ParseKeyring(r io.Reader) (*Keyring, error) {
+	var keys [][]byte
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		for _, field := range strings.Split(scanner.Text(), ",") {
+			field = strings.TrimSpace(field)
+			if field == "" || strings.HasPrefix(field, "#") {
+				continue
+			}
+
+			key, err := base64.StdEncoding.DecodeString(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid encryption key %v: %v", len(keys)+1, err)
+			}
+			keys = append(keys, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewKeyring(keys...)
+}
+
+// LoadKeyring reads keys from a file if provided, otherwise from the named
+// environment variable. Returns nil if neither is configured.
+func LoadKeyring(file, env string) (*Keyring, error) {
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		return ParseKeyring(strings.NewReader(string(data)))
+	}
+
+	if env != "" {
+		value := os.Getenv(env)
+		if value == "" {
+			return nil, fmt.Errorf("encryption key environment variable %v is empty", env)
+		}
+		return ParseKeyring(strings.NewReader(value))
+	}
+
+	return nil, nil
+}
+
+// PrimaryKeyID returns the ID of the key used for new records.
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primary
+}
+
+// envelope is an encrypted payload along with its data key, itself encrypted
+// with a master key.
+type envelope struct {
+	KeyID      string `json:"kid"`
+	DataKey    []byte `json:"dk"`
+	Ciphertext []byte `json:"ct"`
+}
+
+// seal encrypts plaintext with a new random data key, which is in turn
+// encrypted with the primary key.
+func (k *Keyring) seal(plaintext []byte) (*envelope, error) {
+	dataKey := make([]byte, encryptionKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := gcmSeal(dataKey, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := gcmSeal(k.keys[k.primary], dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &envelope{
+		KeyID:      k.primary,
+		DataKey:    wrappedKey,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// open decrypts an envelope created by seal with any key in the keyring.
+func (k *Keyring) open(env *envelope) ([]byte, error) {
+	masterKey, ok := k.keys[env.KeyID]
+	if !ok {
+		return nil, &ErrUnknownEncryptionKey{env.KeyID}
+	}
+
+	dataKey, err := gcmOpen(masterKey, env.DataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return gcmOpen(dataKey, env.Ciphertext)
+}
+
+// gcmSeal encrypts with AES-GCM, prefixing the result with a random nonce.
+func gcmSeal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// gcmOpen decrypts a nonce-prefixed AES-GCM ciphertext from gcmSeal.
+func gcmOpen(key, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("encrypted data is too short")
+	}
+
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// keyID returns a short, non-secret fingerprint identifying a key.
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
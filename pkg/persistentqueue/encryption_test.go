@@ -0,0 +1,108 @@
+package persistentqueue
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestKey(t *testing.T) []byte {
+	key := make([]byte, encryptionKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func startEncryptedQueue(t *testing.T, keys ...[]byte) *PersistentQueue {
+	var keyring *Keyring
+	if len(keys) > 0 {
+		var err error
+		if keyring, err = NewKeyring(keys...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(NewMockEventQueue()), WithKeyring(keyring))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func TestEncryptionAtRest(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	rk := "11863b592c824bfc8989d9cba76abcde"
+	oldKey := newTestKey(t)
+	newKey := newTestKey(t)
+
+	q := startEncryptedQueue(t, oldKey)
+	key, err := q.Enqueue(newTestEventContainer(rk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = q.Shutdown()
+
+	raw, err := ioutil.ReadFile(tmpDbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, bytes.Contains(raw, []byte("PagerDuty Agent Test")), "Expected event payload to be encrypted on disk.")
+
+	// Without the key, the payload can't be read.
+	q = startEncryptedQueue(t)
+	_, err = FindEventByKey(q.Events, key)
+	assert.Equal(t, ErrEncryptionKeyMissing, err)
+	_ = q.Shutdown()
+
+	// Rotate to a new primary key, keeping the old one to re-encrypt.
+	q = startEncryptedQueue(t, newKey, oldKey)
+	count, err := q.Reencrypt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, count)
+	_ = q.Shutdown()
+
+	// With only the new key, the event should still be readable.
+	q = startEncryptedQueue(t, newKey)
+	defer q.Shutdown()
+	q.wg.Wait()
+
+	e, err := FindEventByKey(q.Events, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, rk, e.RoutingKey)
+	assert.Equal(t, StatusSuccess, e.Status)
+
+	event, err := e.Event.UnmarshalEvent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, rk, event.GetRoutingKey())
+}
+
+func TestParseKeyring(t *testing.T) {
+	keyring, err := ParseKeyring(bytes.NewBufferString(`
+		# Newest key first.
+		MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=
+		ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, keyID([]byte("0123456789abcdef0123456789abcdef")), keyring.PrimaryKeyID())
+	assert.Len(t, keyring.keys, 2)
+
+	_, err = ParseKeyring(bytes.NewBufferString("dG9vIHNob3J0"))
+	assert.Error(t, err)
+
+	_, err = ParseKeyring(bytes.NewBufferString(""))
+	assert.Equal(t, ErrNoEncryptionKeys, err)
+}
@@ -1,10 +1,29 @@
 package persistentqueue
 
 import (
+	"encoding/json"
+	"time"
+
 	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/metrics"
 )
 
+// Enqueuer is where receivers of alerts from other sources queue events.
+type Enqueuer interface {
+	Enqueue(*eventsapi.EventContainer, ...EventOption) (string, error)
+}
+
+// EnqueueEvent wraps an event in a container and adds it to a queue,
+// returning the event record's key.
+func EnqueueEvent(q Enqueuer, event eventsapi.Event, options ...EventOption) (string, error) {
+	eventContainer, err := eventsapi.NewEventContainer(event)
+	if err != nil {
+		return "", err
+	}
+	return q.Enqueue(eventContainer, options...)
+}
+
 // Enqueue adds an event to the persistent queue for processing.
 //
 // Returns the event record's key along with any synchronous errors.
@@ -12,7 +31,7 @@ import (
 // Only synchronous errors (e.g. invalid event) are supported as there are
 // cases where we might not have a per-event response channel (e.g. processing
 // a backlog).
-func (q *PersistentQueue) Enqueue(eventContainer *eventsapi.EventContainer) (string, error) {
+func (q *PersistentQueue) Enqueue(eventContainer *eventsapi.EventContainer, options ...EventOption) (string, error) {
 	event, err := eventContainer.UnmarshalEvent()
 	if err != nil {
 		q.logger.Errorf("Failed to unmarshal event container in queue", err)
@@ -28,46 +47,205 @@ func (q *PersistentQueue) Enqueue(eventContainer *eventsapi.EventContainer) (str
 	if err != nil {
 		return "", err
 	}
+	for _, option := range options {
+		option(e)
+	}
 	q.logger.Infof("Enqueuing to %v with key %v.", event.GetRoutingKey(), e.Key)
 
-	if err := e.Create(q.Events); err != nil {
+	// Created under the lock, so that events are dispatched in the order of
+	// their IDs.
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	err = e.Create(q.DB)
+	q.recordWrite(err)
+	if err != nil {
 		q.logger.Errorf("Failed to create event %v: %v.", e.Key, err)
 		return e.Key, err
 	}
 	q.logger.Infof("Event enqueued with key %v, ID %v.", e.Key, e.ID)
-
-	q.processEvent(e)
+	q.publish(TransitionAccepted, e, "")
+	q.dispatch(e)
 
 	return e.Key, nil
 }
 
-func (q *PersistentQueue) processEvent(e *Event) {
+// dispatch sends a newly created event, unless replay will send it. Callers
+// must hold `q.mu`.
+func (q *PersistentQueue) dispatch(e *Event) {
+	if q.replaying {
+		// Sent by replay in turn, after any older pending events.
+		q.progress.Total++
+		return
+	} else if e.ID <= q.replayedThrough {
+		// Already sent by replay before it finished.
+		return
+	}
+	q.admit(e)
+}
+
+// admit sends an event, or backlogs it. Callers must hold `q.mu`.
+//
+// Like replay, events wait for room if their routing key has too many events
+// in flight, e.g. after a large batch or retry, so that the EventQueue's
+// buffer doesn't overflow. Later events wait behind them to keep their order.
+func (q *PersistentQueue) admit(e *Event) {
+	if len(q.backlog) > 0 || q.inflight[e.RoutingKey] >= replayKeyLimit {
+		q.backlog = append(q.backlog, e)
+		if len(q.backlog) == 1 {
+			q.wg.Add(1)
+			go q.feed()
+		}
+		return
+	}
+	q.send(e)
+}
+
+// feed sends backlogged events in order as their routing keys have room.
+//
+// Events still backlogged when the queue stops remain pending, to be
+// replayed once it next starts.
+func (q *PersistentQueue) feed() {
+	defer q.wg.Done()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.backlog) > 0 {
+		e := q.backlog[0]
+		for q.inflight[e.RoutingKey] >= replayKeyLimit && !q.stopping {
+			q.room.Wait()
+		}
+		if q.stopping {
+			q.logger.Infof("Stopped with %v events waiting to be sent, leaving them pending.", len(q.backlog))
+			q.backlog = nil
+			return
+		}
+
+		q.backlog = q.backlog[1:]
+		q.send(e)
+	}
+
+	// Wakes `Drain`, in case the last events were held rather than sent.
+	q.room.Broadcast()
+}
+
+// send dispatches an event to the EventQueue, updating its status once
+// processed.
+//
+// Callers must hold `q.mu`, ensuring events are sequenced within their
+// ordering scope in the same order they're enqueued.
+func (q *PersistentQueue) send(e *Event) {
+	var options []eventqueue.JobOption
+	if deadline := q.expiry.deadline(e); !deadline.IsZero() {
+		options = append(options, eventqueue.WithDeadline(deadline))
+	}
+
+	var d *dispatch
+	if q.ordering.blocking() {
+		if d = q.sequence(e); d == nil {
+			return
+		}
+		options = append(options, eventqueue.WithPrecondition(d.wait))
+	}
+
 	q.wg.Add(1)
+	if len(q.inflight) == 0 {
+		q.lastProgress = time.Now()
+	}
+	q.inflight[e.RoutingKey]++
 	respChan := make(chan eventqueue.Response)
 
-	// Ignoring error -- currently only occurs if event fails validation, which
-	// we check in Enqueue.
-	q.logger.Infof("Enqueuing %v with EventQueue.", e.Key)
-	_ = q.EventQueue.Enqueue(e.Event, respChan)
-
 	go func() {
+		defer q.wg.Done()
+		defer q.release(e.RoutingKey)
+
 		q.logger.Debugf("Waiting for response for %v.", e.Key)
 		resp := <-respChan
 		q.logger.Debugf("Received response for %v.", e.Key)
 
-		if resp.Error != nil {
+		if resp.Error == ErrEventBlocked {
+			// Remains pending until the failed event is retried or skipped.
+			q.settle(e, d, resp.Error)
+			return
+		} else if resp.Error == eventqueue.ErrJobStopped {
+			// Remains pending, to be replayed once the queue next starts.
+			q.logger.Infof("EventQueue stopped before sending %v, leaving it pending.", e.Key)
+			if d != nil {
+				q.settle(e, d, resp.Error)
+			}
+			return
+		}
+
+		transition := TransitionSucceeded
+		if resp.Error == eventqueue.ErrJobExpired {
+			e.Status = StatusExpired
+			transition = TransitionExpired
+			q.logger.Infof("EventQueue expired %v before it was sent.", e.Key)
+		} else if resp.Error != nil {
 			e.Status = StatusError
+			transition = TransitionFailed
 			q.logger.Infof("EventQueue returned error for %v: %v, %+v", e.Key, resp.Error, resp.Response)
 		} else {
 			e.Status = StatusSuccess
 			q.logger.Infof("EventQueue returned success for %v. ", e.Key)
 		}
 
-		err := e.Update(q.Events)
+		if resp.Response != nil {
+			// Kept so that callers waiting on the event can see the response.
+			e.ResponseBody, _ = json.Marshal(resp.Response)
+		}
+
+		err := e.Update(q.DB)
+		q.mu.Lock()
+		q.recordWrite(err)
+		q.mu.Unlock()
 		if err != nil {
 			q.logger.Error(err)
 		}
 		q.logger.Infof("Set status of %v to %v.", e.Key, e.Status)
-		q.wg.Done()
+		metrics.EventsProcessed.WithLabelValues(metrics.RoutingKey(e.RoutingKey), e.Status).Inc()
+		q.recordOutcome(e.Status)
+		q.notify(e)
+		q.publish(transition, e, responseSummary(resp))
+
+		if d != nil {
+			// Expired events don't block those after them.
+			if resp.Error == eventqueue.ErrJobExpired {
+				resp.Error = nil
+			}
+			q.settle(e, d, resp.Error)
+		}
 	}()
+
+	// Ignoring error -- currently only occurs if event fails validation, which
+	// we check in Enqueue.
+	q.logger.Infof("Enqueuing %v with EventQueue.", e.Key)
+	q.publish(TransitionDispatched, e, "")
+	_ = q.EventQueue.Enqueue(e.Event, respChan, options...)
+}
+
+// release frees an in-flight slot for the routing key once an event has been
+// processed.
+func (q *PersistentQueue) release(routingKey string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.inflight[routingKey]--; q.inflight[routingKey] <= 0 {
+		delete(q.inflight, routingKey)
+	}
+	q.room.Broadcast()
+}
+
+// expire marks a pending event as expired without sending it.
+func (q *PersistentQueue) expire(e *Event) {
+	e.Status = StatusExpired
+	if err := e.Update(q.DB); err != nil {
+		q.logger.Error(err)
+		return
+	}
+	q.logger.Infof("Expired %v, created at %v.", e.Key, e.CreatedAt)
+	metrics.EventsProcessed.WithLabelValues(metrics.RoutingKey(e.RoutingKey), e.Status).Inc()
+	q.notify(e)
+	q.publish(TransitionExpired, e, "")
 }
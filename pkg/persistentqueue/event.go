@@ -1,27 +1,48 @@
 package persistentqueue
 
 import (
+	"encoding/binary"
 	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/common"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
 	"github.com/asdine/storm"
+	"github.com/asdine/storm/index"
+	bolt "go.etcd.io/bbolt"
 )
 
 const StatusPending = "pending"
 const StatusError = "error"
 const StatusSuccess = "success"
+const StatusExpired = "expired"
+const StatusSkipped = "skipped"
 
 // Event represents an queued or processed event.
+//
+// RoutingKey and Status are indexed by `create` and `update` rather than
+// storm, see `index`.
 type Event struct {
 	ID           int    `storm:"id,increment"`
 	Key          string `storm:"index"`
-	RoutingKey   string `storm:"index"`
-	Status       string `storm:"index"`
+	RoutingKey   string
+	Status       string
 	Event        *eventsapi.EventContainer
 	ResponseBody []byte
 	CreatedAt    time.Time `storm:"index"`
 	UpdatedAt    time.Time `storm:"index"`
+
+	// Name of the API token the event was sent with, if any.
+	Token string `json:",omitempty"`
+}
+
+// EventOption sets additional details of an event when it's enqueued.
+type EventOption func(*Event)
+
+// WithToken records the name of the API token an event was sent with.
+func WithToken(name string) EventOption {
+	return func(e *Event) {
+		e.Token = name
+	}
 }
 
 func NewEvent(eventContainer *eventsapi.EventContainer) (*Event, error) {
@@ -40,21 +61,112 @@ func NewEvent(eventContainer *eventsapi.EventContainer) (*Event, error) {
 	}, nil
 }
 
-// Create an event within the specified Queue.
+// Create an event within the specified database.
 //
-// Main convenience is ensuring that CreatedAt and UpdatedAt are set.
-func (e *Event) Create(db storm.Node) error {
+// Main convenience is ensuring that CreatedAt and UpdatedAt are set. The
+// event's indexes and status counter are updated within the same transaction.
+func (e *Event) Create(db *storm.DB) error {
+	return withTx(db, e.create)
+}
+
+func (e *Event) create(tx *eventTx) error {
 	e.CreatedAt = time.Now()
 	e.UpdatedAt = e.CreatedAt
-	return db.Save(e)
+	if err := tx.Save(e); err != nil {
+		return err
+	}
+	if err := e.index(tx); err != nil {
+		return err
+	}
+	return adjustCounter(tx, e.RoutingKey, e.Status, 1)
 }
 
-// Update an event within the specified Queue.
+// Update an event within the specified database.
 //
-// Main convenience is ensuring that UpdatedAt is updated.
-func (e *Event) Update(db storm.Node) error {
+// Main convenience is ensuring that UpdatedAt is updated. If the event's
+// status changed, its indexes and status counters are moved within the same
+// transaction.
+func (e *Event) Update(db *storm.DB) error {
+	return withTx(db, e.update)
+}
+
+func (e *Event) update(tx *eventTx) error {
+	var prev Event
+	if err := tx.One("ID", e.ID, &prev); err != nil {
+		return err
+	}
+
 	e.UpdatedAt = time.Now()
-	return db.Update(e)
+	if err := tx.Update(e); err != nil {
+		return err
+	}
+
+	if prev.Status == e.Status {
+		return nil
+	}
+	if err := e.index(tx); err != nil {
+		return err
+	}
+	if err := adjustCounter(tx, prev.RoutingKey, prev.Status, -1); err != nil {
+		return err
+	}
+	return adjustCounter(tx, e.RoutingKey, e.Status, 1)
+}
+
+// index adds the event to the RoutingKey and Status indexes, moving it from
+// any previous value.
+//
+// Storm checks every event indexed under a value each time it saves one to
+// that index, making saves slower as events with the same status or routing
+// key accumulate. These are instead maintained here, in the same layout as
+// storm's list indexes, at the cost of a lookup per index.
+func (e *Event) index(tx *eventTx) error {
+	bucket := tx.GetBucket(tx.tx, "Event")
+	if bucket == nil {
+		return storm.ErrNotFound
+	}
+
+	id := make([]byte, 8)
+	binary.BigEndian.PutUint64(id, uint64(e.ID))
+
+	for _, field := range []struct{ name, value string }{
+		{"RoutingKey", e.RoutingKey},
+		{"Status", e.Status},
+	} {
+		idx, err := index.NewListIndex(bucket, []byte(stormIndexPrefix+field.name))
+		if err != nil {
+			return err
+		}
+
+		// Removed first as storm does, since adding reuses the previous
+		// entry's key, which belongs to bolt. Like storm, events are left out
+		// of indexes for empty values.
+		if err := idx.RemoveID(id); err != nil {
+			return err
+		}
+		if field.value == "" {
+			continue
+		}
+		if err := idx.Add([]byte(field.value), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventTx is the events node within a writable transaction, along with the
+// transaction itself for indexes maintained outside of storm.
+type eventTx struct {
+	storm.Node
+	tx *bolt.Tx
+}
+
+// withTx runs fn within a single writable transaction on db's events,
+// committing on success and rolling back on error.
+func withTx(db *storm.DB, fn func(*eventTx) error) error {
+	return db.Bolt.Update(func(tx *bolt.Tx) error {
+		return fn(&eventTx{db.WithTransaction(tx).From("events"), tx})
+	})
 }
 
 func FindEventByKey(db storm.Node, key string) (*Event, error) {
@@ -38,7 +38,7 @@ func TestEvent(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err = event.Create(eventsDb); err != nil {
+	if err = event.Create(db); err != nil {
 		t.Fatal(err)
 	}
 
@@ -53,7 +53,7 @@ func TestEvent(t *testing.T) {
 
 	event.Status = StatusSuccess
 
-	if err = event.Update(eventsDb); err != nil {
+	if err = event.Update(db); err != nil {
 		t.Fatal(err)
 	}
 
@@ -0,0 +1,88 @@
+package persistentqueue
+
+import (
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// ExpiryRule defines how long a pending event may wait before it's no longer
+// worth sending, optionally varying by severity. A zero duration means events
+// never expire.
+type ExpiryRule struct {
+	MaxAge     time.Duration            `mapstructure:"max_age"`
+	Severities map[string]time.Duration `mapstructure:"severities"`
+}
+
+// ExpiryPolicy determines when pending events are moved to `StatusExpired`
+// rather than being sent.
+//
+// Rules are resolved from most to least specific: the routing key's severity,
+// the routing key's max age, the global severity, then the global max age.
+// Routing keys are matched case-insensitively, as configuration keys are
+// normally lowercased when read.
+type ExpiryPolicy struct {
+	ExpiryRule  `mapstructure:",squash"`
+	RoutingKeys map[string]ExpiryRule `mapstructure:"routing_keys"`
+}
+
+// MaxAgeFor returns the maximum age of an event for the given routing key and
+// severity, or zero if events never expire.
+func (p *ExpiryPolicy) MaxAgeFor(routingKey, severity string) time.Duration {
+	for k, rule := range p.RoutingKeys {
+		if !strings.EqualFold(k, routingKey) {
+			continue
+		}
+		if maxAge, ok := rule.Severities[severity]; ok {
+			return maxAge
+		}
+		if rule.MaxAge > 0 {
+			return rule.MaxAge
+		}
+	}
+
+	if maxAge, ok := p.Severities[severity]; ok {
+		return maxAge
+	}
+	return p.MaxAge
+}
+
+// deadline returns the time after which the event expires, or the zero time
+// if it never does.
+func (p *ExpiryPolicy) deadline(e *Event) time.Time {
+	maxAge := p.MaxAgeFor(e.RoutingKey, eventSeverity(e.Event))
+	if maxAge <= 0 {
+		return time.Time{}
+	}
+	return e.CreatedAt.Add(maxAge)
+}
+
+// expired returns true if the event is past its deadline.
+func (p *ExpiryPolicy) expired(e *Event) bool {
+	deadline := p.deadline(e)
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
+
+// SetExpiry replaces the policy for expiring stale pending events while the
+// queue is running. Events already passed to the EventQueue keep their
+// existing deadlines.
+func (q *PersistentQueue) SetExpiry(policy ExpiryPolicy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expiry = policy
+}
+
+// eventSeverity returns the severity of V2 events, or an empty string for V1
+// events which have no equivalent.
+func eventSeverity(eventContainer *eventsapi.EventContainer) string {
+	event, err := eventContainer.UnmarshalEvent()
+	if err != nil {
+		return ""
+	}
+
+	if e, ok := event.(*eventsapi.EventV2); ok {
+		return e.Payload.Severity
+	}
+	return ""
+}
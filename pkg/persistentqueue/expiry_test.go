@@ -0,0 +1,84 @@
+package persistentqueue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiryPolicyMaxAgeFor(t *testing.T) {
+	policy := ExpiryPolicy{
+		ExpiryRule: ExpiryRule{
+			MaxAge:     24 * time.Hour,
+			Severities: map[string]time.Duration{"info": time.Hour},
+		},
+		RoutingKeys: map[string]ExpiryRule{
+			"abcdef": {
+				MaxAge:     2 * time.Hour,
+				Severities: map[string]time.Duration{"critical": 48 * time.Hour},
+			},
+		},
+	}
+
+	assert.Equal(t, 48*time.Hour, policy.MaxAgeFor("ABCDEF", "critical"))
+	assert.Equal(t, 2*time.Hour, policy.MaxAgeFor("abcdef", "info"))
+	assert.Equal(t, time.Hour, policy.MaxAgeFor("other", "info"))
+	assert.Equal(t, 24*time.Hour, policy.MaxAgeFor("other", "error"))
+	assert.Equal(t, time.Duration(0), (&ExpiryPolicy{}).MaxAgeFor("other", "error"))
+}
+
+func TestExpiryOnStart(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	rk := "11863b592c824bfc8989d9cba76abcde"
+
+	db, err := storm.Open(tmpDbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale, _ := NewEvent(newTestEventContainer(rk))
+	fresh, _ := NewEvent(newTestEventContainer(rk))
+	if err := stale.Create(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := fresh.Create(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Create always stamps the current time, so backdate after the fact.
+	if err := db.From("events").UpdateField(stale, "CreatedAt", time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	policy := ExpiryPolicy{ExpiryRule: ExpiryRule{MaxAge: time.Hour}}
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(NewMockEventQueue()), WithExpiry(policy))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	q.wg.Wait()
+
+	e, err := FindEventByKey(q.Events, stale.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, StatusExpired, e.Status)
+
+	e, err = FindEventByKey(q.Events, fresh.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, StatusSuccess, e.Status)
+
+	items, err := q.Status(rk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []StatusItem{{RoutingKey: rk, Success: 1, Expired: 1}}, items)
+}
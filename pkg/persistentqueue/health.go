@@ -0,0 +1,119 @@
+package persistentqueue
+
+import (
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Health is a snapshot of the queue's state for health checks.
+type Health struct {
+	// Started is true once the queue has started, until it shuts down.
+	Started bool
+
+	// DBError is the error encountered writing to the database, if any.
+	DBError error
+
+	Replay ReplayProgress
+
+	// OldestPending is when the oldest pending event was created, or zero if
+	// none are pending.
+	OldestPending time.Time
+
+	// Inflight is the number of events sent to the EventQueue awaiting a
+	// response, and LastProgress when a response was last received (or when
+	// events were first in flight since then).
+	Inflight     int
+	LastProgress time.Time
+
+	// LastSuccess and LastFailure are when events were last sent
+	// successfully, and last failed to send.
+	LastSuccess time.Time
+	LastFailure time.Time
+
+	// Blocked lists ordering scopes holding events behind failed events.
+	Blocked []string
+}
+
+// How long health checks rely on the outcome of the last write to the
+// database, before writing to it themselves.
+const dbProbeInterval = time.Minute
+
+// Health reports on the queue's state, checking its database is writable.
+func (q *PersistentQueue) Health() Health {
+	q.mu.Lock()
+	h := Health{
+		Started:      q.DB != nil && !q.stopping,
+		Replay:       q.progress,
+		LastProgress: q.lastProgress,
+		LastSuccess:  q.lastSuccess,
+		LastFailure:  q.lastFailure,
+	}
+	h.Inflight = q.inflightCount()
+	for key, s := range q.scopes {
+		if s.blocked {
+			h.Blocked = append(h.Blocked, key)
+		}
+	}
+	q.mu.Unlock()
+
+	sort.Strings(h.Blocked)
+
+	if !h.Started {
+		return h
+	}
+
+	h.DBError = q.writeError()
+
+	if oldest, err := q.pendingAfter(0, 1); err != nil {
+		h.DBError = err
+	} else if len(oldest) > 0 {
+		h.OldestPending = oldest[0].CreatedAt
+	}
+
+	return h
+}
+
+// recordOutcome tracks when events were last processed, for health checks.
+func (q *PersistentQueue) recordOutcome(status string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	q.lastProgress = now
+	switch status {
+	case StatusSuccess:
+		q.lastSuccess = now
+	case StatusError:
+		q.lastFailure = now
+	}
+}
+
+// recordWrite tracks the outcome of writing to the database, for health
+// checks. Callers must hold `q.mu`.
+func (q *PersistentQueue) recordWrite(err error) {
+	q.lastWrite = time.Now()
+	q.lastWriteErr = err
+}
+
+// writeError returns the error from the last write to the database, if any.
+// When nothing's been written recently, the database is probed first.
+func (q *PersistentQueue) writeError() error {
+	q.mu.Lock()
+	recent := time.Since(q.lastWrite) < dbProbeInterval
+	err := q.lastWriteErr
+	q.mu.Unlock()
+
+	if recent {
+		return err
+	}
+
+	// An empty transaction still commits, so fails if the database can't be
+	// written.
+	err = q.DB.Bolt.Update(func(tx *bolt.Tx) error { return nil })
+	q.mu.Lock()
+	q.recordWrite(err)
+	q.mu.Unlock()
+	return err
+}
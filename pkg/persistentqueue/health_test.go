@@ -0,0 +1,64 @@
+package persistentqueue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestPersistentQueueHealth(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	r := newRecorder("1")
+	q := newOrderingQueue(t, r, OrderingPolicy{OnFailure: OrderingBlock})
+
+	enqueueAll(t, q, "1", "2")
+
+	h := q.Health()
+	assert.True(t, h.Started)
+	assert.NoError(t, h.DBError)
+	assert.Equal(t, 0, h.Inflight)
+	assert.False(t, h.OldestPending.IsZero())
+	assert.False(t, h.LastFailure.IsZero())
+	assert.True(t, h.LastSuccess.IsZero())
+	assert.Equal(t, []string{orderingRoutingKey}, h.Blocked)
+
+	// Checks rely on recent writes rather than writing themselves.
+	txID := func() int {
+		var id int
+		_ = q.DB.Bolt.View(func(tx *bolt.Tx) error {
+			id = tx.ID()
+			return nil
+		})
+		return id
+	}
+	before := txID()
+	q.Health()
+	assert.Equal(t, before, txID())
+
+	q.mu.Lock()
+	q.lastWrite = time.Now().Add(-dbProbeInterval)
+	q.mu.Unlock()
+	assert.NoError(t, q.Health().DBError)
+	assert.Equal(t, before+1, txID())
+	q.Health()
+	assert.Equal(t, before+1, txID())
+
+	if _, err := q.Skip(orderingRoutingKey); err != nil {
+		t.Fatal(err)
+	}
+	q.wg.Wait()
+
+	h = q.Health()
+	assert.True(t, h.OldestPending.IsZero())
+	assert.False(t, h.LastSuccess.IsZero())
+	assert.Empty(t, h.Blocked)
+
+	if err := q.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, q.Health().Started)
+}
@@ -1,8 +1,10 @@
 package persistentqueue
 
 import (
+	"fmt"
 	"os"
 	"path"
+	"path/filepath"
 	"testing"
 
 	"github.com/PagerDuty/go-pdagent/pkg/common"
@@ -16,6 +18,12 @@ const tmpDir = "../../tmp"
 
 var tmpDbFile = path.Join(tmpDir, "test.db")
 
+func TestMain(m *testing.M) {
+	code := m.Run()
+	_ = os.Remove(benchmarkDbFile)
+	os.Exit(code)
+}
+
 type MockEventQueue struct {
 	logger *zap.SugaredLogger
 }
@@ -30,7 +38,7 @@ func (q *MockEventQueue) Shutdown() {
 	q.logger.Debug("Shutdown called.")
 }
 
-func (q *MockEventQueue) Enqueue(_ *eventsapi.EventContainer, c chan<- eventqueue.Response) error {
+func (q *MockEventQueue) Enqueue(_ *eventsapi.EventContainer, c chan<- eventqueue.Response, _ ...eventqueue.JobOption) error {
 	q.logger.Debug("Enqueue called.")
 	go func() {
 		q.logger.Debug("Response sent called.")
@@ -42,7 +50,7 @@ func (q *MockEventQueue) Enqueue(_ *eventsapi.EventContainer, c chan<- eventqueu
 }
 
 // Clean up any existing tmp directory contents and create if necessary.
-func setup(t *testing.T) {
+func setup(t testing.TB) {
 	if err := os.RemoveAll(tmpDbFile); err != nil {
 		t.Fatal(err)
 	}
@@ -55,8 +63,32 @@ func setup(t *testing.T) {
 // Clean up any leftover tmp files.
 //
 // Useful to comment out when troubleshooting DB issues.
-func teardown(t *testing.T) {
+func teardown(t testing.TB) {
 	if err := os.RemoveAll(tmpDbFile); err != nil {
 		t.Fatal(err)
 	}
+
+	backups, _ := filepath.Glob(tmpDbFile + ".*.bak")
+	for _, backup := range backups {
+		if err := os.Remove(backup); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newTestEventContainer(routingKey string) *eventsapi.EventContainer {
+	return &eventsapi.EventContainer{
+		EventVersion: eventsapi.EventVersion2,
+		EventData: []byte(fmt.Sprintf(`
+			{
+				"routing_key":  "%v",
+				"event_action": "trigger",
+				"payload": {
+					"summary":  "PagerDuty Agent Test",
+					"source":   "pdagent",
+					"severity": "error"
+				}
+			}
+		`, routingKey)),
+	}
 }
@@ -0,0 +1,393 @@
+package persistentqueue
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/codec"
+	"github.com/asdine/storm/index"
+	bolt "go.etcd.io/bbolt"
+)
+
+const compactTxMaxSize = 64 * 1024 * 1024
+
+const (
+	ProblemCorrupt  = "corrupt"
+	ProblemIndex    = "index"
+	ProblemOrphaned = "orphaned"
+	ProblemCounter  = "counter"
+)
+
+// Storm's prefix for index buckets, used when inspecting the raw database.
+const stormIndexPrefix = "__storm_index_"
+
+// eventIndexes lists each indexed Event field along with its value.
+var eventIndexes = []struct {
+	name  string
+	value func(*Event) interface{}
+}{
+	{"Key", func(e *Event) interface{} { return e.Key }},
+	{"RoutingKey", func(e *Event) interface{} { return e.RoutingKey }},
+	{"Status", func(e *Event) interface{} { return e.Status }},
+	{"CreatedAt", func(e *Event) interface{} { return e.CreatedAt }},
+	{"UpdatedAt", func(e *Event) interface{} { return e.UpdatedAt }},
+}
+
+// Backup writes a consistent copy of the database to the given path, which
+// must not already exist, and returns the path written.
+//
+// Safe to use while the database is in use.
+func Backup(db *storm.DB, path string) (string, error) {
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("backup file already exists: %v", path)
+	}
+
+	err := db.Bolt.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(path, 0600)
+	})
+	return path, err
+}
+
+// Backup writes a consistent copy of the queue's database while it runs.
+//
+// If no path is provided the backup is written alongside the database.
+func (q *PersistentQueue) Backup(path string) (string, error) {
+	if path == "" {
+		path = fmt.Sprintf("%v.%v.bak", q.path, time.Now().UTC().Format("20060102T150405Z"))
+	}
+
+	q.logger.Infof("Backing up database to %v.", path)
+	return Backup(q.DB, path)
+}
+
+// Size returns the size of the queue's database file in bytes.
+func (q *PersistentQueue) Size() (int64, error) {
+	return fileSize(q.path)
+}
+
+// Compact rewrites the database at path to reclaim space left by deleted
+// records, returning its size before and after.
+//
+// The database must not be in use. A compacted copy is first written
+// alongside the original, then moved into its place.
+func Compact(path string) (int64, int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	before := info.Size()
+
+	src, err := bolt.Open(path, 0600, &bolt.Options{Timeout: openTimeout, ReadOnly: true})
+	if err != nil {
+		return 0, 0, err
+	}
+	defer src.Close()
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.compact")
+	if err != nil {
+		return 0, 0, err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	dst, err := bolt.Open(tmpPath, 0600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := compactInto(dst, src); err != nil {
+		dst.Close()
+		return 0, 0, err
+	}
+	if err := dst.Close(); err != nil {
+		return 0, 0, err
+	}
+	src.Close()
+
+	// Keep the original's owner and mode, so that compacting as another user
+	// (e.g. root) doesn't leave a database the agent can't open.
+	if err := chownLike(tmpPath, info); err != nil {
+		return 0, 0, err
+	}
+	if err := os.Chmod(tmpPath, info.Mode().Perm()); err != nil {
+		return 0, 0, err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return 0, 0, err
+	}
+
+	after, err := fileSize(path)
+	return before, after, err
+}
+
+// compactInto copies every bucket and key from src to dst, committing
+// periodically to bound memory usage.
+func compactInto(dst, src *bolt.DB) error {
+	var size int64
+
+	tx, err := dst.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = src.View(func(srcTx *bolt.Tx) error {
+		return srcTx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return copyBucket(b, nil, name, func(path [][]byte, k, v []byte, seq uint64) error {
+				// Commit once the transaction reaches its limit.
+				if size+int64(len(k)+len(v)) > compactTxMaxSize {
+					if err := tx.Commit(); err != nil {
+						return err
+					}
+					next, err := dst.Begin(true)
+					if err != nil {
+						return err
+					}
+					tx = next
+					size = 0
+				}
+				size += int64(len(k) + len(v))
+
+				b, err := tx.CreateBucketIfNotExists(path[0])
+				if err != nil {
+					return err
+				}
+				for _, name := range path[1:] {
+					if b, err = b.CreateBucketIfNotExists(name); err != nil {
+						return err
+					}
+				}
+
+				if k == nil {
+					return b.SetSequence(seq)
+				}
+				return b.Put(k, v)
+			})
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// copyBucket walks a bucket recursively, calling fn with the bucket's path
+// for the bucket itself (with a nil key and its sequence) and each of its
+// keys.
+func copyBucket(b *bolt.Bucket, parent [][]byte, name []byte, fn func([][]byte, []byte, []byte, uint64) error) error {
+	path := append(append([][]byte{}, parent...), name)
+	if err := fn(path, nil, nil, b.Sequence()); err != nil {
+		return err
+	}
+
+	return b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			return copyBucket(b.Bucket(k), path, k, fn)
+		}
+		return fn(path, k, v, b.Sequence())
+	})
+}
+
+// CheckProblem describes a single issue found while checking a database.
+type CheckProblem struct {
+	Kind    string `json:"kind"`
+	ID      int    `json:"id,omitempty"`
+	Message string `json:"message"`
+}
+
+// CheckReport summarizes the results of checking a database.
+type CheckReport struct {
+	Events   int            `json:"events"`
+	Problems []CheckProblem `json:"problems"`
+}
+
+func (r *CheckReport) add(kind string, id int, format string, args ...interface{}) {
+	r.Problems = append(r.Problems, CheckProblem{kind, id, fmt.Sprintf(format, args...)})
+}
+
+// Check verifies the integrity of the event database without modifying it.
+//
+// Every event record must decode as an Event containing a valid
+// EventContainer, each index must reference exactly the events with that
+// value, and status counters must match the events they count.
+func Check(db *storm.DB) (*CheckReport, error) {
+	report := CheckReport{}
+	dbCodec := db.Codec()
+
+	err := db.Bolt.View(func(tx *bolt.Tx) error {
+		eventsBucket := tx.Bucket([]byte("events"))
+		if eventsBucket == nil {
+			return nil
+		}
+		bucket := eventsBucket.Bucket([]byte("Event"))
+		if bucket == nil {
+			return nil
+		}
+
+		indexes := map[string]*index.ListIndex{}
+		for _, field := range eventIndexes {
+			idx, err := index.NewListIndex(bucket, []byte(stormIndexPrefix+field.name))
+			if err == index.ErrNotFound {
+				continue
+			} else if err != nil {
+				return err
+			}
+			indexes[field.name] = idx
+		}
+
+		counts := map[string]map[string]int{}
+		ids := map[string]bool{}
+
+		err := bucket.ForEach(func(k, v []byte) error {
+			// Nested buckets hold storm's indexes and metadata.
+			if v == nil {
+				return nil
+			}
+			ids[string(k)] = true
+			report.Events++
+
+			var e Event
+			if err := dbCodec.Unmarshal(v, &e); err != nil {
+				report.add(ProblemCorrupt, 0, "record %x doesn't decode as an event: %v", k, err)
+				return nil
+			}
+			if e.Event == nil {
+				report.add(ProblemCorrupt, e.ID, "event %v has no event data", e.Key)
+				return nil
+			}
+			if _, err := e.Event.UnmarshalEvent(); err != nil {
+				report.add(ProblemCorrupt, e.ID, "event %v data doesn't decode as %v: %v", e.Key, e.Event.EventVersion, err)
+			}
+
+			if counts[e.RoutingKey] == nil {
+				counts[e.RoutingKey] = map[string]int{}
+			}
+			counts[e.RoutingKey][e.Status]++
+
+			for _, field := range eventIndexes {
+				if err := checkIndex(indexes[field.name], dbCodec, k, field.value(&e)); err != nil {
+					report.add(ProblemIndex, e.ID, "event %v %v index: %v", e.Key, field.name, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, field := range eventIndexes {
+			idx, ok := indexes[field.name]
+			if !ok {
+				continue
+			}
+			err := idx.IDs.IndexBucket.ForEach(func(id, _ []byte) error {
+				if !ids[string(id)] {
+					report.add(ProblemOrphaned, 0, "%v index references missing record %x", field.name, id)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		checkCounters(&report, eventsBucket, dbCodec, counts)
+		return nil
+	})
+
+	return &report, err
+}
+
+// checkIndex confirms a record's ID is indexed under the expected value.
+func checkIndex(idx *index.ListIndex, codec codec.MarshalUnmarshaler, id []byte, value interface{}) error {
+	var expected []byte
+	switch v := value.(type) {
+	case string:
+		expected = []byte(v)
+	case time.Time:
+		if v.IsZero() {
+			break
+		}
+		var err error
+		if expected, err = codec.Marshal(v); err != nil {
+			return err
+		}
+	}
+
+	var indexed []byte
+	if idx != nil {
+		indexed = idx.IDs.Get(id)
+	}
+
+	if len(expected) == 0 {
+		if indexed != nil {
+			return fmt.Errorf("unexpected entry for empty value")
+		}
+		return nil
+	}
+
+	key := append(append(append([]byte{}, expected...), '_', '_'), id...)
+	if indexed == nil {
+		return fmt.Errorf("missing entry")
+	}
+	if !bytes.Equal(indexed, key) {
+		return fmt.Errorf("entry doesn't match value")
+	}
+	if idx.IndexBucket.Get(key) == nil {
+		return fmt.Errorf("dangling entry")
+	}
+	return nil
+}
+
+// checkCounters compares stored status counters with those counted from
+// events.
+func checkCounters(report *CheckReport, eventsBucket *bolt.Bucket, codec codec.MarshalUnmarshaler, counts map[string]map[string]int) {
+	stored := map[string]map[string]int{}
+	if b := eventsBucket.Bucket([]byte(countersBucket)); b != nil {
+		if b = b.Bucket([]byte("statusCounter")); b != nil {
+			_ = b.ForEach(func(k, v []byte) error {
+				if v == nil {
+					return nil
+				}
+				var counter statusCounter
+				if err := codec.Unmarshal(v, &counter); err != nil {
+					report.add(ProblemCounter, 0, "counter %v doesn't decode: %v", string(k), err)
+					return nil
+				}
+				stored[counter.RoutingKey] = counter.Counts
+				return nil
+			})
+		}
+	}
+
+	for rk, statuses := range counts {
+		for status, count := range statuses {
+			if stored[rk][status] != count {
+				report.add(ProblemCounter, 0, "%v has %v %v events but counter is %v", rk, count, status, stored[rk][status])
+			}
+		}
+	}
+	for rk, statuses := range stored {
+		for status, count := range statuses {
+			if count != 0 && counts[rk][status] == 0 {
+				report.add(ProblemCounter, 0, "%v has no %v events but counter is %v", rk, status, count)
+			}
+		}
+	}
+}
+
+func fileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
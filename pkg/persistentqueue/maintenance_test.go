@@ -0,0 +1,126 @@
+package persistentqueue
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBackupAndCheck(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(NewMockEventQueue()))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := q.Enqueue(newTestEventContainer("11863b592c824bfc8989d9cba76abcde")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.wg.Wait()
+
+	backup, err := q.Backup(tmpDbFile + ".test.bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = q.Shutdown()
+
+	db, err := OpenDB(backup, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	report, err := Check(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 5, report.Events)
+	assert.Empty(t, report.Problems)
+
+	// Corrupt one record and orphan another's index entries.
+	err = db.Bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("events")).Bucket([]byte("Event"))
+		c := b.Cursor()
+		k, _ := c.First()
+		if err := b.Put(k, []byte("not json")); err != nil {
+			return err
+		}
+		k, _ = c.Next()
+		return b.Delete(k)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = Check(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 4, report.Events)
+
+	kinds := map[string]int{}
+	for _, problem := range report.Problems {
+		kinds[problem.Kind]++
+	}
+	assert.Equal(t, 1, kinds[ProblemCorrupt])
+	assert.Equal(t, len(eventIndexes), kinds[ProblemOrphaned])
+	assert.NotZero(t, kinds[ProblemCounter])
+}
+
+func TestCompact(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	if err := seedEvents(tmpDbFile, 500, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := OpenDB(tmpDbFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []Event
+	if err := db.From("events").All(&events); err != nil {
+		t.Fatal(err)
+	}
+	for i := range events[:450] {
+		if err := db.From("events").DeleteStruct(&events[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	if err := os.Chmod(tmpDbFile, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	before, after, err := Compact(tmpDbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, after < before, "Expected compacted database to be smaller, was %v then %v.", before, after)
+
+	info, err := os.Stat(tmpDbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	db, err = OpenDB(tmpDbFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	count, err := db.From("events").Count(&Event{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 50, count)
+}
@@ -0,0 +1,148 @@
+package persistentqueue
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/asdine/storm"
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+const metaBucket = "meta"
+const schemaVersionKey = "schema_version"
+
+var ErrSchemaTooNew = errors.New("database schema is newer than this version of the agent supports")
+
+// Migration upgrades the event database from the previous schema version to
+// `Version`.
+//
+// Each migration runs within its own transaction along with recording the
+// new schema version, so a failed migration leaves the database at the last
+// successfully applied version.
+type Migration struct {
+	Version     int
+	Description string
+	Migrate     func(events storm.Node) error
+}
+
+// Migrations is the ordered list of all schema migrations. New migrations
+// must be appended with the next version number, and existing migrations
+// should never be modified once released.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Build per-routing key status counters.",
+		Migrate: func(events storm.Node) error {
+			_, err := rebuildStatus(events)
+			return err
+		},
+	},
+}
+
+// LatestSchemaVersion is the schema version of a fully migrated database.
+func LatestSchemaVersion() int {
+	if len(Migrations) == 0 {
+		return 0
+	}
+	return Migrations[len(Migrations)-1].Version
+}
+
+// SchemaVersion returns the schema version recorded in the database, where
+// zero means the database predates versioning.
+func SchemaVersion(db storm.Node) (int, error) {
+	var version int
+	err := db.Get(metaBucket, schemaVersionKey, &version)
+	if err == storm.ErrNotFound {
+		return 0, nil
+	}
+	return version, err
+}
+
+// PendingMigrations returns the migrations yet to be applied to the
+// database, in order.
+func PendingMigrations(db storm.Node) ([]Migration, error) {
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if version > LatestSchemaVersion() {
+		return nil, ErrSchemaTooNew
+	}
+
+	var pending []Migration
+	for _, m := range Migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies any pending migrations to the database, returning those
+// applied.
+//
+// Before migrating an existing database a consistent backup is written
+// alongside it at `dbPath`, named for the schema version it contains. New,
+// empty databases are stamped with the latest version directly.
+func Migrate(db *storm.DB, dbPath string, logger *zap.SugaredLogger) ([]Migration, error) {
+	pending, err := PendingMigrations(db)
+	if err != nil || len(pending) == 0 {
+		return nil, err
+	}
+
+	fresh, err := isEmpty(db)
+	if err != nil {
+		return nil, err
+	}
+	if fresh {
+		logger.Infof("Initializing new database at schema version %v.", LatestSchemaVersion())
+		return nil, db.Set(metaBucket, schemaVersionKey, LatestSchemaVersion())
+	}
+
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+
+	backup, err := Backup(db, fmt.Sprintf("%v.v%v.%v.bak", dbPath, version, time.Now().UnixNano()))
+	if err != nil {
+		logger.Error("Error backing up database before migrating: ", err)
+		return nil, err
+	}
+	logger.Infof("Backed up database to %v before migrating.", backup)
+
+	var applied []Migration
+	for _, m := range pending {
+		logger.Infof("Migrating database to schema version %v: %v", m.Version, m.Description)
+
+		err := db.Bolt.Update(func(tx *bolt.Tx) error {
+			node := db.WithTransaction(tx)
+			if err := m.Migrate(node.From("events")); err != nil {
+				return err
+			}
+			return node.Set(metaBucket, schemaVersionKey, m.Version)
+		})
+		if err != nil {
+			logger.Errorf("Error migrating database to schema version %v: %v", m.Version, err)
+			return applied, err
+		}
+
+		applied = append(applied, m)
+	}
+
+	return applied, nil
+}
+
+// isEmpty returns true if no events have ever been written to the database,
+// as is the case when it's first created.
+func isEmpty(db *storm.DB) (bool, error) {
+	empty := true
+	err := db.Bolt.View(func(tx *bolt.Tx) error {
+		empty = tx.Bucket([]byte("events")) == nil
+		return nil
+	})
+	return empty, err
+}
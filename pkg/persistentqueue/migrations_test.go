@@ -0,0 +1,95 @@
+package persistentqueue
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateNewDatabase(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	db, err := OpenDB(tmpDbFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	applied, err := Migrate(db, tmpDbFile, common.Logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, applied)
+
+	version, err := SchemaVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, LatestSchemaVersion(), version)
+
+	backups, _ := filepath.Glob(tmpDbFile + ".*.bak")
+	assert.Empty(t, backups, "Expected no backup for a new database.")
+}
+
+func TestMigrateExistingDatabase(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	if err := seedEvents(tmpDbFile, 10, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := OpenDB(tmpDbFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	pending, err := PendingMigrations(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(Migrations), len(pending))
+
+	applied, err := Migrate(db, tmpDbFile, common.Logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(Migrations), len(applied))
+
+	version, err := SchemaVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, LatestSchemaVersion(), version)
+
+	backups, _ := filepath.Glob(tmpDbFile + ".v0.*.bak")
+	assert.Len(t, backups, 1, "Expected a backup of the unversioned database.")
+
+	pending, err = PendingMigrations(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, pending)
+}
+
+func TestMigrateNewerDatabase(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	db, err := OpenDB(tmpDbFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.Set(metaBucket, schemaVersionKey, LatestSchemaVersion()+1); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Migrate(db, tmpDbFile, common.Logger)
+	assert.Equal(t, ErrSchemaTooNew, err)
+}
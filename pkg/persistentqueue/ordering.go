@@ -0,0 +1,202 @@
+package persistentqueue
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+const (
+	// OrderingSkip lets later events proceed when an event fails, leaving
+	// the failed event to be retried out of order. This is the default.
+	OrderingSkip = "skip"
+
+	// OrderingBlock holds later events in the same scope as a failed event
+	// until it's either retried successfully or explicitly skipped.
+	OrderingBlock = "block"
+)
+
+const (
+	// OrderingScopeRoutingKey orders all events for a routing key. This is
+	// the default.
+	OrderingScopeRoutingKey = "routing_key"
+
+	// OrderingScopeDedupKey orders events per routing key and dedup key (or
+	// incident key for V1 events), so a failure only blocks events for the
+	// same alert. Events without a dedup key share their routing key's scope.
+	OrderingScopeDedupKey = "dedup_key"
+)
+
+var ErrEventBlocked = errors.New("an earlier event in the same ordering scope failed")
+
+// OrderingPolicy determines what happens to later events when an event fails.
+//
+// Events within a scope are always dispatched in creation order, including
+// when replayed on start. With `OrderingBlock`, that order is also preserved
+// across failures and retries.
+type OrderingPolicy struct {
+	OnFailure string `mapstructure:"on_failure"`
+	Scope     string `mapstructure:"scope"`
+}
+
+// blocking returns true if failures hold later events.
+func (p *OrderingPolicy) blocking() bool {
+	return p.OnFailure == OrderingBlock
+}
+
+// scopeKey returns the key identifying the event's ordering scope.
+func (p *OrderingPolicy) scopeKey(e *Event) string {
+	if p.Scope != OrderingScopeDedupKey {
+		return e.RoutingKey
+	}
+
+	if dedupKey := eventDedupKey(e.Event); dedupKey != "" {
+		return e.RoutingKey + "/" + dedupKey
+	}
+	return e.RoutingKey
+}
+
+// eventDedupKey returns the dedup key of V2 events, or the incident key of V1
+// events.
+func eventDedupKey(eventContainer *eventsapi.EventContainer) string {
+	event, err := eventContainer.UnmarshalEvent()
+	if err != nil {
+		return ""
+	}
+
+	switch e := event.(type) {
+	case *eventsapi.EventV2:
+		return e.DedupKey
+	case *eventsapi.EventV1:
+		return e.IncidentKey
+	}
+	return ""
+}
+
+// orderingScope tracks events dispatched within a scope when blocking.
+type orderingScope struct {
+	routingKey string
+	blocked    bool
+	held       []*Event
+	last       *dispatch
+}
+
+// dispatch is a single event sent to the EventQueue. Each waits for the
+// previous dispatch in its scope to complete before it's processed.
+type dispatch struct {
+	prev *dispatch
+	done chan struct{}
+	ok   bool
+}
+
+// wait blocks until the previous dispatch has completed, returning
+// `ErrEventBlocked` if it didn't succeed.
+//
+// Only called from the EventQueue's worker for the event's routing key.
+func (d *dispatch) wait() error {
+	prev := d.prev
+	if prev == nil {
+		return nil
+	}
+	d.prev = nil
+
+	<-prev.done
+	if !prev.ok {
+		return ErrEventBlocked
+	}
+	return nil
+}
+
+// sequence registers an event for dispatch within its scope, or holds it if
+// the scope is blocked, returning nil. Callers must hold `q.mu`.
+func (q *PersistentQueue) sequence(e *Event) *dispatch {
+	s := q.scope(e)
+	if s.blocked {
+		q.logger.Infof("Holding %v behind failed event.", e.Key)
+		s.held = append(s.held, e)
+		return nil
+	}
+
+	d := dispatch{prev: s.last, done: make(chan struct{})}
+	s.last = &d
+	return &d
+}
+
+// settle records the outcome of a dispatch, blocking its scope if it failed.
+//
+// Events that were themselves blocked are held, unless the scope has since
+// been unblocked by a retry in which case they're dispatched again (provided
+// the queue isn't shutting down).
+func (q *PersistentQueue) settle(e *Event, d *dispatch, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	s := q.scope(e)
+	switch {
+	case err == ErrEventBlocked && (s.blocked || q.stopping):
+		q.logger.Infof("Holding %v behind failed event.", e.Key)
+		s.held = append(s.held, e)
+	case err == ErrEventBlocked:
+		// Sent before completing so that later events blocked by the same
+		// failure are sent after it.
+		q.send(e)
+	case err != nil:
+		s.blocked = true
+	default:
+		d.ok = true
+	}
+	close(d.done)
+
+	if s.last == d && !s.blocked && len(s.held) == 0 {
+		delete(q.scopes, q.ordering.scopeKey(e))
+	}
+}
+
+// block marks the event's scope as blocked, as when loading failed events on
+// start.
+func (q *PersistentQueue) block(e *Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.scope(e).blocked = true
+}
+
+// unblock clears blocked scopes for a routing key (or all if none is
+// provided), returning their held events. Callers must hold `q.mu`.
+//
+// Released events start a new sequence, as events still waiting on the
+// failure are ahead of them in the EventQueue and will be sent again after
+// them.
+func (q *PersistentQueue) unblock(routingKey string) []*Event {
+	var held []*Event
+	for _, s := range q.scopes {
+		if routingKey != "" && s.routingKey != routingKey {
+			continue
+		}
+		s.blocked = false
+		s.last = nil
+		held = append(held, s.held...)
+		s.held = nil
+	}
+	return held
+}
+
+// scope returns the event's scope, creating it if necessary. Callers must
+// hold `q.mu`.
+func (q *PersistentQueue) scope(e *Event) *orderingScope {
+	key := q.ordering.scopeKey(e)
+	s, ok := q.scopes[key]
+	if !ok {
+		s = &orderingScope{routingKey: e.RoutingKey}
+		q.scopes[key] = s
+	}
+	return s
+}
+
+// sortEvents sorts events by ID, i.e. creation order.
+func sortEvents(events []*Event) {
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+}
@@ -0,0 +1,459 @@
+package persistentqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/asdine/storm"
+	"github.com/stretchr/testify/assert"
+)
+
+const orderingRoutingKey = "11863b592c824bfc8989d9cba76abcde"
+
+// recorder is an EventQueue processor that records the dedup keys of events
+// it sends, failing any it's told to.
+type recorder struct {
+	mu      sync.Mutex
+	failing map[string]bool
+	sent    []string
+}
+
+func newRecorder(failing ...string) *recorder {
+	r := recorder{failing: map[string]bool{}}
+	for _, key := range failing {
+		r.failing[key] = true
+	}
+	return &r
+}
+
+func (r *recorder) process(job eventqueue.Job, _ chan bool) {
+	event, _ := job.EventContainer.UnmarshalEvent()
+	dedupKey := event.(*eventsapi.EventV2).DedupKey
+
+	r.mu.Lock()
+	failing := r.failing[dedupKey]
+	if !failing {
+		r.sent = append(r.sent, dedupKey)
+	}
+	r.mu.Unlock()
+
+	if failing {
+		job.ResponseChan <- eventqueue.Response{Error: errors.New("failed")}
+		return
+	}
+	job.ResponseChan <- eventqueue.Response{}
+}
+
+func (r *recorder) recover(dedupKey string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.failing, dedupKey)
+}
+
+func (r *recorder) sentKeys() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.sent...)
+}
+
+func newOrderingQueue(t *testing.T, r *recorder, policy OrderingPolicy) *PersistentQueue {
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = r.process
+
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(eq), WithOrdering(policy))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func newDedupEventContainer(routingKey, dedupKey string) *eventsapi.EventContainer {
+	return &eventsapi.EventContainer{
+		EventVersion: eventsapi.EventVersion2,
+		EventData: []byte(fmt.Sprintf(`
+			{
+				"routing_key":  "%v",
+				"event_action": "trigger",
+				"dedup_key":    "%v",
+				"payload": {
+					"summary":  "PagerDuty Agent Test",
+					"source":   "pdagent",
+					"severity": "error"
+				}
+			}
+		`, routingKey, dedupKey)),
+	}
+}
+
+func enqueueAll(t *testing.T, q *PersistentQueue, dedupKeys ...string) {
+	for _, dedupKey := range dedupKeys {
+		if _, err := q.Enqueue(newDedupEventContainer(orderingRoutingKey, dedupKey)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q.wg.Wait()
+}
+
+func assertStatus(t *testing.T, q *PersistentQueue, expected StatusItem) {
+	expected.RoutingKey = orderingRoutingKey
+
+	items, err := q.Status(orderingRoutingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []StatusItem{expected}, items)
+}
+
+func TestOrderingSkipOnFailure(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	r := newRecorder("1")
+	q := newOrderingQueue(t, r, OrderingPolicy{})
+	defer q.Shutdown()
+
+	enqueueAll(t, q, "1", "2", "3")
+	assert.Equal(t, []string{"2", "3"}, r.sentKeys())
+	assertStatus(t, q, StatusItem{Success: 2, Error: 1})
+
+	r.recover("1")
+	count, err := q.Retry(orderingRoutingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.wg.Wait()
+
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []string{"2", "3", "1"}, r.sentKeys())
+	assertStatus(t, q, StatusItem{Success: 3})
+}
+
+func TestOrderingBlockOnFailure(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	r := newRecorder("1")
+	q := newOrderingQueue(t, r, OrderingPolicy{OnFailure: OrderingBlock})
+	defer q.Shutdown()
+
+	enqueueAll(t, q, "1", "2", "3")
+	assert.Empty(t, r.sentKeys())
+	assertStatus(t, q, StatusItem{Pending: 2, Error: 1})
+
+	// Events enqueued while blocked are held too.
+	enqueueAll(t, q, "4")
+	assert.Empty(t, r.sentKeys())
+
+	r.recover("1")
+	count, err := q.Retry(orderingRoutingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.wg.Wait()
+
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []string{"1", "2", "3", "4"}, r.sentKeys())
+	assertStatus(t, q, StatusItem{Success: 4})
+}
+
+func TestOrderingBlockRetryFailsAgain(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	r := newRecorder("1")
+	q := newOrderingQueue(t, r, OrderingPolicy{OnFailure: OrderingBlock})
+	defer q.Shutdown()
+
+	enqueueAll(t, q, "1", "2")
+
+	if _, err := q.Retry(""); err != nil {
+		t.Fatal(err)
+	}
+	q.wg.Wait()
+
+	assert.Empty(t, r.sentKeys())
+	assertStatus(t, q, StatusItem{Pending: 1, Error: 1})
+}
+
+func TestOrderingConcurrentRetries(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	r := newRecorder("1")
+	q := newOrderingQueue(t, r, OrderingPolicy{OnFailure: OrderingBlock})
+	defer q.Shutdown()
+
+	enqueueAll(t, q, "1", "2")
+	r.recover("1")
+
+	// Failed events are only retried once, however many retries overlap.
+	var wg sync.WaitGroup
+	counts := make([]int, 5)
+	for i := range counts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			count, err := q.Retry(orderingRoutingKey)
+			assert.NoError(t, err)
+			counts[i] = count
+		}(i)
+	}
+	wg.Wait()
+	q.wg.Wait()
+
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	assert.Equal(t, 1, total)
+	assert.Equal(t, []string{"1", "2"}, r.sentKeys())
+	assertStatus(t, q, StatusItem{Success: 2})
+}
+
+func TestOrderingRetryBackPressure(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	// More failed events than the EventQueue's buffer holds for a routing
+	// key.
+	db, err := storm.Open(tmpDbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected []string
+	err = withTx(db, func(tx *eventTx) error {
+		for i := 0; i < eventqueue.DefaultBufferSize+500; i++ {
+			dedupKey := fmt.Sprint(i)
+			e, err := NewEvent(newDedupEventContainer(orderingRoutingKey, dedupKey))
+			if err != nil {
+				return err
+			}
+			e.Status = StatusError
+			if err := e.create(tx); err != nil {
+				return err
+			}
+			expected = append(expected, dedupKey)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// Holds events in the EventQueue until released.
+	r := newRecorder()
+	release := make(chan struct{})
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = func(job eventqueue.Job, stop chan bool) {
+		<-release
+		r.process(job, stop)
+	}
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+	if err := q.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := q.Retry(orderingRoutingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(expected), count)
+
+	q.mu.Lock()
+	assert.Equal(t, replayKeyLimit, q.inflight[orderingRoutingKey])
+	q.mu.Unlock()
+
+	close(release)
+	if err := q.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, expected, r.sentKeys())
+	assertStatus(t, q, StatusItem{Success: len(expected)})
+}
+
+func TestOrderingConcurrentRetryAndSkip(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	r := newRecorder("1")
+	q := newOrderingQueue(t, r, OrderingPolicy{OnFailure: OrderingBlock})
+	defer q.Shutdown()
+
+	enqueueAll(t, q, "1", "2")
+	r.recover("1")
+
+	// The failed event is either retried or skipped, never both.
+	var wg sync.WaitGroup
+	var retried, skipped int
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		count, err := q.Retry(orderingRoutingKey)
+		assert.NoError(t, err)
+		retried = count
+	}()
+	go func() {
+		defer wg.Done()
+		count, err := q.Skip(orderingRoutingKey)
+		assert.NoError(t, err)
+		skipped = count
+	}()
+	wg.Wait()
+	q.wg.Wait()
+
+	assert.Equal(t, 1, retried+skipped)
+	if retried == 1 {
+		assert.Equal(t, []string{"1", "2"}, r.sentKeys())
+		assertStatus(t, q, StatusItem{Success: 2})
+	} else {
+		assert.Equal(t, []string{"2"}, r.sentKeys())
+		assertStatus(t, q, StatusItem{Success: 1, Skipped: 1})
+	}
+}
+
+func TestOrderingConcurrentEnqueues(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	r := newRecorder()
+	q := newOrderingQueue(t, r, OrderingPolicy{})
+	defer q.Shutdown()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := q.Enqueue(newDedupEventContainer(orderingRoutingKey, fmt.Sprint(i)))
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	q.wg.Wait()
+
+	// Events are sent in the order they were created in, whichever order
+	// their enqueues finished in.
+	var events []*Event
+	if err := q.Events.All(&events); err != nil {
+		t.Fatal(err)
+	}
+	var expected []string
+	for _, e := range events {
+		expected = append(expected, eventDedupKey(e.Event))
+	}
+	assert.Len(t, expected, 50)
+	assert.Equal(t, expected, r.sentKeys())
+}
+
+func TestOrderingBlockSkip(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	r := newRecorder("1")
+	q := newOrderingQueue(t, r, OrderingPolicy{OnFailure: OrderingBlock})
+	defer q.Shutdown()
+
+	enqueueAll(t, q, "1", "2", "3")
+
+	count, err := q.Skip(orderingRoutingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.wg.Wait()
+
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []string{"2", "3"}, r.sentKeys())
+	assertStatus(t, q, StatusItem{Success: 2, Skipped: 1})
+}
+
+func TestOrderingBlockDedupKeyScope(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	r := newRecorder("a")
+	q := newOrderingQueue(t, r, OrderingPolicy{OnFailure: OrderingBlock, Scope: OrderingScopeDedupKey})
+	defer q.Shutdown()
+
+	enqueueAll(t, q, "a", "b", "a", "b")
+
+	// Only events for the failed alert are held.
+	assert.Equal(t, []string{"b", "b"}, r.sentKeys())
+	assertStatus(t, q, StatusItem{Pending: 1, Success: 2, Error: 1})
+}
+
+func TestOrderingBlockAcrossRestart(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	policy := OrderingPolicy{OnFailure: OrderingBlock}
+
+	r := newRecorder("1")
+	q := newOrderingQueue(t, r, policy)
+	enqueueAll(t, q, "1", "2")
+	if err := q.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The failed event continues to block after a restart.
+	r = newRecorder()
+	q = newOrderingQueue(t, r, policy)
+	defer q.Shutdown()
+	q.wg.Wait()
+
+	enqueueAll(t, q, "3")
+	assert.Empty(t, r.sentKeys())
+	assertStatus(t, q, StatusItem{Pending: 2, Error: 1})
+
+	if _, err := q.Retry(orderingRoutingKey); err != nil {
+		t.Fatal(err)
+	}
+	q.wg.Wait()
+
+	assert.Equal(t, []string{"1", "2", "3"}, r.sentKeys())
+	assertStatus(t, q, StatusItem{Success: 3})
+}
+
+func TestOrderingReplayOnStart(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	db, err := storm.Open(tmpDbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash part way through, with a mix of sent and unsent events.
+	var expected []string
+	for i := 1; i <= 10; i++ {
+		dedupKey := fmt.Sprint(i)
+		e, _ := NewEvent(newDedupEventContainer(orderingRoutingKey, dedupKey))
+		if i%3 == 0 {
+			e.Status = StatusSuccess
+		} else {
+			expected = append(expected, dedupKey)
+		}
+		if err := e.Create(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	r := newRecorder()
+	q := newOrderingQueue(t, r, OrderingPolicy{})
+	defer q.Shutdown()
+	q.wg.Wait()
+
+	assert.Equal(t, expected, r.sentKeys())
+	assertStatus(t, q, StatusItem{Success: 10})
+}
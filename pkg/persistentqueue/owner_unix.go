@@ -0,0 +1,18 @@
+// +build !windows
+
+package persistentqueue
+
+import (
+	"os"
+	"syscall"
+)
+
+// chownLike gives the file at path the same owner as the file described by
+// info.
+func chownLike(path string, info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return os.Chown(path, int(stat.Uid), int(stat.Gid))
+}
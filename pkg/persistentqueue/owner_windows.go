@@ -0,0 +1,8 @@
+package persistentqueue
+
+import "os"
+
+// chownLike does nothing on Windows, where files don't have Unix owners.
+func chownLike(path string, info os.FileInfo) error {
+	return nil
+}
@@ -5,16 +5,20 @@ import (
 	"os"
 	"path"
 	"sync"
+	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/common"
 	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
 	"github.com/asdine/storm"
+	bolt "go.etcd.io/bbolt"
 	"go.uber.org/zap"
 )
 
+const openTimeout = 5 * time.Second
+
 type EventQueue interface {
-	Enqueue(*eventsapi.EventContainer, chan<- eventqueue.Response) error
+	Enqueue(*eventsapi.EventContainer, chan<- eventqueue.Response, ...eventqueue.JobOption) error
 	Shutdown()
 }
 
@@ -23,10 +27,50 @@ type PersistentQueue struct {
 	Events     storm.Node
 	EventQueue EventQueue
 
-	path   string
-	logger *zap.SugaredLogger
-	tmp    bool
-	wg     sync.WaitGroup
+	expiry   ExpiryPolicy
+	keyring  *Keyring
+	ordering OrderingPolicy
+	path     string
+	logger   *zap.SugaredLogger
+	tmp      bool
+	wg       sync.WaitGroup
+
+	// Guards ordering and replay state, and the expiry policy.
+	mu              sync.Mutex
+	scopes          map[string]*orderingScope
+	inflight        map[string]int
+	room            *sync.Cond
+	replaying       bool
+	replayedThrough int
+	replayDone      chan struct{}
+	progress        ReplayProgress
+	stopping        bool
+
+	// While replaying: the last event ID read by replay, events retried or
+	// released that it had already read past, and each scope's first failed
+	// event from before it started.
+	replayRead     int
+	replayRetries  []*Event
+	replayFailures map[string]*Event
+
+	// Newly enqueued or retried events waiting for room in the EventQueue,
+	// sent in order by `feed`.
+	backlog []*Event
+
+	// Channels closed once events leave the pending status, by event key.
+	watchers map[string][]chan struct{}
+
+	transitions transitions
+
+	// When events were last processed, for health checks.
+	lastProgress time.Time
+	lastSuccess  time.Time
+	lastFailure  time.Time
+
+	// When the database was last written to and whether it failed, for
+	// health checks.
+	lastWrite    time.Time
+	lastWriteErr error
 }
 
 type Option func(*PersistentQueue)
@@ -44,6 +88,28 @@ func WithEventQueue(eq EventQueue) Option {
 	}
 }
 
+// WithExpiry sets the policy for expiring stale pending events.
+func WithExpiry(policy ExpiryPolicy) Option {
+	return func(q *PersistentQueue) {
+		q.expiry = policy
+	}
+}
+
+// WithKeyring enables encryption of event payloads at rest using the given
+// keys.
+func WithKeyring(keyring *Keyring) Option {
+	return func(q *PersistentQueue) {
+		q.keyring = keyring
+	}
+}
+
+// WithOrdering sets the policy for ordering events when an event fails.
+func WithOrdering(policy OrderingPolicy) Option {
+	return func(q *PersistentQueue) {
+		q.ordering = policy
+	}
+}
+
 func NewPersistentQueue(options ...Option) *PersistentQueue {
 	logger := common.Logger.Named("PersistentQueue")
 	logger.Info("Creating new PersistentQueue.")
@@ -51,8 +117,12 @@ func NewPersistentQueue(options ...Option) *PersistentQueue {
 	q := PersistentQueue{
 		EventQueue: eventqueue.NewEventQueue(),
 		logger:     logger,
+		scopes:     map[string]*orderingScope{},
+		inflight:   map[string]int{},
+		watchers:   map[string][]chan struct{}{},
 		tmp:        true,
 	}
+	q.room = sync.NewCond(&q.mu)
 
 	for _, option := range options {
 		option(&q)
@@ -75,32 +145,49 @@ func (q *PersistentQueue) Start() error {
 		}
 	}
 
-	db, err := storm.Open(q.path)
+	db, err := OpenDB(q.path, q.keyring)
 	if err != nil {
 		return err
 	}
 
-	q.DB = db
-	q.Events = q.DB.From("events")
-
-	var pendingEvents []Event
-	if err := q.Events.Find("Status", StatusPending, &pendingEvents); err != nil && err != storm.ErrNotFound {
-		q.logger.Error("Error querying for pending events: ", err)
+	if _, err := Migrate(db, q.path, q.logger); err != nil {
+		q.logger.Error("Error migrating database: ", err)
+		db.Close()
 		return err
 	}
 
-	q.logger.Infof("Enqueuing %v pending events.", len(pendingEvents))
-	for _, e := range pendingEvents {
-		q.processEvent(&e)
-	}
+	q.DB = db
+	q.Events = q.DB.From("events")
 
-	return nil
+	return q.startReplay()
+}
+
+// OpenDB opens the event database at the given path, using the keyring (if
+// any) for encrypted events.
+//
+// Fails rather than blocking if the database is already open elsewhere, e.g.
+// by a running server.
+func OpenDB(path string, keyring *Keyring) (*storm.DB, error) {
+	return storm.Open(
+		path,
+		storm.Codec(eventCodec{keyring}),
+		storm.BoltOptions(0600, &bolt.Options{Timeout: openTimeout}),
+	)
 }
 
 // Stop a `PersistentQueue`, performing any necessary cleanup.
 func (q *PersistentQueue) Shutdown() error {
 	q.logger.Info("Shutting down PersistentQueue.")
 
+	q.mu.Lock()
+	q.stopping = true
+	q.room.Broadcast()
+	q.mu.Unlock()
+
+	if q.replayDone != nil {
+		<-q.replayDone
+	}
+
 	q.EventQueue.Shutdown()
 	q.wg.Wait()
 	if err := q.DB.Close(); err != nil {
@@ -53,3 +53,29 @@ func TestPersistentQueueSimple(t *testing.T) {
 
 	_ = q.Shutdown()
 }
+
+func TestPersistentQueueEnqueueWithToken(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(NewMockEventQueue()))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	key, err := q.Enqueue(newTestEventContainer("11863b592c824bfc8989d9cba76abcde"), WithToken("ci"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.wg.Wait()
+
+	persistedEvent, err := FindEventByKey(q.Events, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if persistedEvent.Token != "ci" {
+		t.Errorf("Expected event token to be ci, was %v.", persistedEvent.Token)
+	}
+}
@@ -0,0 +1,58 @@
+package persistentqueue
+
+import (
+	"github.com/asdine/storm"
+)
+
+const reencryptBatchSize = 500
+
+// Reencrypt rewrites every event using the queue's current encryption
+// configuration, returning the number of events rewritten.
+//
+// After rotating keys this moves all events onto the new primary key, and
+// for a previously unencrypted database it encrypts existing events. Events
+// are rewritten in place without touching indexes, in batches to avoid
+// holding a single long-running write transaction.
+func (q *PersistentQueue) Reencrypt() (int, error) {
+	q.logger.Info("Re-encrypting events.")
+
+	var ids []int
+	err := q.Events.Select().Each(new(Event), func(record interface{}) error {
+		ids = append(ids, record.(*Event).ID)
+		return nil
+	})
+	if err != nil && err != storm.ErrNotFound {
+		q.logger.Error("Error listing events to re-encrypt: ", err)
+		return 0, err
+	}
+
+	count := 0
+	for start := 0; start < len(ids); start += reencryptBatchSize {
+		end := start + reencryptBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		err := withTx(q.DB, func(tx *eventTx) error {
+			for _, id := range ids[start:end] {
+				var e Event
+				if err := tx.One("ID", id, &e); err != nil {
+					return err
+				}
+				if err := tx.Set("Event", e.ID, &e); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			q.logger.Errorf("Error re-encrypting events after %v rewritten: %v", count, err)
+			return count, err
+		}
+
+		count = end
+		q.logger.Infof("Re-encrypted %v of %v events.", count, len(ids))
+	}
+
+	return count, nil
+}
@@ -0,0 +1,281 @@
+package persistentqueue
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/index"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Number of pending events read from the database at a time during replay.
+const replayBatchSize = 500
+
+// Maximum events in flight per routing key before replayed, newly enqueued
+// and retried events wait for room, leaving space in the EventQueue's buffer.
+const replayKeyLimit = eventqueue.DefaultBufferSize / 2
+
+// ReplayProgress reports on replaying pending events left from a previous
+// run.
+type ReplayProgress struct {
+	Total    int  `json:"total"`
+	Replayed int  `json:"replayed"`
+	Done     bool `json:"done"`
+}
+
+// ReplayProgress returns the progress of replaying pending events on start.
+func (q *PersistentQueue) ReplayProgress() ReplayProgress {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.progress
+}
+
+// startReplay begins replaying pending events in the background.
+//
+// Until replay completes, newly enqueued events are only persisted, and are
+// picked up by replay in turn so that they aren't sent ahead of older events.
+func (q *PersistentQueue) startReplay() error {
+	items, err := q.Status("")
+	if err != nil {
+		return err
+	}
+
+	var failures map[string]*Event
+	if q.ordering.blocking() {
+		if failures, err = q.firstFailures(); err != nil {
+			return err
+		}
+	}
+
+	for _, item := range items {
+		q.progress.Total += item.Pending
+	}
+	q.replaying = true
+	q.replayFailures = failures
+	q.replayDone = make(chan struct{})
+
+	q.wg.Add(1)
+	go q.replay()
+
+	return nil
+}
+
+// replay streams pending events to the EventQueue in creation order, in
+// batches, waiting whenever a routing key has too many events in flight.
+//
+// As events are read in order, replay pauses for all routing keys while
+// waiting for any one of them. Events retried or released meanwhile that
+// replay has already read past are merged in by ID.
+//
+// When blocking on failure, events after each scope's first failed event are
+// held until it's retried or skipped.
+func (q *PersistentQueue) replay() {
+	defer q.wg.Done()
+	defer close(q.replayDone)
+
+	q.logger.Infof("Replaying %v pending events.", q.ReplayProgress().Total)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var events []*Event
+	for {
+		if len(events) == 0 {
+			var err error
+			if events, err = q.pendingAfter(q.replayRead, replayBatchSize); err != nil {
+				q.logger.Error("Error querying for pending events: ", err)
+			}
+			if len(events) > 0 {
+				if q.replayRead > 0 {
+					q.logger.Infof("Replayed %v of %v pending events.", q.progress.Replayed, q.progress.Total)
+				}
+				q.replayRead = events[len(events)-1].ID
+			}
+		}
+
+		// Finishing in the same critical section as an empty read, so that
+		// no events are enqueued for replay after it.
+		retried := len(q.replayRetries) > 0 && (len(events) == 0 || q.replayRetries[0].ID < events[0].ID)
+		var e *Event
+		switch {
+		case retried:
+			e = q.replayRetries[0]
+		case len(events) > 0:
+			e = events[0]
+		default:
+			q.finishReplay()
+			return
+		}
+
+		expired := q.expiry.expired(e)
+		if !expired && q.inflight[e.RoutingKey] >= replayKeyLimit && !q.stopping {
+			// Events may be retried while waiting, so pick again after.
+			q.room.Wait()
+			continue
+		}
+		if q.stopping {
+			q.logger.Infof("Stopped replay after %v of %v pending events.", q.progress.Replayed, q.progress.Total)
+			return
+		}
+
+		if retried {
+			q.replayRetries = q.replayRetries[1:]
+		} else {
+			events = events[1:]
+		}
+		q.replayEvent(e, expired)
+
+		// Lets enqueues and retries in between events.
+		q.mu.Unlock()
+		q.mu.Lock()
+	}
+}
+
+// replayEvent sends or expires a pending event. Callers must hold `q.mu`.
+func (q *PersistentQueue) replayEvent(e *Event, expired bool) {
+	q.progress.Replayed++
+
+	if expired {
+		q.mu.Unlock()
+		q.expire(e)
+		q.mu.Lock()
+		return
+	}
+
+	key := q.ordering.scopeKey(e)
+	if failure, ok := q.replayFailures[key]; ok && e.ID > failure.ID {
+		q.scope(failure).blocked = true
+		delete(q.replayFailures, key)
+	}
+	q.send(e)
+}
+
+// finishReplay marks replay as complete, blocking any remaining scopes with
+// failed events. Callers must hold `q.mu`.
+//
+// Events up to the last read were sent by replay, including any still being
+// enqueued.
+func (q *PersistentQueue) finishReplay() {
+	for _, failure := range q.replayFailures {
+		q.scope(failure).blocked = true
+	}
+
+	q.replaying = false
+	q.replayedThrough = q.replayRead
+	q.replayFailures = nil
+	q.progress.Done = true
+	q.room.Broadcast()
+	q.logger.Infof("Finished replaying %v pending events.", q.progress.Replayed)
+}
+
+// resend sends retried or released events again, in creation order. Callers
+// must hold `q.mu`.
+//
+// While replaying, they're left to replay instead, so they're sent in order
+// with the pending events it reads and not twice.
+func (q *PersistentQueue) resend(events []*Event) {
+	sortEvents(events)
+	if !q.replaying {
+		for _, e := range events {
+			q.admit(e)
+		}
+		return
+	}
+
+	for _, e := range events {
+		q.progress.Total++
+		if e.ID <= q.replayRead {
+			q.replayRetries = append(q.replayRetries, e)
+		}
+	}
+	sortEvents(q.replayRetries)
+
+	// Wakes replay if it's waiting for room, as the events it's now due to
+	// send may have it.
+	q.room.Broadcast()
+}
+
+// forgetFailures stops replay blocking scopes behind failed events for a
+// routing key (or all if none is provided), once they're retried or skipped.
+// Callers must hold `q.mu`.
+func (q *PersistentQueue) forgetFailures(routingKey string) {
+	for key, failure := range q.replayFailures {
+		if routingKey == "" || failure.RoutingKey == routingKey {
+			delete(q.replayFailures, key)
+		}
+	}
+}
+
+// firstFailures returns the earliest event in an error state for each
+// ordering scope.
+func (q *PersistentQueue) firstFailures() (map[string]*Event, error) {
+	failed, err := q.failedEvents("")
+	if err != nil {
+		return nil, err
+	}
+
+	failures := map[string]*Event{}
+	for _, e := range failed {
+		key := q.ordering.scopeKey(e)
+		if first, ok := failures[key]; !ok || e.ID < first.ID {
+			failures[key] = e
+		}
+	}
+	return failures, nil
+}
+
+// pendingAfter returns up to `limit` pending events with IDs greater than
+// `after`, in creation order.
+//
+// Reads the status index directly, as storm only supports paging by offset,
+// which shifts as events are sent.
+func (q *PersistentQueue) pendingAfter(after, limit int) ([]*Event, error) {
+	return q.statusAfter(StatusPending, after, limit)
+}
+
+// statusAfter returns up to `limit` events in the given status with IDs
+// greater than `after`, in creation order. A limit of zero returns all.
+func (q *PersistentQueue) statusAfter(status string, after, limit int) ([]*Event, error) {
+	var events []*Event
+
+	start := make([]byte, 8)
+	binary.BigEndian.PutUint64(start, uint64(after+1))
+	prefix := []byte(status + "__")
+
+	err := q.DB.Bolt.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("events"))
+		if bucket != nil {
+			bucket = bucket.Bucket([]byte("Event"))
+		}
+		if bucket == nil {
+			return nil
+		}
+
+		idx, err := index.NewListIndex(bucket, []byte(stormIndexPrefix+"Status"))
+		if err == index.ErrNotFound {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		c := idx.IndexBucket.Cursor()
+		for k, id := c.Seek(append(prefix, start...)); bytes.HasPrefix(k, prefix) && (limit == 0 || len(events) < limit); k, id = c.Next() {
+			raw := bucket.Get(id)
+			if raw == nil {
+				return storm.ErrNotFound
+			}
+
+			var e Event
+			if err := q.DB.Codec().Unmarshal(raw, &e); err != nil {
+				return err
+			}
+			events = append(events, &e)
+		}
+		return nil
+	})
+
+	return events, err
+}
@@ -0,0 +1,161 @@
+package persistentqueue
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/asdine/storm"
+	"github.com/stretchr/testify/assert"
+)
+
+// Comfortably more than an EventQueue's buffer for a single routing key.
+const replayEventCount = 2500
+
+func TestReplayLargeBacklog(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	db, err := storm.Open(tmpDbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected []string
+	err = withTx(db, func(tx *eventTx) error {
+		for i := 0; i < replayEventCount; i++ {
+			dedupKey := fmt.Sprint(i)
+			e, err := NewEvent(newDedupEventContainer(orderingRoutingKey, dedupKey))
+			if err != nil {
+				return err
+			}
+			if err := e.create(tx); err != nil {
+				return err
+			}
+			expected = append(expected, dedupKey)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	r := newRecorder()
+	q := newOrderingQueue(t, r, OrderingPolicy{})
+	defer q.Shutdown()
+
+	// Events enqueued during replay are sent after the backlog.
+	enqueueAll(t, q, "live")
+	expected = append(expected, "live")
+
+	assert.Equal(t, expected, r.sentKeys())
+	progress := q.ReplayProgress()
+	assert.True(t, progress.Done)
+	assert.Equal(t, progress.Total, progress.Replayed)
+	assertStatus(t, q, StatusItem{Success: replayEventCount + 1})
+}
+
+func TestReplayRetryDuringReplay(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	db, err := storm.Open(tmpDbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Failed events both in replay's first batch, on another routing key so
+	// as not to block this one, and after it.
+	const otherRoutingKey = "22863b592c824bfc8989d9cba76abcde"
+	failed := map[int]string{200: "other", 1000: "f1", 1800: "f2"}
+
+	var expected []string
+	err = withTx(db, func(tx *eventTx) error {
+		for i := 0; i < replayEventCount; i++ {
+			routingKey, dedupKey := orderingRoutingKey, fmt.Sprint(i)
+			if key, ok := failed[i]; ok {
+				dedupKey = key
+			}
+			if dedupKey == "other" {
+				routingKey = otherRoutingKey
+			} else {
+				expected = append(expected, dedupKey)
+			}
+
+			e, err := NewEvent(newDedupEventContainer(routingKey, dedupKey))
+			if err != nil {
+				return err
+			}
+			if _, ok := failed[i]; ok {
+				e.Status = StatusError
+			}
+			if err := e.create(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// Holds events in flight so that replay stops for room partway through.
+	r := newRecorder()
+	gate := make(chan struct{})
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = func(job eventqueue.Job, stop chan bool) {
+		<-gate
+		r.process(job, stop)
+	}
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(eq), WithOrdering(OrderingPolicy{OnFailure: OrderingBlock}))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		q.mu.Lock()
+		inflight := q.inflight[orderingRoutingKey]
+		q.mu.Unlock()
+		if inflight == replayKeyLimit {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("Expected replay to wait for room, %v events in flight.", inflight)
+		}
+	}
+
+	count, err := q.Retry("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(failed), count)
+	close(gate)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := q.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each event is sent once, in order for its routing key, with retried
+	// failures no longer holding later events.
+	var sent []string
+	others := 0
+	for _, key := range r.sentKeys() {
+		if key == "other" {
+			others++
+		} else {
+			sent = append(sent, key)
+		}
+	}
+	assert.Equal(t, 1, others)
+	assert.Equal(t, expected, sent)
+	assertStatus(t, q, StatusItem{Success: replayEventCount - 1})
+	progress := q.ReplayProgress()
+	assert.True(t, progress.Done)
+	assert.Equal(t, progress.Total, progress.Replayed)
+}
@@ -1,21 +1,104 @@
 package persistentqueue
 
+import (
+	"github.com/PagerDuty/go-pdagent/pkg/metrics"
+)
+
 // Retries events that are in an error state, either for an routing key or
 // for all events in error if none is provided.
+//
+// Events are retried in creation order. When blocking on failure, events held
+// behind the failed events are released after them.
 func (q *PersistentQueue) Retry(routingKey string) (int, error) {
-	var err error
-	var events []Event
+	q.mu.Lock()
+	defer q.mu.Unlock()
 
-	err = q.Events.Find("Status", StatusError, &events)
+	// Marked pending while the lock is held, so that concurrent retries
+	// don't send the same events again.
+	failed, err := q.failedEvents(routingKey)
 	if err != nil {
 		return 0, err
 	}
-
-	for _, event := range events {
-		if routingKey == "" || event.RoutingKey == routingKey {
-			q.processEvent(&event)
+	err = withTx(q.DB, func(tx *eventTx) error {
+		for _, e := range failed {
+			e.Status = StatusPending
+			if err := e.update(tx); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, e := range failed {
+		q.publish(TransitionRetried, e, "")
+	}
+
+	q.forgetFailures(routingKey)
+	q.resend(append(failed, q.unblock(routingKey)...))
+
+	return len(failed), nil
+}
+
+// Skip gives up on events that are in an error state, either for a routing
+// key or for all events in error if none is provided, marking them as
+// skipped.
+//
+// When blocking on failure, events held behind the skipped events are
+// released in creation order.
+func (q *PersistentQueue) Skip(routingKey string) (int, error) {
+	q.mu.Lock()
+
+	// Like retries, skipped under the lock and all at once, so that a
+	// concurrent retry can't send events being skipped.
+	failed, err := q.failedEvents(routingKey)
+	if err == nil {
+		err = withTx(q.DB, func(tx *eventTx) error {
+			for _, e := range failed {
+				e.Status = StatusSkipped
+				if err := e.update(tx); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if err != nil {
+		q.mu.Unlock()
+		return 0, err
+	}
+
+	for _, e := range failed {
+		q.logger.Infof("Skipped %v.", e.Key)
+		metrics.EventsProcessed.WithLabelValues(metrics.RoutingKey(e.RoutingKey), e.Status).Inc()
+		q.publish(TransitionSkipped, e, "")
 	}
 
-	return len(events), nil
+	q.forgetFailures(routingKey)
+	q.resend(q.unblock(routingKey))
+	q.mu.Unlock()
+
+	for _, e := range failed {
+		q.notify(e)
+	}
+	return len(failed), nil
+}
+
+// failedEvents returns events in an error state for a routing key, or all if
+// none is provided.
+func (q *PersistentQueue) failedEvents(routingKey string) ([]*Event, error) {
+	events, err := q.statusAfter(StatusError, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []*Event
+	for _, e := range events {
+		if routingKey == "" || e.RoutingKey == routingKey {
+			failed = append(failed, e)
+		}
+	}
+	return failed, nil
 }
@@ -1,49 +1,143 @@
 package persistentqueue
 
+import (
+	"github.com/asdine/storm"
+	bolt "go.etcd.io/bbolt"
+)
+
+// countersBucket is nested beneath the events node so that counters share
+// transactions with the events they describe.
+const countersBucket = "counters"
+
 type StatusItem struct {
 	RoutingKey string `json:"routing_key"`
 	Pending    int    `json:"pending"`
 	Success    int    `json:"success"`
 	Error      int    `json:"error"`
+	Expired    int    `json:"expired"`
+	Skipped    int    `json:"skipped"`
+}
+
+// statusCounter holds the number of events per status for a routing key.
+//
+// Counters are maintained alongside event writes so that reporting status
+// doesn't require scanning every event.
+type statusCounter struct {
+	RoutingKey string `storm:"id"`
+	Counts     map[string]int
+}
+
+func (c *statusCounter) statusItem() StatusItem {
+	return StatusItem{
+		RoutingKey: c.RoutingKey,
+		Pending:    c.Counts[StatusPending],
+		Success:    c.Counts[StatusSuccess],
+		Error:      c.Counts[StatusError],
+		Expired:    c.Counts[StatusExpired],
+		Skipped:    c.Counts[StatusSkipped],
+	}
 }
 
 // Returns aggregate stats per routing key for pending and enqueued events.
 func (q *PersistentQueue) Status(routingKey string) ([]StatusItem, error) {
-	var err error
-	var events []Event
-	agg := map[string]*StatusItem{}
+	counters := q.Events.From(countersBucket)
 
-	if routingKey == "" {
-		err = q.Events.All(&events)
-	} else {
-		err = q.Events.Find("RoutingKey", routingKey, &events)
+	if routingKey != "" {
+		var counter statusCounter
+		err := counters.One("RoutingKey", routingKey, &counter)
+		if err == storm.ErrNotFound {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return []StatusItem{counter.statusItem()}, nil
 	}
-	if err != nil {
+
+	var all []statusCounter
+	if err := counters.All(&all); err != nil {
 		return nil, err
 	}
 
-	for _, event := range events {
-		item, ok := agg[event.RoutingKey]
+	var items []StatusItem
+	for _, counter := range all {
+		items = append(items, counter.statusItem())
+	}
+
+	return items, nil
+}
+
+// RebuildStatus recalculates all status counters from the events themselves.
+//
+// Only necessary if counters are suspected to have drifted, as databases
+// that predate counters have them built during migration. Returns the number
+// of events counted.
+func (q *PersistentQueue) RebuildStatus() (int, error) {
+	q.logger.Info("Rebuilding status counters.")
+
+	count := 0
+	err := withTx(q.DB, func(tx *eventTx) error {
+		var err error
+		count, err = rebuildStatus(tx)
+		return err
+	})
+	if err != nil {
+		q.logger.Error("Error rebuilding status counters: ", err)
+		return 0, err
+	}
+
+	q.logger.Infof("Rebuilt status counters from %v events.", count)
+	return count, nil
+}
+
+// rebuildStatus replaces all status counters with ones calculated from the
+// events. Must be called with a node in a writable transaction.
+func rebuildStatus(tx storm.Node) (int, error) {
+	if err := tx.From(countersBucket).Drop(&statusCounter{}); err != nil && err != bolt.ErrBucketNotFound {
+		return 0, err
+	}
+
+	count := 0
+	agg := map[string]*statusCounter{}
+	err := tx.Select().Each(new(Event), func(record interface{}) error {
+		e := record.(*Event)
+		counter, ok := agg[e.RoutingKey]
 		if !ok {
-			item = &StatusItem{RoutingKey: event.RoutingKey}
+			counter = &statusCounter{RoutingKey: e.RoutingKey, Counts: map[string]int{}}
+			agg[e.RoutingKey] = counter
 		}
+		counter.Counts[e.Status]++
+		count++
+		return nil
+	})
+	if err != nil && err != storm.ErrNotFound {
+		return 0, err
+	}
 
-		switch event.Status {
-		case StatusPending:
-			item.Pending++
-		case StatusSuccess:
-			item.Success++
-		case StatusError:
-			item.Error++
+	for _, counter := range agg {
+		if err := tx.From(countersBucket).Save(counter); err != nil {
+			return 0, err
 		}
+	}
+	return count, nil
+}
 
-		agg[event.RoutingKey] = item
+// adjustCounter changes the count of events with the given routing key and
+// status by delta. Must be called with a node in a writable transaction.
+func adjustCounter(tx storm.Node, routingKey, status string, delta int) error {
+	counters := tx.From(countersBucket)
+
+	var counter statusCounter
+	err := counters.One("RoutingKey", routingKey, &counter)
+	if err == storm.ErrNotFound {
+		counter = statusCounter{RoutingKey: routingKey}
+	} else if err != nil {
+		return err
 	}
 
-	var items []StatusItem
-	for _, v := range agg {
-		items = append(items, *v)
+	if counter.Counts == nil {
+		counter.Counts = map[string]int{}
 	}
+	counter.Counts[status] += delta
 
-	return items, nil
+	return counters.Save(&counter)
 }
@@ -0,0 +1,232 @@
+package persistentqueue
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+// Large enough for status to be noticeably slow if it scanned every event.
+const benchmarkEventCount = 100000
+const benchmarkRoutingKeys = 50
+
+// Database of seeded events for benchmarks, see `setupBenchmarkQueue`.
+var benchmarkDbFile = path.Join(tmpDir, "benchmark.db")
+
+var benchmarkDb struct {
+	once sync.Once
+	err  error
+}
+
+func TestStatus(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(NewMockEventQueue()))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	rk1 := "11863b592c824bfc8989d9cba76abcde"
+	rk2 := "22863b592c824bfc8989d9cba76abcde"
+	for _, rk := range []string{rk1, rk1, rk2} {
+		if _, err := q.Enqueue(newTestEventContainer(rk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q.wg.Wait()
+
+	items, err := q.Status(rk1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Success != 2 || items[0].Pending != 0 {
+		t.Fatalf("Unexpected status for %v: %+v", rk1, items)
+	}
+
+	items, err = q.Status("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("Expected status for 2 routing keys, got %+v", items)
+	}
+
+	items, err = q.Status("doesnotexist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Fatalf("Expected no status for unknown routing key, got %+v", items)
+	}
+}
+
+func TestStatusRebuiltOnStart(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	// Simulate a database written before counters existed.
+	if err := seedEvents(tmpDbFile, 10, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(NewMockEventQueue()))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	items, err := q.Status("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total := 0
+	for _, item := range items {
+		total += item.Success
+	}
+	if len(items) != 2 || total != 10 {
+		t.Fatalf("Expected rebuilt status for 10 events over 2 keys, got %+v", items)
+	}
+}
+
+func BenchmarkStatus(b *testing.B) {
+	q := setupBenchmarkQueue(b)
+	defer teardownBenchmarkQueue(b, q)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := q.Status(""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStatusRoutingKey(b *testing.B) {
+	q := setupBenchmarkQueue(b)
+	defer teardownBenchmarkQueue(b, q)
+
+	rk := benchmarkRoutingKey(0)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := q.Status(rk); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRebuildStatus(b *testing.B) {
+	q := setupBenchmarkQueue(b)
+	defer teardownBenchmarkQueue(b, q)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := q.RebuildStatus(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func setupBenchmarkQueue(b *testing.B) *PersistentQueue {
+	b.StopTimer()
+	defer b.StartTimer()
+
+	setup(b)
+
+	// Seeding takes minutes, so it's done once and copied for each benchmark.
+	benchmarkDb.once.Do(func() {
+		_ = os.Remove(benchmarkDbFile)
+		benchmarkDb.err = seedBenchmarkEvents(benchmarkDbFile, benchmarkEventCount, benchmarkRoutingKeys)
+	})
+	if benchmarkDb.err != nil {
+		b.Fatal(benchmarkDb.err)
+	}
+	data, err := ioutil.ReadFile(benchmarkDbFile)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := ioutil.WriteFile(tmpDbFile, data, 0600); err != nil {
+		b.Fatal(err)
+	}
+
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(NewMockEventQueue()))
+	if err := q.Start(); err != nil {
+		b.Fatal(err)
+	}
+	return q
+}
+
+func teardownBenchmarkQueue(b *testing.B, q *PersistentQueue) {
+	if err := q.Shutdown(); err != nil {
+		b.Fatal(err)
+	}
+	teardown(b)
+}
+
+// seedEvents writes completed events directly to the database without
+// maintaining status counters, as an older agent version would have.
+func seedEvents(file string, count, routingKeys int) error {
+	db, err := storm.Open(file)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.From("events").Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := 0; i < count; i++ {
+		rk := benchmarkRoutingKey(i % routingKeys)
+		e, err := NewEvent(newTestEventContainer(rk))
+		if err != nil {
+			return err
+		}
+		e.Status = StatusSuccess
+		e.CreatedAt = time.Now()
+		if err := tx.Save(e); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// seedBenchmarkEvents writes completed events for benchmarks through the
+// same path as the queue, maintaining indexes and status counters.
+func seedBenchmarkEvents(file string, count, routingKeys int) error {
+	db, err := storm.Open(file)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return withTx(db, func(tx *eventTx) error {
+		for i := 0; i < count; i++ {
+			e, err := NewEvent(newTestEventContainer(benchmarkRoutingKey(i % routingKeys)))
+			if err != nil {
+				return err
+			}
+			e.Status = StatusSuccess
+			if err := e.create(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func benchmarkRoutingKey(i int) string {
+	return fmt.Sprintf("%032d", i)
+}
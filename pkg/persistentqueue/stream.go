@@ -0,0 +1,199 @@
+package persistentqueue
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+)
+
+// Lifecycle transitions of queued events, see `Subscribe`.
+const (
+	// TransitionAccepted is published once an event is stored, pending.
+	TransitionAccepted = "accepted"
+
+	// TransitionDispatched is published when an event is handed off to be
+	// sent, including when replayed on start.
+	TransitionDispatched = "dispatched"
+
+	// TransitionRetried is published when a failed event is retried, before
+	// it's dispatched again.
+	TransitionRetried = "retried"
+
+	TransitionSucceeded = "succeeded"
+	TransitionFailed    = "failed"
+	TransitionExpired   = "expired"
+	TransitionSkipped   = "skipped"
+)
+
+// Most recent transitions kept for subscribers resuming a stream, and how
+// many more a subscriber may fall behind by before it's dropped.
+const (
+	transitionHistory = 1024
+	subscriberBuffer  = 256
+)
+
+// SubscribeNew is given to `Subscribe` to only receive transitions published
+// after subscribing.
+const SubscribeNew = math.MaxUint64
+
+// Transition is a change in a queued event's lifecycle. IDs increase with
+// each transition published since the queue was created.
+type Transition struct {
+	ID         uint64    `json:"id"`
+	Type       string    `json:"type"`
+	Key        string    `json:"key"`
+	RoutingKey string    `json:"routing_key"`
+	Status     string    `json:"status"`
+	Response   string    `json:"response,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// transitions publishes transitions to subscribers. It has its own lock, as
+// transitions are published both with and without `q.mu` held.
+type transitions struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []Transition
+	subscribers map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	routingKey string
+	c          chan Transition
+}
+
+// Subscription receives transitions on `C` until it's cancelled.
+type Subscription struct {
+	C <-chan Transition
+
+	// ID of the last transition published before subscribing.
+	Since uint64
+
+	cancel func()
+}
+
+// Cancel ends the subscription, closing `C`.
+func (s *Subscription) Cancel() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+// Subscribe subscribes to transitions of events for the routing key, or for
+// all routing keys if it's empty.
+//
+// Transitions after the ID `after` are sent first if they're still kept,
+// none with `SubscribeNew`. Rather than hold up the queue, subscribers falling too far
+// behind are dropped, closing their channel.
+func (q *PersistentQueue) Subscribe(routingKey string, after uint64) *Subscription {
+	t := &q.transitions
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var missed []Transition
+	for _, tr := range t.history {
+		if tr.ID > after && (routingKey == "" || tr.RoutingKey == routingKey) {
+			missed = append(missed, tr)
+		}
+	}
+
+	s := &subscriber{routingKey: routingKey, c: make(chan Transition, len(missed)+subscriberBuffer)}
+	for _, tr := range missed {
+		s.c <- tr
+	}
+	if t.subscribers == nil {
+		t.subscribers = map[*subscriber]struct{}{}
+	}
+	t.subscribers[s] = struct{}{}
+
+	return &Subscription{
+		C:     s.c,
+		Since: t.lastID,
+		cancel: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.drop(s)
+		},
+	}
+}
+
+// drop removes a subscriber, if it hasn't already been. Callers must hold
+// `t.mu`.
+func (t *transitions) drop(s *subscriber) {
+	if _, ok := t.subscribers[s]; ok {
+		delete(t.subscribers, s)
+		close(s.c)
+	}
+}
+
+// publish records an event's transition, sending it to subscribers.
+func (q *PersistentQueue) publish(kind string, e *Event, response string) {
+	t := &q.transitions
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lastID++
+	tr := Transition{
+		ID:         t.lastID,
+		Type:       kind,
+		Key:        e.Key,
+		RoutingKey: e.RoutingKey,
+		Status:     e.Status,
+		Response:   response,
+		Time:       time.Now(),
+	}
+
+	t.history = append(t.history, tr)
+	if len(t.history) > transitionHistory {
+		t.history = t.history[len(t.history)-transitionHistory:]
+	}
+
+	for s := range t.subscribers {
+		if s.routingKey != "" && s.routingKey != tr.RoutingKey {
+			continue
+		}
+		select {
+		case s.c <- tr:
+		default:
+			q.logger.Warnf("Dropping subscriber to transitions that fell behind.")
+			t.drop(s)
+		}
+	}
+}
+
+// responseSummary summarizes the outcome of sending an event, e.g.
+// `202 success: Event processed`.
+func responseSummary(resp eventqueue.Response) string {
+	var parts []string
+	if resp.Response != nil {
+		if httpResp := resp.Response.GetHTTPResponse(); httpResp != nil {
+			parts = append(parts, fmt.Sprint(httpResp.StatusCode))
+		}
+
+		var body struct {
+			Status  string   `json:"status"`
+			Message string   `json:"message"`
+			Errors  []string `json:"errors"`
+		}
+		if data, err := json.Marshal(resp.Response); err == nil && json.Unmarshal(data, &body) == nil {
+			if body.Status != "" {
+				parts = append(parts, body.Status+":")
+			}
+			if body.Message != "" {
+				parts = append(parts, body.Message)
+			}
+			if len(body.Errors) > 0 {
+				parts = append(parts, "("+strings.Join(body.Errors, "; ")+")")
+			}
+		}
+	}
+	if resp.Error != nil && len(parts) == 0 {
+		parts = append(parts, resp.Error.Error())
+	}
+	return strings.TrimSuffix(strings.Join(parts, " "), ":")
+}
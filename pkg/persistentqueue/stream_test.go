@@ -0,0 +1,90 @@
+package persistentqueue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func nextTransition(t *testing.T, c <-chan Transition) Transition {
+	select {
+	case tr := <-c:
+		return tr
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for transition")
+		return Transition{}
+	}
+}
+
+func TestPersistentQueueSubscribe(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := &heldEventQueue{held: make(chan chan<- eventqueue.Response, 1)}
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	all := q.Subscribe("", SubscribeNew)
+	defer all.Cancel()
+	other := q.Subscribe("a0000000000000000000000000000000", SubscribeNew)
+	defer other.Cancel()
+	assert.Equal(t, uint64(0), all.Since)
+
+	key, err := q.Enqueue(newTestEventContainer("11863b592c824bfc8989d9cba76abcde"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	(<-eq.held) <- eventqueue.Response{
+		Response: &eventsapi.ResponseV2{Status: "invalid event", Message: "Event object is invalid", Errors: []string{"'payload.summary' is missing"}},
+		Error:    errors.New("bad request"),
+	}
+
+	accepted := nextTransition(t, all.C)
+	assert.Equal(t, TransitionAccepted, accepted.Type)
+	assert.Equal(t, key, accepted.Key)
+	assert.Equal(t, "11863b592c824bfc8989d9cba76abcde", accepted.RoutingKey)
+	assert.Equal(t, StatusPending, accepted.Status)
+	assert.Equal(t, TransitionDispatched, nextTransition(t, all.C).Type)
+
+	failed := nextTransition(t, all.C)
+	assert.Equal(t, TransitionFailed, failed.Type)
+	assert.Equal(t, StatusError, failed.Status)
+	assert.Equal(t, "invalid event: Event object is invalid ('payload.summary' is missing)", failed.Response)
+
+	if _, err := q.Retry(""); err != nil {
+		t.Fatal(err)
+	}
+	(<-eq.held) <- eventqueue.Response{Response: &eventsapi.ResponseV2{Status: "success", Message: "Event processed"}}
+
+	assert.Equal(t, TransitionRetried, nextTransition(t, all.C).Type)
+	assert.Equal(t, TransitionDispatched, nextTransition(t, all.C).Type)
+	succeeded := nextTransition(t, all.C)
+	assert.Equal(t, TransitionSucceeded, succeeded.Type)
+	assert.Equal(t, "success: Event processed", succeeded.Response)
+
+	// Filtered by routing key.
+	select {
+	case tr := <-other.C:
+		t.Fatalf("unexpected transition %+v", tr)
+	default:
+	}
+
+	// Resuming replays transitions after the given ID.
+	resumed := q.Subscribe("", failed.ID)
+	defer resumed.Cancel()
+	assert.Equal(t, succeeded.ID, resumed.Since)
+	assert.Equal(t, TransitionRetried, nextTransition(t, resumed.C).Type)
+	assert.Equal(t, TransitionDispatched, nextTransition(t, resumed.C).Type)
+	assert.Equal(t, succeeded.ID, nextTransition(t, resumed.C).ID)
+
+	all.Cancel()
+	_, ok := <-all.C
+	assert.False(t, ok)
+}
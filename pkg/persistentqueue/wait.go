@@ -0,0 +1,70 @@
+package persistentqueue
+
+import (
+	"context"
+)
+
+// Wait blocks until the event with the given key is no longer pending, or
+// the context is done, returning the event as last stored.
+//
+// The event remains queued if the context is done first, in which case it's
+// returned still pending along with the context's error.
+func (q *PersistentQueue) Wait(ctx context.Context, key string) (*Event, error) {
+	done := q.watch(key)
+	defer q.unwatch(key, done)
+
+	// Registered before reading, so an update after the read isn't missed.
+	e, err := FindEventByKey(q.Events, key)
+	if err != nil || e.Status != StatusPending {
+		return e, err
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return e, ctx.Err()
+	}
+	return FindEventByKey(q.Events, key)
+}
+
+// watch returns a channel that's closed once the event with the given key
+// leaves the pending status.
+func (q *PersistentQueue) watch(key string) chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	done := make(chan struct{})
+	q.watchers[key] = append(q.watchers[key], done)
+	return done
+}
+
+// unwatch removes a channel returned by `watch`, if it hasn't been notified.
+func (q *PersistentQueue) unwatch(key string, done chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	watchers := q.watchers[key]
+	for i, w := range watchers {
+		if w == done {
+			watchers = append(watchers[:i], watchers[i+1:]...)
+			break
+		}
+	}
+	if len(watchers) == 0 {
+		delete(q.watchers, key)
+	} else {
+		q.watchers[key] = watchers
+	}
+}
+
+// notify wakes anything waiting on an event, once its status has been
+// updated.
+func (q *PersistentQueue) notify(e *Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, done := range q.watchers[e.Key] {
+		close(done)
+	}
+	delete(q.watchers, e.Key)
+}
@@ -0,0 +1,76 @@
+package persistentqueue
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// heldEventQueue holds events until told to respond to them.
+type heldEventQueue struct {
+	held chan chan<- eventqueue.Response
+}
+
+func (q *heldEventQueue) Enqueue(_ *eventsapi.EventContainer, c chan<- eventqueue.Response, _ ...eventqueue.JobOption) error {
+	q.held <- c
+	return nil
+}
+
+func (q *heldEventQueue) Shutdown() {}
+
+func TestPersistentQueueWait(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	eq := &heldEventQueue{held: make(chan chan<- eventqueue.Response, 1)}
+	q := NewPersistentQueue(WithFile(tmpDbFile), WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	key, err := q.Enqueue(newTestEventContainer("11863b592c824bfc8989d9cba76abcde"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	respChan := <-eq.held
+
+	// Times out while the event is held, leaving it pending.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	e, err := q.Wait(ctx, key)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, StatusPending, e.Status)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		respChan <- eventqueue.Response{Response: &eventsapi.ResponseV2{
+			Status:   "success",
+			Message:  "Event processed",
+			DedupKey: "srv01/HTTP",
+		}}
+	}()
+
+	e, err = q.Wait(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, StatusSuccess, e.Status)
+
+	var resp eventsapi.ResponseV2
+	if err := json.Unmarshal(e.ResponseBody, &resp); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "srv01/HTTP", resp.DedupKey)
+
+	// Returns immediately once the event has been sent.
+	e, err = q.Wait(context.Background(), key)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusSuccess, e.Status)
+	assert.Empty(t, q.watchers)
+}
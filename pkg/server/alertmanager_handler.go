@@ -0,0 +1,270 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/metrics"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+// AlertmanagerConfig configures how alerts received from Prometheus
+// Alertmanager are routed.
+//
+// Each alert's routing key is taken from its `RoutingKeyLabel` label if set,
+// then from `Receivers` by the name of the Alertmanager receiver, and
+// otherwise is `RoutingKey`. Receiver names are matched case-insensitively,
+// as configuration keys are normally lowercased when read.
+type AlertmanagerConfig struct {
+	RoutingKey      string            `mapstructure:"routing_key"`
+	RoutingKeyLabel string            `mapstructure:"routing_key_label"`
+	Receivers       map[string]string `mapstructure:"receivers"`
+}
+
+// WithAlertmanager configures the Alertmanager webhook receiver.
+func WithAlertmanager(config AlertmanagerConfig) Option {
+	return func(s *Server) {
+		s.alertmanager = config
+	}
+}
+
+// routingKey returns the routing key for an alert, or an empty string if
+// none is configured.
+func (c *AlertmanagerConfig) routingKey(receiver string, alert AlertmanagerAlert) string {
+	if c.RoutingKeyLabel != "" {
+		if key := alert.Labels[c.RoutingKeyLabel]; key != "" {
+			return key
+		}
+	}
+	for name, key := range c.Receivers {
+		if strings.EqualFold(name, receiver) {
+			return key
+		}
+	}
+	return c.RoutingKey
+}
+
+// AlertmanagerMessage is the payload of Alertmanager's webhook notifications.
+type AlertmanagerMessage struct {
+	Version           string              `json:"version"`
+	GroupKey          string              `json:"groupKey"`
+	Status            string              `json:"status"`
+	Receiver          string              `json:"receiver"`
+	GroupLabels       map[string]string   `json:"groupLabels"`
+	CommonLabels      map[string]string   `json:"commonLabels"`
+	CommonAnnotations map[string]string   `json:"commonAnnotations"`
+	ExternalURL       string              `json:"externalURL"`
+	Alerts            []AlertmanagerAlert `json:"alerts"`
+}
+
+// AlertmanagerAlert is a single alert within an Alertmanager notification.
+type AlertmanagerAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     string            `json:"startsAt"`
+	EndsAt       string            `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// AlertmanagerHandler accepts Alertmanager webhook notifications, queuing an
+// event for each alert.
+//
+// Alerts that can't be converted, e.g. as no routing key is configured for
+// them, are reported individually without preventing the others from being
+// queued. Alertmanager retries notifications that fail with a 5xx status.
+func (s *Server) AlertmanagerHandler(rw http.ResponseWriter, req *http.Request) {
+	body, err := readBody(req)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+
+	var message AlertmanagerMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		metrics.SendRequests.WithLabelValues("rejected").Inc()
+		errorResp(rw, 400, []string{fmt.Sprintf("Invalid Alertmanager notification: %v", err)})
+		return
+	}
+
+	s.logger.Debugf("/integrations/alertmanager with %v alerts for receiver %v", len(message.Alerts), message.Receiver)
+
+	s.mu.RLock()
+	config := s.alertmanager
+	s.mu.RUnlock()
+
+	results := make([]BatchResult, len(message.Alerts))
+	var valid []*eventsapi.EventContainer
+	var indexes []int
+	for i, alert := range message.Alerts {
+		eventContainer, err := s.alertEvent(req, &config, &message, alert)
+		if err != nil {
+			metrics.SendRequests.WithLabelValues("rejected").Inc()
+			results[i].Errors = []string{err.Error()}
+			continue
+		}
+		valid = append(valid, eventContainer)
+		indexes = append(indexes, i)
+	}
+
+	if len(valid) > 0 {
+		var options []persistentqueue.EventOption
+		if token := requestToken(req); token != nil {
+			options = append(options, persistentqueue.WithToken(token.Name))
+		}
+
+		keys, err := s.Queue.EnqueueBatch(valid, options...)
+		if err != nil {
+			metrics.SendRequests.WithLabelValues("rejected").Add(float64(len(valid)))
+			errorResp(rw, 500, []string{err.Error()})
+			return
+		}
+		metrics.SendRequests.WithLabelValues("accepted").Add(float64(len(keys)))
+
+		for i, key := range keys {
+			results[indexes[i]].Key = key
+		}
+	}
+
+	okResp(rw, SendBatchResponse{Results: results})
+}
+
+// alertEvent converts an alert to a V2 event, checking the request's token
+// may send it.
+func (s *Server) alertEvent(req *http.Request, config *AlertmanagerConfig, message *AlertmanagerMessage, alert AlertmanagerAlert) (*eventsapi.EventContainer, error) {
+	event, err := convertAlert(config, message, alert)
+	if err != nil {
+		return nil, err
+	}
+	if err := event.Validate(); err != nil {
+		return nil, err
+	}
+
+	if token := requestToken(req); token != nil && !token.AllowsRoutingKey(event.RoutingKey) {
+		return nil, fmt.Errorf("token isn't allowed routing key %v", event.RoutingKey)
+	}
+
+	return eventsapi.NewEventContainer(event)
+}
+
+// convertAlert converts an alert to a V2 event, triggering firing alerts and
+// resolving resolved alerts with the alert's fingerprint as the dedup key.
+func convertAlert(config *AlertmanagerConfig, message *AlertmanagerMessage, alert AlertmanagerAlert) (*eventsapi.EventV2, error) {
+	var action string
+	switch alert.Status {
+	case "firing":
+		action = "trigger"
+	case "resolved":
+		action = "resolve"
+	default:
+		return nil, fmt.Errorf("alert has unknown status %q", alert.Status)
+	}
+
+	routingKey := config.routingKey(message.Receiver, alert)
+	if routingKey == "" {
+		return nil, fmt.Errorf("no routing key configured for receiver %q", message.Receiver)
+	}
+
+	dedupKey := alert.Fingerprint
+	if dedupKey == "" {
+		dedupKey = labelsFingerprint(alert.Labels)
+	}
+
+	event := eventsapi.EventV2{
+		RoutingKey:  routingKey,
+		EventAction: action,
+		DedupKey:    dedupKey,
+		Client:      "Alertmanager",
+		ClientUrl:   message.ExternalURL,
+		Payload: eventsapi.PayloadV2{
+			Summary:   alertSummary(alert),
+			Source:    alertSource(message, alert),
+			Severity:  alertSeverity(alert.Labels["severity"]),
+			Timestamp: alert.StartsAt,
+			Class:     alert.Labels["alertname"],
+			Group:     alert.Labels["job"],
+			CustomDetails: map[string]interface{}{
+				"labels":      alert.Labels,
+				"annotations": alert.Annotations,
+				"receiver":    message.Receiver,
+				"starts_at":   alert.StartsAt,
+				"ends_at":     alert.EndsAt,
+			},
+		},
+	}
+	if alert.GeneratorURL != "" {
+		event.Links = []eventsapi.LinkV2{{Href: alert.GeneratorURL, Text: "Source"}}
+	}
+	return &event, nil
+}
+
+// alertSummary uses the alert's summary or description annotation, falling
+// back to its name and labels.
+func alertSummary(alert AlertmanagerAlert) string {
+	summary := alert.Annotations["summary"]
+	if summary == "" {
+		summary = alert.Annotations["description"]
+	}
+	if summary == "" {
+		var labels []string
+		for name, value := range alert.Labels {
+			if name != "alertname" {
+				labels = append(labels, fmt.Sprintf("%v=%v", name, value))
+			}
+		}
+		sort.Strings(labels)
+		summary = strings.TrimSpace(fmt.Sprintf("%v %v", alert.Labels["alertname"], strings.Join(labels, " ")))
+	}
+
+	return eventsapi.TruncateSummary(summary)
+}
+
+// alertSource uses the alert's instance or job label, falling back to the
+// Alertmanager's URL.
+func alertSource(message *AlertmanagerMessage, alert AlertmanagerAlert) string {
+	for _, label := range []string{"instance", "job"} {
+		if source := alert.Labels[label]; source != "" {
+			return source
+		}
+	}
+	if message.ExternalURL != "" {
+		return message.ExternalURL
+	}
+	return "Alertmanager"
+}
+
+// alertSeverity maps an alert's severity label to an Events API severity,
+// defaulting to error.
+func alertSeverity(label string) string {
+	switch strings.ToLower(label) {
+	case "critical", "page":
+		return "critical"
+	case "warning", "warn":
+		return "warning"
+	case "info", "informational", "none":
+		return "info"
+	}
+	return "error"
+}
+
+// labelsFingerprint identifies an alert by its labels, for alerts without a
+// fingerprint.
+func labelsFingerprint(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%v\xff%v\xff", name, labels[name])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))[:16]
+}
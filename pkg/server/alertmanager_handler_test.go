@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAlertmanagerMessage = `{
+	"version": "4",
+	"status": "firing",
+	"receiver": "Team-A",
+	"externalURL": "http://alertmanager:9093",
+	"alerts": [
+		{
+			"status": "firing",
+			"labels": {"alertname": "HighLatency", "instance": "web-1:9100", "job": "web", "severity": "warn"},
+			"annotations": {"summary": "Latency is high on web-1"},
+			"startsAt": "2020-01-01T00:00:00Z",
+			"generatorURL": "http://prometheus:9090/graph",
+			"fingerprint": "c6e1f3a9b2d4e5f6"
+		},
+		{
+			"status": "resolved",
+			"labels": {"alertname": "DiskFull", "routing_key": "11111111111111111111111111111111"},
+			"annotations": {},
+			"startsAt": "2020-01-01T00:00:00Z"
+		},
+		{
+			"status": "unknown",
+			"labels": {"alertname": "Other"}
+		}
+	]
+}`
+
+func postAlertmanager(s *Server, token, body string) (int, SendBatchResponse) {
+	req := httptest.NewRequest("POST", "/integrations/alertmanager", strings.NewReader(body))
+	req.Header.Set("Authorization", "token "+token)
+	rw := httptest.NewRecorder()
+	s.HTTPServer.Handler.ServeHTTP(rw, req)
+
+	var resp SendBatchResponse
+	_ = json.Unmarshal(rw.Body.Bytes(), &resp)
+	return rw.Code, resp
+}
+
+func TestAlertmanagerHandler(t *testing.T) {
+	s, q := newTestServer("secret")
+	WithAlertmanager(AlertmanagerConfig{
+		RoutingKeyLabel: "routing_key",
+		Receivers:       map[string]string{"team-a": testRoutingKey},
+	})(s)
+
+	code, resp := postAlertmanager(s, "secret", testAlertmanagerMessage)
+	assert.Equal(t, 200, code)
+	assert.Len(t, resp.Results, 3)
+	assert.NotEmpty(t, resp.Results[0].Key)
+	assert.NotEmpty(t, resp.Results[1].Key)
+	assert.Equal(t, []string{`alert has unknown status "unknown"`}, resp.Results[2].Errors)
+	assert.Len(t, q.events, 2)
+
+	var firing eventsapi.EventV2
+	assert.NoError(t, json.Unmarshal(q.events[0].EventData, &firing))
+	assert.Equal(t, testRoutingKey, firing.RoutingKey)
+	assert.Equal(t, "trigger", firing.EventAction)
+	assert.Equal(t, "c6e1f3a9b2d4e5f6", firing.DedupKey)
+	assert.Equal(t, "Latency is high on web-1", firing.Payload.Summary)
+	assert.Equal(t, "web-1:9100", firing.Payload.Source)
+	assert.Equal(t, "warning", firing.Payload.Severity)
+	assert.Equal(t, "HighLatency", firing.Payload.Class)
+	assert.Equal(t, []eventsapi.LinkV2{{Href: "http://prometheus:9090/graph", Text: "Source"}}, firing.Links)
+
+	var resolved eventsapi.EventV2
+	assert.NoError(t, json.Unmarshal(q.events[1].EventData, &resolved))
+	assert.Equal(t, "11111111111111111111111111111111", resolved.RoutingKey)
+	assert.Equal(t, "resolve", resolved.EventAction)
+	assert.Equal(t, labelsFingerprint(map[string]string{"alertname": "DiskFull", "routing_key": "11111111111111111111111111111111"}), resolved.DedupKey)
+	assert.Equal(t, "DiskFull routing_key=11111111111111111111111111111111", resolved.Payload.Summary)
+	assert.Equal(t, "http://alertmanager:9093", resolved.Payload.Source)
+
+	code, _ = postAlertmanager(s, "secret", `{"alerts": [`)
+	assert.Equal(t, 400, code)
+}
+
+func TestAlertmanagerHandlerRouting(t *testing.T) {
+	s, q := newTestServer("secret")
+
+	code, resp := postAlertmanager(s, "secret", testAlertmanagerMessage)
+	assert.Equal(t, 200, code)
+	assert.Equal(t, []string{`no routing key configured for receiver "Team-A"`}, resp.Results[0].Errors)
+	assert.Empty(t, q.events)
+
+	if err := s.Reconfigure(Settings{
+		Secret:       "secret",
+		Tokens:       []Token{{Name: "ci", Token: "ci-token", Scopes: []string{ScopeSend}, RoutingKeys: []string{testRoutingKey}}},
+		Alertmanager: AlertmanagerConfig{RoutingKey: testRoutingKey},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	code, resp = postAlertmanager(s, "ci-token", testAlertmanagerMessage)
+	assert.Equal(t, 200, code)
+	assert.NotEmpty(t, resp.Results[0].Key)
+	assert.NotEmpty(t, resp.Results[1].Key)
+	assert.Len(t, q.events, 2)
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Default largest request body accepted.
+const defaultMaxRequestSize = 10 * 1024 * 1024
+
+// APIConfig limits requests to the server's API.
+//
+// Request bodies larger than `MaxRequestSize` bytes are rejected, 10MiB by
+// default. With a rate limit, each token (or, without tokens, each client
+// address) may make at most `RateLimit.Requests` requests in each
+// `RateLimit.Interval`, further requests being rejected until the interval
+// elapses. Health checks aren't rate limited.
+type APIConfig struct {
+	MaxRequestSize int64           `mapstructure:"max_request_size"`
+	RateLimit      RateLimitConfig `mapstructure:"rate_limit"`
+}
+
+type RateLimitConfig struct {
+	Requests int           `mapstructure:"requests"`
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+func (c APIConfig) validate() error {
+	if c.MaxRequestSize < 0 {
+		return fmt.Errorf("api max_request_size must not be negative")
+	}
+	if c.RateLimit.Requests > 0 && c.RateLimit.Interval <= 0 {
+		return fmt.Errorf("api rate_limit needs a positive interval")
+	}
+	return nil
+}
+
+// WithAPI sets the server's API request limits.
+func WithAPI(config APIConfig) Option {
+	return func(s *Server) {
+		s.api = config
+	}
+}
+
+func (s *Server) maxRequestSize() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.api.MaxRequestSize > 0 {
+		return s.api.MaxRequestSize
+	}
+	return defaultMaxRequestSize
+}
+
+// limitRequests rejects requests over the configured rate limit, if any.
+func (s *Server) limitRequests(handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		s.mu.RLock()
+		config := s.api.RateLimit
+		s.mu.RUnlock()
+
+		if config.Requests <= 0 {
+			handler(rw, req)
+			return
+		}
+
+		client := req.RemoteAddr
+		if host, _, err := net.SplitHostPort(client); err == nil {
+			client = host
+		}
+		if token := requestToken(req); token != nil {
+			client = "token:" + token.Name
+		}
+
+		if ok, retryAfter := s.limiter.allow(client, config, time.Now()); !ok {
+			s.logger.Infof("Rate limiting %v for %v.", client, req.URL.Path)
+			rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			errorResp(rw, 429, []string{fmt.Sprintf("Too many requests, at most %v are allowed every %v.", config.Requests, config.Interval)})
+			return
+		}
+		handler(rw, req)
+	}
+}
+
+// rateLimiter counts each client's requests in fixed windows, shared by all
+// clients so that counts are discarded together.
+type rateLimiter struct {
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+// allow counts a request from the client, returning false and how long until
+// the current window ends if it's over the limit.
+func (l *rateLimiter) allow(client string, config RateLimitConfig, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.counts == nil || now.Sub(l.start) >= config.Interval || now.Before(l.start) {
+		l.start = now
+		l.counts = map[string]int{}
+	}
+
+	l.counts[client]++
+	if l.counts[client] > config.Requests {
+		return false, l.start.Add(config.Interval).Sub(now)
+	}
+	return true, 0
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func apiRequest(s *Server, method, path string, body io.Reader) (*httptest.ResponseRecorder, ErrorResponse) {
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Authorization", "token secret")
+	rw := httptest.NewRecorder()
+	s.HTTPServer.Handler.ServeHTTP(rw, req)
+
+	var resp ErrorResponse
+	_ = json.Unmarshal(rw.Body.Bytes(), &resp)
+	return rw, resp
+}
+
+func TestRouterAPIV1(t *testing.T) {
+	s, q := newTestServer("secret")
+	event := `{"routing_key": "` + testRoutingKey + `", "event_action": "trigger"}`
+
+	rw, _ := apiRequest(s, "POST", "/api/v1/send", strings.NewReader(event))
+	assert.Equal(t, 200, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+	assert.Len(t, q.events, 1)
+
+	rw, resp := apiRequest(s, "GET", "/api/v1/send", nil)
+	assert.Equal(t, 405, rw.Code)
+	assert.Equal(t, "POST", rw.Header().Get("Allow"))
+	assert.Equal(t, "method_not_allowed", resp.Code)
+
+	rw, _ = apiRequest(s, "DELETE", "/api/v1/integrations/webhook/deploys", nil)
+	assert.Equal(t, 405, rw.Code)
+	assert.Equal(t, "POST", rw.Header().Get("Allow"))
+
+	rw, resp = apiRequest(s, "GET", "/api/v1/queue/missing", nil)
+	assert.Equal(t, 404, rw.Code)
+	assert.Equal(t, "not_found", resp.Code)
+	assert.Equal(t, "Not found, no endpoint at /api/v1/queue/missing.", resp.Message)
+
+	// Unversioned aliases accept any method.
+	rw, _ = apiRequest(s, "PUT", "/send", strings.NewReader(event))
+	assert.Equal(t, 200, rw.Code)
+	assert.Len(t, q.events, 2)
+
+	req := httptest.NewRequest("POST", "/api/v1/send", strings.NewReader(event))
+	rw = httptest.NewRecorder()
+	s.HTTPServer.Handler.ServeHTTP(rw, req)
+	_ = json.Unmarshal(rw.Body.Bytes(), &resp)
+	assert.Equal(t, 401, rw.Code)
+	assert.Equal(t, "unauthorized", resp.Code)
+}
+
+func TestSendHandlerEventVersion(t *testing.T) {
+	s, q := newTestServer("secret")
+
+	// Without a Pd-Event-Version header, the version is determined by the
+	// event's fields.
+	rw, _ := apiRequest(s, "POST", "/api/v1/send", strings.NewReader(`{"routing_key": "`+testRoutingKey+`", "event_action": "trigger"}`))
+	assert.Equal(t, 200, rw.Code)
+	rw, _ = apiRequest(s, "POST", "/api/v1/send", strings.NewReader(`{"service_key": "`+testRoutingKey+`", "event_type": "trigger"}`))
+	assert.Equal(t, 200, rw.Code)
+	if assert.Len(t, q.events, 2) {
+		assert.Equal(t, "v2", q.events[0].EventVersion.String())
+		assert.Equal(t, "v1", q.events[1].EventVersion.String())
+	}
+
+	rw, resp := apiRequest(s, "POST", "/api/v1/send", strings.NewReader(`{"summary": "unknown"}`))
+	assert.Equal(t, 400, rw.Code)
+	assert.Equal(t, "invalid_request", resp.Code)
+
+	rw, resp = apiRequest(s, "POST", "/api/v1/send", strings.NewReader(`{"routing_key": "short", "event_action": "trigger"}`))
+	assert.Equal(t, 400, rw.Code)
+	assert.Contains(t, resp.Message, "Invalid event")
+
+	req := httptest.NewRequest("POST", "/api/v1/send", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "token secret")
+	req.Header.Set("Pd-Event-Version", "v3")
+	rw = httptest.NewRecorder()
+	s.HTTPServer.Handler.ServeHTTP(rw, req)
+	assert.Equal(t, 400, rw.Code)
+	assert.Len(t, q.events, 2)
+}
+
+func TestAPIMaxRequestSize(t *testing.T) {
+	s, q := newTestServer("secret")
+	assert.NoError(t, s.Reconfigure(Settings{Secret: "secret", API: APIConfig{MaxRequestSize: 64}}))
+	event := `{"routing_key": "` + testRoutingKey + `", "event_action": "trigger", "dedup_key": "disk-full"}`
+
+	rw, resp := apiRequest(s, "POST", "/api/v1/send", strings.NewReader(event))
+	assert.Equal(t, 413, rw.Code)
+	assert.Equal(t, "request_too_large", resp.Code)
+
+	// Bodies of unknown length fail once they exceed the limit.
+	req := httptest.NewRequest("POST", "/api/v1/send/batch", strings.NewReader(event))
+	req.Header.Set("Authorization", "token secret")
+	req.ContentLength = -1
+	rw = httptest.NewRecorder()
+	s.HTTPServer.Handler.ServeHTTP(rw, req)
+	assert.Equal(t, 413, rw.Code)
+	assert.Empty(t, q.events)
+
+	rw, _ = apiRequest(s, "POST", "/api/v1/send", strings.NewReader(`{"routing_key": "`+testRoutingKey+`"}`))
+	assert.Equal(t, 200, rw.Code)
+
+	assert.Error(t, s.Reconfigure(Settings{API: APIConfig{MaxRequestSize: -1}}))
+}
+
+func TestAPIRateLimit(t *testing.T) {
+	s, q := newTestServer("secret")
+	assert.NoError(t, s.Reconfigure(Settings{Secret: "secret", API: APIConfig{RateLimit: RateLimitConfig{Requests: 2, Interval: time.Minute}}}))
+	event := `{"routing_key": "` + testRoutingKey + `", "event_action": "trigger"}`
+
+	for i := 0; i < 2; i++ {
+		rw, _ := apiRequest(s, "POST", "/api/v1/send", strings.NewReader(event))
+		assert.Equal(t, 200, rw.Code)
+	}
+	rw, resp := apiRequest(s, "POST", "/send", strings.NewReader(event))
+	assert.Equal(t, 429, rw.Code)
+	assert.Equal(t, "rate_limited", resp.Code)
+	assert.Equal(t, "60", rw.Header().Get("Retry-After"))
+	assert.Len(t, q.events, 2)
+
+	assert.Error(t, s.Reconfigure(Settings{API: APIConfig{RateLimit: RateLimitConfig{Requests: 2}}}))
+}
+
+func TestRateLimiter(t *testing.T) {
+	var l rateLimiter
+	config := RateLimitConfig{Requests: 1, Interval: time.Minute}
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	ok, _ := l.allow("a", config, now)
+	assert.True(t, ok)
+	ok, _ = l.allow("b", config, now)
+	assert.True(t, ok)
+
+	ok, retryAfter := l.allow("a", config, now.Add(15*time.Second))
+	assert.False(t, ok)
+	assert.Equal(t, 45*time.Second, retryAfter)
+
+	ok, _ = l.allow("a", config, now.Add(time.Minute))
+	assert.True(t, ok)
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	s, _ := newTestServer("secret")
+
+	req := httptest.NewRequest("GET", "/api/v1/openapi.json", nil)
+	rw := httptest.NewRecorder()
+	s.HTTPServer.Handler.ServeHTTP(rw, req)
+	assert.Equal(t, 200, rw.Code)
+
+	var spec struct {
+		Paths map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(rw.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every route is documented with its methods.
+	for _, rt := range s.routes() {
+		for _, method := range rt.methods {
+			assert.Contains(t, spec.Paths[rt.path], strings.ToLower(method), "%v %v", method, rt.path)
+		}
+	}
+	assert.Len(t, spec.Paths, len(s.routes())+1)
+}
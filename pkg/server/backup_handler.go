@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+	"path/filepath"
+)
+
+func (s *Server) BackupHandler(rw http.ResponseWriter, req *http.Request) {
+	path := req.URL.Query().Get("path")
+
+	if !s.authorizeRoutingKey(rw, req, "") {
+		return
+	}
+
+	if path != "" && !filepath.IsAbs(path) {
+		errorResp(rw, 400, []string{"Backup path must be absolute."})
+		return
+	}
+
+	backup, err := s.Queue.Backup(path)
+	if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	okResp(rw, BackupResponse{Path: backup})
+}
+
+type BackupResponse struct {
+	Path string `json:"path"`
+}
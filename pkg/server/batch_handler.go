@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/metrics"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+// Maximum number of events accepted in a single batch.
+const maxBatchEvents = 1000
+
+// SendBatchHandler queues several events at once, given either as a JSON
+// array or as newline-delimited JSON, persisting them in a single
+// transaction.
+//
+// Each event's version is determined by its fields. Invalid events are
+// reported individually without preventing the others from being queued.
+func (s *Server) SendBatchHandler(rw http.ResponseWriter, req *http.Request) {
+	body, err := readBody(req)
+	if err != nil {
+		writeError(rw, err)
+		return
+	}
+
+	items, err := splitBatch(body)
+	if err != nil {
+		errorResp(rw, 400, []string{err.Error()})
+		return
+	}
+	if len(items) > maxBatchEvents {
+		errorResp(rw, 400, []string{fmt.Sprintf("Batches may contain at most %v events, got %v.", maxBatchEvents, len(items))})
+		return
+	}
+
+	s.logger.Debugf("/send/batch with %v events", len(items))
+
+	results := make([]BatchResult, len(items))
+	var valid []*eventsapi.EventContainer
+	var indexes []int
+	for i, item := range items {
+		eventContainer, err := s.batchEvent(req, item)
+		if err != nil {
+			metrics.SendRequests.WithLabelValues("rejected").Inc()
+			results[i].Errors = []string{err.Error()}
+			continue
+		}
+		valid = append(valid, eventContainer)
+		indexes = append(indexes, i)
+	}
+
+	if len(valid) > 0 {
+		var options []persistentqueue.EventOption
+		if token := requestToken(req); token != nil {
+			options = append(options, persistentqueue.WithToken(token.Name))
+		}
+
+		keys, err := s.Queue.EnqueueBatch(valid, options...)
+		if err != nil {
+			metrics.SendRequests.WithLabelValues("rejected").Add(float64(len(valid)))
+			errorResp(rw, 500, []string{err.Error()})
+			return
+		}
+		metrics.SendRequests.WithLabelValues("accepted").Add(float64(len(keys)))
+
+		for i, key := range keys {
+			results[indexes[i]].Key = key
+		}
+	}
+
+	okResp(rw, SendBatchResponse{Results: results})
+}
+
+// batchEvent decodes and validates a single event from a batch, checking the
+// request's token may send it.
+func (s *Server) batchEvent(req *http.Request, item json.RawMessage) (*eventsapi.EventContainer, error) {
+	version, err := batchEventVersion(item)
+	if err != nil {
+		return nil, err
+	}
+
+	eventContainer := eventsapi.EventContainer{EventVersion: version, EventData: item}
+	event, err := eventContainer.UnmarshalEvent()
+	if err != nil {
+		return nil, err
+	}
+	if err := event.Validate(); err != nil {
+		return nil, err
+	}
+
+	if token := requestToken(req); token != nil && !token.AllowsRoutingKey(event.GetRoutingKey()) {
+		return nil, fmt.Errorf("token isn't allowed routing key %v", event.GetRoutingKey())
+	}
+
+	return &eventContainer, nil
+}
+
+// splitBatch splits a batch into its events, parsing it as a JSON array if
+// it starts with `[`, and otherwise as one event per line. Lines aren't
+// parsed here, so that any malformed line is reported on its own.
+func splitBatch(body []byte) ([]json.RawMessage, error) {
+	body = bytes.TrimSpace(body)
+
+	if bytes.HasPrefix(body, []byte("[")) {
+		var items []json.RawMessage
+		err := json.Unmarshal(body, &items)
+		return items, err
+	}
+
+	var items []json.RawMessage
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			items = append(items, json.RawMessage(line))
+		}
+	}
+	return items, nil
+}
+
+// batchEventVersion determines an event's version from its fields.
+func batchEventVersion(item json.RawMessage) (eventsapi.EventVersion, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(item, &fields); err != nil {
+		return "", err
+	}
+
+	for _, field := range []string{"routing_key", "event_action"} {
+		if _, ok := fields[field]; ok {
+			return eventsapi.EventVersion2, nil
+		}
+	}
+	for _, field := range []string{"service_key", "event_type"} {
+		if _, ok := fields[field]; ok {
+			return eventsapi.EventVersion1, nil
+		}
+	}
+	return "", eventsapi.ErrUnrecognizedEventType
+}
+
+type SendBatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// BatchResult is the outcome of queuing a single event from a batch, either
+// its key or why it was rejected.
+type BatchResult struct {
+	Key    string   `json:"key,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func postBatch(s *Server, token, body string) (int, SendBatchResponse) {
+	req := httptest.NewRequest("POST", "/send/batch", strings.NewReader(body))
+	req.Header.Set("Authorization", "token "+token)
+	rw := httptest.NewRecorder()
+	s.HTTPServer.Handler.ServeHTTP(rw, req)
+
+	var resp SendBatchResponse
+	_ = json.Unmarshal(rw.Body.Bytes(), &resp)
+	return rw.Code, resp
+}
+
+func TestSendBatchHandlerArray(t *testing.T) {
+	s, q := newTestServer("secret")
+
+	code, resp := postBatch(s, "secret", `[
+		{"routing_key": "`+testRoutingKey+`", "event_action": "trigger"},
+		{"service_key": "`+testRoutingKey+`", "event_type": "trigger"},
+		{"routing_key": "short", "event_action": "trigger"},
+		{"summary": "unknown"}
+	]`)
+	assert.Equal(t, 200, code)
+	assert.Len(t, resp.Results, 4)
+	assert.NotEmpty(t, resp.Results[0].Key)
+	assert.NotEmpty(t, resp.Results[1].Key)
+	assert.Equal(t, []string{eventsapi.ErrInvalidRoutingKey.Error()}, resp.Results[2].Errors)
+	assert.Equal(t, []string{eventsapi.ErrUnrecognizedEventType.Error()}, resp.Results[3].Errors)
+
+	assert.Len(t, q.events, 2)
+	assert.Equal(t, eventsapi.EventVersion2, q.events[0].EventVersion)
+	assert.Equal(t, eventsapi.EventVersion1, q.events[1].EventVersion)
+
+	code, _ = postBatch(s, "secret", `[{"routing_key": "`)
+	assert.Equal(t, 400, code)
+}
+
+func TestSendBatchHandlerNDJSON(t *testing.T) {
+	s, q := newTestServer("secret")
+	if err := s.SetTokens([]Token{{Name: "ci", Token: "ci-token", Scopes: []string{ScopeSend}, RoutingKeys: []string{testRoutingKey}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	code, resp := postBatch(s, "ci-token", `{"routing_key": "`+testRoutingKey+`", "event_action": "trigger"}
+{"routing_key": "
+{"routing_key": "00000000000000000000000000000000", "event_action": "trigger"}
+
+`)
+	assert.Equal(t, 200, code)
+	assert.Len(t, resp.Results, 3)
+	assert.NotEmpty(t, resp.Results[0].Key)
+	assert.NotEmpty(t, resp.Results[1].Errors)
+	assert.Equal(t, []string{"token isn't allowed routing key 00000000000000000000000000000000"}, resp.Results[2].Errors)
+	assert.Len(t, q.events, 1)
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// Codes identifying the kind of error in an `ErrorResponse`, by HTTP status.
+var errorCodes = map[int]string{
+	400: "invalid_request",
+	401: "unauthorized",
+	403: "forbidden",
+	404: "not_found",
+	405: "method_not_allowed",
+	409: "conflict",
+	413: "request_too_large",
+	429: "rate_limited",
+	500: "internal_error",
+	501: "not_implemented",
+}
+
+func errorCode(status int) string {
+	if code, ok := errorCodes[status]; ok {
+		return code
+	}
+	if status >= 500 {
+		return errorCodes[500]
+	}
+	return errorCodes[400]
+}
+
+// APIError is an error with the HTTP status it's reported with. Other errors
+// are reported as internal errors.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// errorStatus returns the HTTP status an error is reported with.
+func errorStatus(err error) int {
+	if e, ok := err.(*APIError); ok {
+		return e.Status
+	}
+	return 500
+}
+
+// writeError responds with an error, using its status if it's an `APIError`.
+func writeError(rw http.ResponseWriter, err error) {
+	errorResp(rw, errorStatus(err), []string{err.Error()})
+}
+
+// readBody reads a request's body, returning an `APIError` if it can't be
+// read or is larger than the server allows.
+func readBody(req *http.Request) ([]byte, error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if _, ok := err.(*APIError); ok {
+		return nil, err
+	} else if err != nil {
+		return nil, &APIError{Status: 400, Message: fmt.Sprintf("Error reading request body: %v", err)}
+	}
+	return body, nil
+}
+
+func requestTooLarge(limit int64) *APIError {
+	return &APIError{Status: 413, Message: fmt.Sprintf("Request body is larger than the maximum of %v bytes.", limit)}
+}
+
+// limitedBody is a request body failing with a 413 `APIError` once more than
+// its limit has been read.
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, requestTooLarge(b.limit)
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = -1
+		return n, requestTooLarge(b.limit)
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func notFoundHandler(rw http.ResponseWriter, req *http.Request) {
+	errorResp(rw, 404, []string{fmt.Sprintf("Not found, no endpoint at %v.", req.URL.Path)})
+}
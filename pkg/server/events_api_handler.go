@@ -0,0 +1,217 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/metrics"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+// Paths of the Events API's own endpoints, served so that its clients can
+// send events via the agent by changing only their base URL.
+const (
+	eventsAPIPathV1 = "/generic/2010-04-15/create_event.json"
+	eventsAPIPathV2 = "/v2/enqueue"
+)
+
+// EventsAPIConfig configures the Events API-compatible endpoints.
+//
+// Like other endpoints, these require a token when the server has tokens
+// configured, unless `RequireToken` is explicitly false for Events API
+// clients that can't send one. Requests with a token are still checked
+// against its scopes and routing keys.
+type EventsAPIConfig struct {
+	RequireToken *bool `mapstructure:"require_token"`
+}
+
+// requireToken returns true unless events may be sent without a token.
+func (c EventsAPIConfig) requireToken() bool {
+	return c.RequireToken == nil || *c.RequireToken
+}
+
+// WithEventsAPI configures the Events API-compatible endpoints.
+func WithEventsAPI(config EventsAPIConfig) Option {
+	return func(s *Server) {
+		s.eventsAPI = config
+	}
+}
+
+// EventsAPIResponse mirrors the Events API's responses for V1 and V2 events.
+type EventsAPIResponse struct {
+	Status      string   `json:"status"`
+	Message     string   `json:"message"`
+	DedupKey    string   `json:"dedup_key,omitempty"`
+	IncidentKey string   `json:"incident_key,omitempty"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+// allowsAnonymous returns true if the request may be made without a token.
+func (s *Server) allowsAnonymous(req *http.Request) bool {
+	if req.URL.Path == apiPathV1+openAPIPath {
+		return true
+	}
+
+	s.mu.RLock()
+	requireToken := s.eventsAPI.requireToken()
+	s.mu.RUnlock()
+
+	if requireToken || req.Header.Get("Authorization") != "" {
+		return false
+	}
+	return req.URL.Path == eventsAPIPathV1 || req.URL.Path == eventsAPIPathV2
+}
+
+// EventsAPIV1Handler accepts V1 events as the Events API's
+// `create_event.json` endpoint would.
+func (s *Server) EventsAPIV1Handler(rw http.ResponseWriter, req *http.Request) {
+	s.eventsAPIHandler(rw, req, eventsapi.EventVersion1)
+}
+
+// EventsAPIV2Handler accepts V2 events as the Events API's `enqueue`
+// endpoint would.
+func (s *Server) EventsAPIV2Handler(rw http.ResponseWriter, req *http.Request) {
+	s.eventsAPIHandler(rw, req, eventsapi.EventVersion2)
+}
+
+// eventsAPIHandler queues an event, responding in the Events API's format.
+//
+// Events are checked as the Events API would before being queued, so that
+// clients see invalid events rejected immediately. Events without a dedup
+// (or incident) key are given one, which is returned as the Events API does.
+func (s *Server) eventsAPIHandler(rw http.ResponseWriter, req *http.Request, version eventsapi.EventVersion) {
+	body, err := readBody(req)
+	if err != nil {
+		metrics.SendRequests.WithLabelValues("rejected").Inc()
+		eventsAPIResp(rw, errorStatus(err), invalidEventResponse([]string{err.Error()}))
+		return
+	}
+
+	s.logger.Debugf("%v payload: %v", req.URL.Path, string(body))
+
+	event, errs := parseEventsAPIEvent(version, body)
+	if len(errs) > 0 {
+		metrics.SendRequests.WithLabelValues("rejected").Inc()
+		eventsAPIResp(rw, 400, invalidEventResponse(errs))
+		return
+	}
+
+	if !s.authorizeRoutingKey(rw, req, event.GetRoutingKey()) {
+		metrics.SendRequests.WithLabelValues("rejected").Inc()
+		return
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		eventsAPIResp(rw, 500, EventsAPIResponse{Status: "error", Message: err.Error()})
+		return
+	}
+
+	var options []persistentqueue.EventOption
+	if token := requestToken(req); token != nil {
+		options = append(options, persistentqueue.WithToken(token.Name))
+	}
+
+	eventContainer := eventsapi.EventContainer{EventVersion: version, EventData: data}
+	if _, err := s.Queue.Enqueue(&eventContainer, options...); err != nil {
+		metrics.SendRequests.WithLabelValues("rejected").Inc()
+		eventsAPIResp(rw, 500, EventsAPIResponse{Status: "error", Message: err.Error()})
+		return
+	}
+	metrics.SendRequests.WithLabelValues("accepted").Inc()
+
+	switch e := event.(type) {
+	case *eventsapi.EventV1:
+		eventsAPIResp(rw, 200, EventsAPIResponse{Status: "success", Message: "Event processed", IncidentKey: e.IncidentKey})
+	case *eventsapi.EventV2:
+		eventsAPIResp(rw, 202, EventsAPIResponse{Status: "success", Message: "Event processed", DedupKey: e.DedupKey})
+	}
+}
+
+// parseEventsAPIEvent decodes and checks an event, giving it a dedup (or
+// incident) key if it has none. Returns the problems found, if any.
+func parseEventsAPIEvent(version eventsapi.EventVersion, body []byte) (eventsapi.Event, []string) {
+	eventContainer := eventsapi.EventContainer{EventVersion: version, EventData: body}
+	event, err := eventContainer.UnmarshalEvent()
+	if err != nil {
+		return nil, []string{err.Error()}
+	}
+
+	var errs []string
+	if err := event.Validate(); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	switch e := event.(type) {
+	case *eventsapi.EventV1:
+		errs = append(errs, checkEventV1(e)...)
+		if e.IncidentKey == "" {
+			e.IncidentKey = common.GenerateKey()
+		}
+	case *eventsapi.EventV2:
+		errs = append(errs, checkEventV2(e)...)
+		if e.DedupKey == "" {
+			e.DedupKey = common.GenerateKey()
+		}
+	}
+
+	return event, errs
+}
+
+var eventActions = map[string]bool{"trigger": true, "acknowledge": true, "resolve": true}
+
+func checkEventV1(e *eventsapi.EventV1) []string {
+	var errs []string
+	if !eventActions[e.EventType] {
+		errs = append(errs, fmt.Sprintf("'event_type' %q is invalid, must be one of trigger, acknowledge or resolve", e.EventType))
+	}
+	if e.EventType == "trigger" {
+		if e.Description == "" {
+			errs = append(errs, "'description' is missing or blank")
+		}
+	} else if eventActions[e.EventType] && e.IncidentKey == "" {
+		errs = append(errs, "'incident_key' is required to acknowledge or resolve an incident")
+	}
+	return errs
+}
+
+func checkEventV2(e *eventsapi.EventV2) []string {
+	var errs []string
+	if !eventActions[e.EventAction] {
+		errs = append(errs, fmt.Sprintf("'event_action' %q is invalid, must be one of trigger, acknowledge or resolve", e.EventAction))
+	}
+	if e.EventAction == "trigger" {
+		if e.Payload.Summary == "" {
+			errs = append(errs, "'payload.summary' is missing or blank")
+		}
+		if e.Payload.Source == "" {
+			errs = append(errs, "'payload.source' is missing or blank")
+		}
+		if !eventsapi.ValidSeverity(e.Payload.Severity) {
+			errs = append(errs, "'payload.severity' is invalid, must be one of critical, error, warning or info")
+		}
+	} else if eventActions[e.EventAction] && e.DedupKey == "" {
+		errs = append(errs, "'dedup_key' is required to acknowledge or resolve an alert")
+	}
+	return errs
+}
+
+func invalidEventResponse(errs []string) EventsAPIResponse {
+	return EventsAPIResponse{Status: "invalid event", Message: "Event object is invalid", Errors: errs}
+}
+
+func eventsAPIResp(rw http.ResponseWriter, code int, resp EventsAPIResponse) {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		rw.WriteHeader(500)
+		_, _ = fmt.Fprint(rw, err.Error())
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	_, _ = rw.Write(body)
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/stretchr/testify/assert"
+)
+
+const testRoutingKey = "11863b592c824bfc8989d9cba76abcde"
+
+func postEventsAPI(s *Server, path, token, body string) (int, EventsAPIResponse) {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+	rw := httptest.NewRecorder()
+	s.HTTPServer.Handler.ServeHTTP(rw, req)
+
+	var resp EventsAPIResponse
+	_ = json.Unmarshal(rw.Body.Bytes(), &resp)
+	return rw.Code, resp
+}
+
+func TestEventsAPIV2Handler(t *testing.T) {
+	s, q := newTestServer("secret")
+
+	code, resp := postEventsAPI(s, "/v2/enqueue", "secret", `{
+		"routing_key": "`+testRoutingKey+`",
+		"event_action": "trigger",
+		"payload": {"summary": "Test", "source": "pdagent", "severity": "error"}
+	}`)
+	assert.Equal(t, 202, code)
+	assert.Equal(t, "success", resp.Status)
+	assert.Len(t, resp.DedupKey, 32)
+
+	// The generated dedup key is queued with the event.
+	assert.Len(t, q.events, 1)
+	event, err := q.events[0].UnmarshalEvent()
+	assert.NoError(t, err)
+	assert.Equal(t, resp.DedupKey, event.(*eventsapi.EventV2).DedupKey)
+
+	code, resp = postEventsAPI(s, "/v2/enqueue", "secret", `{
+		"routing_key": "`+testRoutingKey+`",
+		"event_action": "resolve"
+	}`)
+	assert.Equal(t, 400, code)
+	assert.Equal(t, "invalid event", resp.Status)
+	assert.Equal(t, []string{"'dedup_key' is required to acknowledge or resolve an alert"}, resp.Errors)
+	assert.Len(t, q.events, 1)
+}
+
+func TestEventsAPIV1Handler(t *testing.T) {
+	s, q := newTestServer("secret")
+
+	code, resp := postEventsAPI(s, "/generic/2010-04-15/create_event.json", "secret", `{
+		"service_key": "`+testRoutingKey+`",
+		"event_type": "acknowledge",
+		"incident_key": "abc"
+	}`)
+	assert.Equal(t, 200, code)
+	assert.Equal(t, "abc", resp.IncidentKey)
+	assert.Len(t, q.events, 1)
+	assert.Equal(t, eventsapi.EventVersion1, q.events[0].EventVersion)
+}
+
+func TestEventsAPIRequireToken(t *testing.T) {
+	s, q := newTestServer("secret")
+
+	body := `{"service_key": "` + testRoutingKey + `", "event_type": "trigger", "description": "Test"}`
+
+	// Tokens are required by default when configured.
+	code, _ := postEventsAPI(s, "/generic/2010-04-15/create_event.json", "", body)
+	assert.Equal(t, 401, code)
+	code, _ = postEventsAPI(s, "/v2/enqueue", "", `{"routing_key": "`+testRoutingKey+`", "event_action": "trigger"}`)
+	assert.Equal(t, 401, code)
+
+	code, _ = postEventsAPI(s, "/generic/2010-04-15/create_event.json", "secret", body)
+	assert.Equal(t, 200, code)
+	assert.Len(t, q.events, 1)
+
+	// Unless explicitly not required.
+	requireToken := false
+	s.eventsAPI.RequireToken = &requireToken
+	code, _ = postEventsAPI(s, "/generic/2010-04-15/create_event.json", "", body)
+	assert.Equal(t, 200, code)
+	assert.Len(t, q.events, 2)
+
+	code, _ = postEventsAPI(s, "/generic/2010-04-15/create_event.json", "wrong", body)
+	assert.Equal(t, 401, code)
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+const (
+	HealthPass = "pass"
+	HealthWarn = "warn"
+	HealthFail = "fail"
+)
+
+const (
+	defaultStalledAfter   = 30 * time.Minute
+	defaultMaxDeliveryAge = time.Hour
+)
+
+// HealthConfig sets the thresholds at which health checks fail.
+//
+// Liveness fails when events have been in flight for `StalledAfter` without
+// any being processed. Readiness additionally fails when the oldest pending
+// event is older than `MaxPendingAge`, or when events are pending and none
+// have been sent successfully within `MaxDeliveryAge`. A negative duration
+// disables a check, and zero uses its default (`MaxPendingAge` is disabled
+// by default).
+type HealthConfig struct {
+	StalledAfter   time.Duration `mapstructure:"stalled_after"`
+	MaxPendingAge  time.Duration `mapstructure:"max_pending_age"`
+	MaxDeliveryAge time.Duration `mapstructure:"max_delivery_age"`
+}
+
+// WithHealth sets the server's health check thresholds.
+func WithHealth(config HealthConfig) Option {
+	return func(s *Server) {
+		s.health = config
+	}
+}
+
+// HealthReport is the result of a server's health checks, failing if any
+// check fails.
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks"`
+}
+
+// HealthCheck is the result of checking a single component. Time is the
+// most relevant time for the check, e.g. when events were last sent.
+type HealthCheck struct {
+	Status  string     `json:"status"`
+	Message string     `json:"message"`
+	Time    *time.Time `json:"time,omitempty"`
+	Blocked []string   `json:"blocked,omitempty"`
+}
+
+// healthInputs gathers everything health checks are based on.
+type healthInputs struct {
+	config    HealthConfig
+	queue     persistentqueue.Health
+	heartbeat HeartbeatStatus
+	started   time.Time
+	now       time.Time
+}
+
+// checkHealth runs health checks, returning reports for liveness and
+// readiness, where readiness includes the liveness checks.
+func checkHealth(in healthInputs) (live, ready HealthReport) {
+	config := in.config
+	if config.StalledAfter == 0 {
+		config.StalledAfter = defaultStalledAfter
+	}
+	if config.MaxDeliveryAge == 0 {
+		config.MaxDeliveryAge = defaultMaxDeliveryAge
+	}
+
+	live = newHealthReport()
+	live.add("queue", checkQueue(in.queue))
+	live.add("database", checkDatabase(in.queue))
+	live.add("workers", checkWorkers(in.queue, config.StalledAfter, in.now))
+
+	ready = newHealthReport()
+	for name, check := range live.Checks {
+		ready.add(name, check)
+	}
+	ready.add("pending", checkPending(in.queue, config.MaxPendingAge, in.now))
+	ready.add("delivery", checkDelivery(in.queue, config.MaxDeliveryAge, in.started, in.now))
+	ready.add("heartbeat", checkHeartbeat(in.heartbeat))
+	ready.add("circuit", checkCircuit(in.queue))
+
+	return live, ready
+}
+
+func newHealthReport() HealthReport {
+	return HealthReport{Status: HealthPass, Checks: map[string]HealthCheck{}}
+}
+
+// add includes a check in the report, which takes the worst status of its
+// checks.
+func (r *HealthReport) add(name string, check HealthCheck) {
+	r.Checks[name] = check
+	if check.Status == HealthFail || (check.Status == HealthWarn && r.Status == HealthPass) {
+		r.Status = check.Status
+	}
+}
+
+func checkQueue(q persistentqueue.Health) HealthCheck {
+	switch {
+	case !q.Started:
+		return HealthCheck{Status: HealthFail, Message: "Queue isn't running."}
+	case !q.Replay.Done:
+		return HealthCheck{
+			Status:  HealthWarn,
+			Message: fmt.Sprintf("Replaying pending events: %v of %v.", q.Replay.Replayed, q.Replay.Total),
+		}
+	}
+	return HealthCheck{Status: HealthPass, Message: "Queue is running."}
+}
+
+func checkDatabase(q persistentqueue.Health) HealthCheck {
+	switch {
+	case !q.Started:
+		return HealthCheck{Status: HealthFail, Message: "Database isn't open."}
+	case q.DBError != nil:
+		return HealthCheck{Status: HealthFail, Message: fmt.Sprintf("Database isn't writable: %v", q.DBError)}
+	}
+	return HealthCheck{Status: HealthPass, Message: "Database is writable."}
+}
+
+func checkWorkers(q persistentqueue.Health, stalledAfter time.Duration, now time.Time) HealthCheck {
+	if q.Inflight == 0 {
+		return HealthCheck{Status: HealthPass, Message: "No events in flight."}
+	}
+
+	check := HealthCheck{Status: HealthPass, Time: timePtr(q.LastProgress)}
+	idle := now.Sub(q.LastProgress).Round(time.Second)
+	check.Message = fmt.Sprintf("%v events in flight, last processed %v ago.", q.Inflight, idle)
+	if stalledAfter > 0 && idle > stalledAfter {
+		check.Status = HealthFail
+	}
+	return check
+}
+
+func checkPending(q persistentqueue.Health, maxAge time.Duration, now time.Time) HealthCheck {
+	if q.OldestPending.IsZero() {
+		return HealthCheck{Status: HealthPass, Message: "No pending events."}
+	}
+
+	check := HealthCheck{Status: HealthPass, Time: timePtr(q.OldestPending)}
+	age := now.Sub(q.OldestPending).Round(time.Second)
+	check.Message = fmt.Sprintf("Oldest pending event is %v old.", age)
+	if maxAge > 0 && age > maxAge {
+		check.Status = HealthFail
+	}
+	return check
+}
+
+// checkDelivery fails if events are pending but none have been sent
+// successfully recently, measured from the server's start if none have been
+// sent at all.
+func checkDelivery(q persistentqueue.Health, maxAge time.Duration, started, now time.Time) HealthCheck {
+	check := HealthCheck{Status: HealthPass, Message: "No events sent yet."}
+
+	since := started
+	if !q.LastSuccess.IsZero() {
+		since = q.LastSuccess
+		check.Time = timePtr(q.LastSuccess)
+		check.Message = fmt.Sprintf("Last sent an event successfully %v ago.", now.Sub(since).Round(time.Second))
+	}
+
+	if !q.OldestPending.IsZero() && maxAge > 0 && now.Sub(since) > maxAge {
+		check.Status = HealthFail
+		check.Message = fmt.Sprintf("Events are pending but none have been sent successfully in %v.", now.Sub(since).Round(time.Second))
+	}
+	return check
+}
+
+// checkHeartbeat warns if the last heartbeat failed, which alone doesn't
+// affect sending events.
+func checkHeartbeat(hb HeartbeatStatus) HealthCheck {
+	switch {
+	case hb.LastAttempt.IsZero():
+		return HealthCheck{Status: HealthPass, Message: "No heartbeat sent yet."}
+	case hb.Error != nil:
+		check := HealthCheck{Status: HealthWarn, Message: fmt.Sprintf("Last heartbeat failed: %v", hb.Error)}
+		if !hb.LastSuccess.IsZero() {
+			check.Time = timePtr(hb.LastSuccess)
+		}
+		return check
+	}
+	return HealthCheck{Status: HealthPass, Message: "Last heartbeat succeeded.", Time: timePtr(hb.LastSuccess)}
+}
+
+// checkCircuit warns while ordering scopes are open, holding events behind
+// failed events until they're retried or skipped.
+func checkCircuit(q persistentqueue.Health) HealthCheck {
+	if len(q.Blocked) == 0 {
+		return HealthCheck{Status: HealthPass, Message: "Closed."}
+	}
+	return HealthCheck{
+		Status:  HealthWarn,
+		Message: fmt.Sprintf("Open, %v ordering scopes are holding events behind failed events.", len(q.Blocked)),
+		Blocked: q.Blocked,
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -1,13 +1,50 @@
 package server
 
 import (
-	"fmt"
+	"encoding/json"
 	"net/http"
+	"time"
 )
 
+// HealthHandler responds with the result of all health checks, failing with
+// a 503 unless the server is ready to accept and send events.
 func (s *Server) HealthHandler(rw http.ResponseWriter, _ *http.Request) {
-	_, err := fmt.Fprint(rw, "OK")
-	if err != nil {
-		s.logger.Error("Error responding to healthcheck.")
+	_, ready := s.checkHealth()
+	s.healthResp(rw, ready)
+}
+
+// LivenessHandler responds with the result of liveness checks, failing with
+// a 503 if the server needs restarting.
+func (s *Server) LivenessHandler(rw http.ResponseWriter, _ *http.Request) {
+	live, _ := s.checkHealth()
+	s.healthResp(rw, live)
+}
+
+func (s *Server) checkHealth() (live, ready HealthReport) {
+	s.mu.RLock()
+	config := s.health
+	s.mu.RUnlock()
+
+	return checkHealth(healthInputs{
+		config:    config,
+		queue:     s.Queue.Health(),
+		heartbeat: s.Heartbeat.Status(),
+		started:   s.started,
+		now:       time.Now(),
+	})
+}
+
+func (s *Server) healthResp(rw http.ResponseWriter, report HealthReport) {
+	if report.Status == HealthFail {
+		body, err := json.Marshal(report)
+		if err != nil {
+			s.logger.Error("Error responding to healthcheck.")
+			rw.WriteHeader(500)
+			return
+		}
+		rw.WriteHeader(503)
+		_, _ = rw.Write(body)
+		return
 	}
+	okResp(rw, report)
 }
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckHealth(t *testing.T) {
+	now := time.Now()
+	in := healthInputs{
+		queue: persistentqueue.Health{
+			Started: true,
+			Replay:  persistentqueue.ReplayProgress{Done: true},
+		},
+		started: now.Add(-2 * time.Hour),
+		now:     now,
+	}
+
+	live, ready := checkHealth(in)
+	assert.Equal(t, HealthPass, live.Status)
+	assert.Equal(t, HealthPass, ready.Status)
+	assert.Len(t, live.Checks, 3)
+	assert.Len(t, ready.Checks, 7)
+
+	// Pending events with no recent deliveries aren't ready, but are alive.
+	in.queue.OldestPending = now.Add(-90 * time.Minute)
+	live, ready = checkHealth(in)
+	assert.Equal(t, HealthPass, live.Status)
+	assert.Equal(t, HealthFail, ready.Status)
+	assert.Equal(t, HealthFail, ready.Checks["delivery"].Status)
+	assert.Equal(t, HealthPass, ready.Checks["pending"].Status)
+
+	in.queue.LastSuccess = now.Add(-time.Minute)
+	_, ready = checkHealth(in)
+	assert.Equal(t, HealthPass, ready.Status)
+
+	in.config.MaxPendingAge = time.Hour
+	_, ready = checkHealth(in)
+	assert.Equal(t, HealthFail, ready.Checks["pending"].Status)
+	in.config.MaxPendingAge = 0
+
+	// Warnings don't fail either check.
+	in.queue.Blocked = []string{"abc"}
+	in.heartbeat = HeartbeatStatus{LastAttempt: now, Error: errors.New("unreachable")}
+	live, ready = checkHealth(in)
+	assert.Equal(t, HealthPass, live.Status)
+	assert.Equal(t, HealthWarn, ready.Status)
+	assert.Equal(t, []string{"abc"}, ready.Checks["circuit"].Blocked)
+
+	// Stalled workers fail liveness.
+	in.queue.Inflight = 1
+	in.queue.LastProgress = now.Add(-time.Hour)
+	live, ready = checkHealth(in)
+	assert.Equal(t, HealthFail, live.Checks["workers"].Status)
+	assert.Equal(t, HealthFail, ready.Status)
+
+	in.config.StalledAfter = -1
+	live, _ = checkHealth(in)
+	assert.Equal(t, HealthPass, live.Status)
+
+	in.queue.DBError = errors.New("read only")
+	live, _ = checkHealth(in)
+	assert.Equal(t, HealthFail, live.Checks["database"].Status)
+}
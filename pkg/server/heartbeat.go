@@ -5,9 +5,11 @@ import (
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/metrics"
 	"go.uber.org/zap"
 )
 
@@ -21,6 +23,14 @@ var ErrHeartbeatError = errors.New("an error was encountered while sending the h
 type Heartbeat interface {
 	Start()
 	Shutdown()
+	Status() HeartbeatStatus
+}
+
+// HeartbeatStatus reports on the most recent heartbeat, if any have been sent.
+type HeartbeatStatus struct {
+	LastAttempt time.Time
+	LastSuccess time.Time
+	Error       error
 }
 
 type heartbeat struct {
@@ -29,6 +39,9 @@ type heartbeat struct {
 	logger    *zap.SugaredLogger
 	client    *http.Client
 	frequency int
+
+	mu     sync.Mutex
+	status HeartbeatStatus
 }
 
 type heartbeatResponseBody struct {
@@ -80,11 +93,14 @@ func (hb *heartbeat) beat() {
 	hb.logger.Info("Sending heartbeat")
 
 	heartbeatResponse, err := hb.doHeartbeatRequest()
+	hb.record(err)
 	if err != nil {
+		metrics.Heartbeats.WithLabelValues("failure").Inc()
 		hb.logger.Warnf("An error occurred while sending heartbeat: ", err)
 		return
 	}
 
+	metrics.Heartbeats.WithLabelValues("success").Inc()
 	hb.logger.Info("Heartbeat successful")
 
 	hb.logger.Info("Updating heartbeat frequency to ", heartbeatResponse.HeartBeatIntervalSeconds)
@@ -92,6 +108,23 @@ func (hb *heartbeat) beat() {
 	hb.ticker = time.NewTicker(time.Duration(heartbeatResponse.HeartBeatIntervalSeconds) * time.Second)
 }
 
+func (hb *heartbeat) Status() HeartbeatStatus {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	return hb.status
+}
+
+func (hb *heartbeat) record(err error) {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+
+	hb.status.LastAttempt = time.Now()
+	hb.status.Error = err
+	if err == nil {
+		hb.status.LastSuccess = hb.status.LastAttempt
+	}
+}
+
 func (hb *heartbeat) doHeartbeatRequest() (*heartbeatResponseBody, error) {
 	url := common.PdApiUrl() + endpoint
 	req, err := http.NewRequest("GET", url, nil)
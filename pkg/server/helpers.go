@@ -7,6 +7,10 @@ import (
 )
 
 func okResp(rw http.ResponseWriter, resp interface{}) {
+	jsonResp(rw, 200, resp)
+}
+
+func jsonResp(rw http.ResponseWriter, code int, resp interface{}) {
 	body, err := json.Marshal(resp)
 	if err != nil {
 		rw.WriteHeader(500)
@@ -14,22 +18,34 @@ func okResp(rw http.ResponseWriter, resp interface{}) {
 		return
 	}
 
-	rw.WriteHeader(200)
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
 	_, _ = rw.Write(body)
 }
 
+// ErrorResponse is the body of every error response, with a code identifying
+// the kind of error, see `errorCodes`, and a message describing it. `Errors`
+// lists every problem found, starting with the message.
 type ErrorResponse struct {
-	Errors []string `json:"errors"`
+	Code    string   `json:"code"`
+	Message string   `json:"message"`
+	Errors  []string `json:"errors"`
 }
 
 func errorResp(rw http.ResponseWriter, code int, errs []string) {
-	body, err := json.Marshal(ErrorResponse{errs})
+	resp := ErrorResponse{Code: errorCode(code), Errors: errs}
+	if len(errs) > 0 {
+		resp.Message = errs[0]
+	}
+
+	body, err := json.Marshal(resp)
 	if err != nil {
 		rw.WriteHeader(500)
 		_, _ = fmt.Fprint(rw, err.Error())
 		return
 	}
 
+	rw.Header().Set("Content-Type", "application/json")
 	rw.WriteHeader(code)
 	_, _ = rw.Write(body)
 }
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+// mockQueue records enqueued events, panicking if other Queue methods are
+// called.
+type mockQueue struct {
+	Queue
+	events []*eventsapi.EventContainer
+
+	// Event returned by Wait, which otherwise blocks until its context is
+	// done.
+	sent *persistentqueue.Event
+
+	// Transitions sent to subscribers, closed once sent, and the arguments
+	// of the last subscription.
+	transitions []persistentqueue.Transition
+	subscribed  []interface{}
+}
+
+func (q *mockQueue) Enqueue(eventContainer *eventsapi.EventContainer, _ ...persistentqueue.EventOption) (string, error) {
+	q.events = append(q.events, eventContainer)
+	return common.GenerateKey(), nil
+}
+
+func (q *mockQueue) EnqueueBatch(eventContainers []*eventsapi.EventContainer, _ ...persistentqueue.EventOption) ([]string, error) {
+	keys := make([]string, len(eventContainers))
+	for i, eventContainer := range eventContainers {
+		keys[i], _ = q.Enqueue(eventContainer)
+	}
+	return keys, nil
+}
+
+func (q *mockQueue) SetExpiry(persistentqueue.ExpiryPolicy) {}
+
+func (q *mockQueue) Wait(ctx context.Context, key string) (*persistentqueue.Event, error) {
+	if q.sent != nil {
+		return q.sent, nil
+	}
+	<-ctx.Done()
+	return &persistentqueue.Event{Key: key, Status: persistentqueue.StatusPending}, ctx.Err()
+}
+
+func (q *mockQueue) Subscribe(routingKey string, after uint64) *persistentqueue.Subscription {
+	q.subscribed = []interface{}{routingKey, after}
+	c := make(chan persistentqueue.Transition, len(q.transitions))
+	for _, t := range q.transitions {
+		c <- t
+	}
+	close(c)
+	return &persistentqueue.Subscription{C: c, Since: 12}
+}
+
+func newTestServer(secret string) (*Server, *mockQueue) {
+	q := &mockQueue{}
+	return NewServer("127.0.0.1:0", secret, "", q), q
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"strconv"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/systemd"
+)
+
+const defaultSocketMode = 0600
+
+// SocketConfig configures the Unix domain socket the server listens on,
+// either in place of TCP (with a `unix://` address) or alongside it.
+//
+// Owner and group may be names or numeric IDs, and default to those of the
+// server process. Mode defaults to 0600.
+type SocketConfig struct {
+	Path  string `mapstructure:"path"`
+	Owner string `mapstructure:"owner"`
+	Group string `mapstructure:"group"`
+	Mode  uint32 `mapstructure:"mode"`
+}
+
+// WithSocket configures the Unix socket's permissions, additionally
+// listening on its path if set.
+func WithSocket(config SocketConfig) Option {
+	return func(s *Server) {
+		s.socket = config
+	}
+}
+
+// listen opens listeners for the server's address and additional socket, if
+// any, unless systemd has passed sockets to listen on instead.
+func (s *Server) listen() ([]net.Listener, error) {
+	activated, err := systemd.Listeners()
+	if err != nil {
+		return nil, err
+	}
+	if len(activated) > 0 {
+		for _, l := range activated {
+			s.logger.Infof("Listening on %v, passed by systemd", l.Addr())
+		}
+		return activated, nil
+	}
+
+	addresses := []string{s.HTTPServer.Addr}
+	if s.socket.Path != "" {
+		addresses = append(addresses, "unix://"+s.socket.Path)
+	}
+
+	var listeners []net.Listener
+	for _, address := range addresses {
+		l, err := s.listenOn(address)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		s.logger.Infof("Listening on %v", address)
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
+
+func (s *Server) listenOn(address string) (net.Listener, error) {
+	network, addr := common.SplitAddress(address)
+	if network != "unix" {
+		return net.Listen(network, addr)
+	}
+
+	if err := os.MkdirAll(path.Dir(addr), 0755); err != nil {
+		return nil, err
+	}
+
+	// A socket left behind by a server that didn't shut down cleanly would
+	// otherwise prevent listening. The pidfile ensures no other server is
+	// running.
+	if info, err := os.Lstat(addr); err == nil && info.Mode()&os.ModeSocket != 0 {
+		s.logger.Infof("Removing stale socket %v", addr)
+		if err := os.Remove(addr); err != nil {
+			return nil, err
+		}
+	}
+
+	// Created accessible only to the server's user, so that it isn't open to
+	// others before its configured permissions are applied.
+	var l net.Listener
+	var err error
+	withUmask(0777&^defaultSocketMode, func() {
+		l, err = net.Listen(network, addr)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.socket.apply(addr); err != nil {
+		l.Close()
+		return nil, fmt.Errorf("error setting permissions of socket %v: %v", addr, err)
+	}
+
+	return l, nil
+}
+
+// apply sets the configured mode and ownership of the socket at path.
+func (c SocketConfig) apply(path string) error {
+	mode := os.FileMode(defaultSocketMode)
+	if c.Mode != 0 {
+		mode = os.FileMode(c.Mode)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		return err
+	}
+
+	if c.Owner == "" && c.Group == "" {
+		return nil
+	}
+
+	uid, gid := -1, -1
+	if c.Owner != "" {
+		u, err := user.Lookup(c.Owner)
+		if _, ok := err.(user.UnknownUserError); ok {
+			u, err = user.LookupId(c.Owner)
+		}
+		if err != nil {
+			return err
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return err
+		}
+	}
+	if c.Group != "" {
+		g, err := user.LookupGroup(c.Group)
+		if _, ok := err.(user.UnknownGroupError); ok {
+			g, err = user.LookupGroupId(c.Group)
+		}
+		if err != nil {
+			return err
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return err
+		}
+	}
+
+	return os.Chown(path, uid, gid)
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/PagerDuty/go-pdagent/pkg/metrics"
+)
+
+var metricsHandler = metrics.Handler()
+
+// MetricsHandler serves Prometheus metrics, updating the database size first.
+//
+// Metrics cover all routing keys, so tokens restricted to specific routing
+// keys can't read them.
+func (s *Server) MetricsHandler(rw http.ResponseWriter, req *http.Request) {
+	if !s.authorizeRoutingKey(rw, req, "") {
+		return
+	}
+
+	if size, err := s.Queue.Size(); err != nil {
+		s.logger.Error("Error reading database size: ", err)
+	} else {
+		metrics.DBSize.Set(float64(size))
+	}
+
+	metricsHandler.ServeHTTP(rw, req)
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/metrics"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pdagent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	eq := eventqueue.NewEventQueue()
+	eq.Processor = func(job eventqueue.Job, _ chan bool) {
+		job.ResponseChan <- eventqueue.Response{}
+	}
+	q := persistentqueue.NewPersistentQueue(persistentqueue.WithFile(filepath.Join(dir, "pdagent.db")), persistentqueue.WithEventQueue(eq))
+	if err := q.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer q.Shutdown()
+
+	s := NewServer("127.0.0.1:0", "secret", "", q)
+	// Metrics are global, so use a fresh routing key each time the test runs.
+	rk := common.GenerateKey()
+
+	rw, _ := apiRequest(s, "POST", "/api/v1/send", strings.NewReader(`{"routing_key": "`+rk+`", "event_action": "trigger", "payload": {"summary": "Disk full", "source": "db01", "severity": "error"}}`))
+	assert.Equal(t, 200, rw.Code)
+	var resp SendResponse
+	if err := json.Unmarshal(rw.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := q.Wait(ctx, resp.Key); err != nil {
+		t.Fatal(err)
+	}
+
+	rw, _ = apiRequest(s, "GET", "/api/v1/metrics", nil)
+	assert.Equal(t, 200, rw.Code)
+	body := rw.Body.String()
+
+	for series, value := range map[string]string{
+		`pdagent_send_requests_total{result="accepted"}`:                                                     `\d+`,
+		`pdagent_events_processed_total{routing_key_hash="` + metrics.RoutingKey(rk) + `",status="success"}`: `1`,
+		`pdagent_queue_depth{routing_key_hash="` + metrics.RoutingKey(rk) + `"}`:                             `0`,
+		`pdagent_db_size_bytes`: `[1-9][\d.e+]*`,
+		`pdagent_server_request_duration_seconds_count{code="200",method="POST",path="/api/v1/send"}`: `\d+`,
+	} {
+		assert.Regexp(t, `(?m)^`+regexp.QuoteMeta(series)+` `+value+`$`, body)
+	}
+	assert.NotContains(t, body, rk)
+
+	// Routes with variables share a series.
+	_, _ = apiRequest(s, "POST", "/api/v1/integrations/webhook/missing", strings.NewReader(`{}`))
+	rw, _ = apiRequest(s, "GET", "/api/v1/metrics", nil)
+	assert.Contains(t, rw.Body.String(), `path="/api/v1/integrations/webhook/{name}"`)
+}
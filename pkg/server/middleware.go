@@ -2,8 +2,13 @@ package server
 
 import (
 	"fmt"
-	"go.uber.org/zap"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/metrics"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
 )
 
 func loggingMiddleware(logger *zap.SugaredLogger) func(http.Handler) http.Handler {
@@ -15,23 +20,84 @@ func loggingMiddleware(logger *zap.SugaredLogger) func(http.Handler) http.Handle
 	}
 }
 
-func authMiddleware(s *Server) func(http.Handler) http.Handler {
-	serverHeader := fmt.Sprintf("token %v", s.secret)
+// metricsMiddleware observes the duration of requests by their route's path
+// template, so that paths with variables share a series.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				path = template
+			}
+		}
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: 200}
+		next.ServeHTTP(sw, r)
+
+		metrics.ServerRequestDuration.
+			WithLabelValues(path, r.Method, fmt.Sprint(sw.status)).
+			Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusWriter records the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush supports streamed responses, see `StreamHandler`.
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
 
+// authMiddleware authenticates requests using the token in their
+// Authorization header, unless the server has no secret or tokens configured
+// or the request may be made without a token.
+func authMiddleware(s *Server) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if s.secret == "" {
+			if s.tokens.empty() || s.allowsAnonymous(r) {
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			clientHeader := r.Header.Get("Authorization")
-			if clientHeader != serverHeader {
-				s.logger.Infof("Authorization failure with client auth header: %v", clientHeader)
+			var token *Token
+			if clientHeader := r.Header.Get("Authorization"); strings.HasPrefix(clientHeader, "token ") {
+				token = s.tokens.find(strings.TrimPrefix(clientHeader, "token "))
+			}
+			if token == nil {
+				s.logger.Infof("Authorization failure for %v.", r.URL.Path)
 				errorResp(w, 401, []string{"Unauthorized, expected matching secret token in Authorization header."})
 				return
 			}
 
+			next.ServeHTTP(w, withToken(r, token))
+		})
+	}
+}
+
+// bodyLimitMiddleware rejects requests with bodies larger than the server
+// allows, failing reads of those without a declared length once they exceed
+// it.
+func bodyLimitMiddleware(s *Server) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := s.maxRequestSize()
+			if r.ContentLength > limit {
+				writeError(w, requestTooLarge(limit))
+				return
+			}
+
+			r.Body = &limitedBody{ReadCloser: r.Body, limit: limit, remaining: limit}
 			next.ServeHTTP(w, r)
 		})
 	}
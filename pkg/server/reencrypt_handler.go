@@ -0,0 +1,26 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func (s *Server) ReencryptHandler(rw http.ResponseWriter, req *http.Request) {
+	s.logger.Debugf("Re-encrypting events.")
+
+	if !s.authorizeRoutingKey(rw, req, "") {
+		return
+	}
+
+	count, err := s.Queue.Reencrypt()
+	if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	okResp(rw, ReencryptResponse{fmt.Sprintf("Re-encrypted %v events.", count)})
+}
+
+type ReencryptResponse struct {
+	Message string `json:"message"`
+}
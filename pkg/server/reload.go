@@ -0,0 +1,127 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/systemd"
+	"github.com/PagerDuty/go-pdagent/pkg/webhook"
+	"go.uber.org/zap/zapcore"
+)
+
+var ErrReloadUnsupported = errors.New("reloading configuration isn't supported by this server")
+
+// Settings are the server's settings that may be changed while it's
+// running.
+type Settings struct {
+	Secret       string
+	Tokens       []Token
+	Health       HealthConfig
+	EventsAPI    EventsAPIConfig
+	Alertmanager AlertmanagerConfig
+	Webhooks     []webhook.Config
+	API          APIConfig
+
+	// Applied to the agent as a whole, see `common.LogLevel` and
+	// `common.SetRegion`, and to the queue.
+	LogLevel zapcore.Level
+	Region   string
+	Expiry   persistentqueue.ExpiryPolicy
+}
+
+// Reconfigure replaces the server's settings while it's running. Settings are
+// validated first, so either all or none are applied.
+func (s *Server) Reconfigure(settings Settings) error {
+	tokens, err := withSecret(settings.Secret, settings.Tokens)
+	if err != nil {
+		return err
+	}
+	webhooks, err := compileWebhooks(settings.Webhooks)
+	if err != nil {
+		return err
+	}
+	if err := settings.API.validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.secret = settings.Secret
+	s.health = settings.Health
+	s.eventsAPI = settings.EventsAPI
+	s.alertmanager = settings.Alertmanager
+	s.webhooks = webhooks
+	s.api = settings.API
+	s.tokens.set(tokens)
+	s.Queue.SetExpiry(settings.Expiry)
+	common.SetRegion(settings.Region)
+	common.LogLevel.SetLevel(settings.LogLevel)
+	s.logger.Infof("Reconfigured, loaded %v API tokens.", len(tokens))
+	return nil
+}
+
+// ReloadResult lists the settings applied by a reload, and those that
+// changed but only take effect once the server restarts.
+type ReloadResult struct {
+	Applied         []string `json:"applied"`
+	RestartRequired []string `json:"restart_required"`
+}
+
+// ReloadFunc re-reads the server's configuration, applying any settings that
+// may be changed while it's running, e.g. using `Reconfigure`.
+type ReloadFunc func(*Server) (ReloadResult, error)
+
+// WithReload sets how the server reloads its configuration, on SIGHUP or
+// when requested via the API.
+func WithReload(reload ReloadFunc) Option {
+	return func(s *Server) {
+		s.reloadFunc = reload
+	}
+}
+
+// Reload re-reads the server's configuration, if it's been configured with
+// `WithReload`.
+func (s *Server) Reload() (ReloadResult, error) {
+	if s.reloadFunc == nil {
+		return ReloadResult{}, ErrReloadUnsupported
+	}
+
+	// Reloads may be requested concurrently, e.g. by signal and via the API.
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	// Only once started, as systemd treats READY=1 as the end of startup.
+	if s.systemdReady {
+		s.notify(systemd.Reloading)
+		defer s.notify(systemd.Ready)
+	}
+
+	result, err := s.reloadFunc(s)
+	if err != nil {
+		s.logger.Errorf("Error reloading configuration, keeping existing configuration: %v", err)
+		return result, err
+	}
+
+	s.logger.Infof("Reloaded configuration, applied: %v.", result.Applied)
+	if len(result.RestartRequired) > 0 {
+		s.logger.Warnf("Changes to %v require a restart to take effect.", result.RestartRequired)
+	}
+	return result, nil
+}
+
+// ReloadHandler reloads the server's configuration, responding with which
+// settings were applied and which require a restart.
+func (s *Server) ReloadHandler(rw http.ResponseWriter, _ *http.Request) {
+	result, err := s.Reload()
+	if err == ErrReloadUnsupported {
+		errorResp(rw, 501, []string{err.Error()})
+		return
+	} else if err != nil {
+		errorResp(rw, 400, []string{err.Error()})
+		return
+	}
+	okResp(rw, result)
+}
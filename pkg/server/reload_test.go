@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconfigure(t *testing.T) {
+	s, _ := newTestServer("secret")
+	requireToken := false
+
+	err := s.Reconfigure(Settings{
+		Secret:    "rotated",
+		Tokens:    []Token{{Name: "sender", Token: "send-token", Scopes: []string{ScopeSend}}},
+		Health:    HealthConfig{MaxPendingAge: 1},
+		EventsAPI: EventsAPIConfig{RequireToken: &requireToken},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, s.tokens.find("secret"))
+	assert.Equal(t, secretTokenName, s.tokens.find("rotated").Name)
+	assert.Equal(t, "sender", s.tokens.find("send-token").Name)
+	assert.False(t, s.eventsAPI.requireToken())
+
+	// Invalid settings leave the existing settings in place.
+	err = s.Reconfigure(Settings{Secret: "other", Tokens: []Token{{Name: "bad"}}})
+	assert.Error(t, err)
+	assert.Equal(t, "rotated", s.secret)
+	assert.NotNil(t, s.tokens.find("send-token"))
+	assert.False(t, s.eventsAPI.requireToken())
+}
+
+func TestReloadHandler(t *testing.T) {
+	s, _ := newTestServer("secret")
+
+	reload := func() (int, ReloadResult) {
+		req := httptest.NewRequest("POST", "/server/reload", nil)
+		req.Header.Set("Authorization", "token secret")
+		rw := httptest.NewRecorder()
+		s.HTTPServer.Handler.ServeHTTP(rw, req)
+
+		var result ReloadResult
+		_ = json.Unmarshal(rw.Body.Bytes(), &result)
+		return rw.Code, result
+	}
+
+	code, _ := reload()
+	assert.Equal(t, 501, code)
+
+	var reloadErr error
+	WithReload(func(*Server) (ReloadResult, error) {
+		return ReloadResult{Applied: []string{"secret"}, RestartRequired: []string{"address"}}, reloadErr
+	})(s)
+
+	code, result := reload()
+	assert.Equal(t, 200, code)
+	assert.Equal(t, []string{"secret"}, result.Applied)
+	assert.Equal(t, []string{"address"}, result.RestartRequired)
+
+	reloadErr = errors.New("invalid config")
+	code, _ = reload()
+	assert.Equal(t, 400, code)
+}
@@ -14,6 +14,10 @@ func (s *Server) RetryHandler(rw http.ResponseWriter, req *http.Request) {
 		s.logger.Debugf("Retrying for routing key %v", rk)
 	}
 
+	if !s.authorizeRoutingKey(rw, req, rk) {
+		return
+	}
+
 	count, err := s.Queue.Retry(rk)
 	if err != nil {
 		errorResp(rw, 500, []string{err.Error()})
@@ -1,19 +1,93 @@
 package server
 
 import (
+	"fmt"
+	"net/http"
+	"strings"
+
 	"github.com/gorilla/mux"
 )
 
+// Prefix of the versioned API's paths.
+const apiPathV1 = "/api/v1"
+
+// route is an API endpoint, served under `apiPathV1` restricted to its
+// methods, and without the prefix accepting any method for compatibility
+// with earlier clients.
+type route struct {
+	path    string
+	methods []string
+	scope   string
+	handler http.HandlerFunc
+}
+
+func (s *Server) routes() []route {
+	return []route{
+		{"/db/backup", []string{"POST"}, ScopeAdmin, s.BackupHandler},
+		{"/health", []string{"GET"}, "", s.HealthHandler},
+		{"/health/live", []string{"GET"}, "", s.LivenessHandler},
+		{"/health/ready", []string{"GET"}, "", s.HealthHandler},
+		{"/integrations/alertmanager", []string{"POST"}, ScopeSend, s.AlertmanagerHandler},
+		{"/integrations/webhook/{name}", []string{"POST"}, ScopeSend, s.WebhookHandler},
+		{"/metrics", []string{"GET"}, ScopeReadStatus, s.MetricsHandler},
+		{"/send", []string{"POST"}, ScopeSend, s.SendHandler},
+		{"/send/batch", []string{"POST"}, ScopeSend, s.SendBatchHandler},
+		{"/queue/reencrypt", []string{"POST"}, ScopeAdmin, s.ReencryptHandler},
+		{"/queue/retry", []string{"POST"}, ScopeAdmin, s.RetryHandler},
+		{"/queue/skip", []string{"POST"}, ScopeAdmin, s.SkipHandler},
+		{"/queue/status", []string{"GET"}, ScopeReadStatus, s.StatusHandler},
+		{"/queue/status/rebuild", []string{"POST"}, ScopeAdmin, s.RebuildStatusHandler},
+		{"/queue/stream", []string{"GET"}, ScopeReadStatus, s.StreamHandler},
+		{"/server/reload", []string{"POST"}, ScopeAdmin, s.ReloadHandler},
+		{"/server/stop", []string{"POST"}, ScopeAdmin, s.StopHandler},
+	}
+}
+
 func Router(s *Server) *mux.Router {
 	r := mux.NewRouter()
+	r.NotFoundHandler = http.HandlerFunc(notFoundHandler)
 
-	r.HandleFunc("/health", s.HealthHandler)
-	r.HandleFunc("/send", s.SendHandler)
-	r.HandleFunc("/queue/retry", s.RetryHandler)
-	r.HandleFunc("/queue/status", s.StatusHandler)
+	api := r.PathPrefix(apiPathV1).Subrouter()
+	api.HandleFunc(openAPIPath, OpenAPIHandler).Methods("GET")
+
+	for _, rt := range s.routes() {
+		handler := rt.handler
+		if rt.scope != "" {
+			handler = s.limitRequests(s.requireScope(rt.scope, handler))
+		}
+		api.HandleFunc(rt.path, handler).Methods(rt.methods...)
+		r.HandleFunc(rt.path, handler)
+	}
+
+	// Compatible with the Events API, see `EventsAPIConfig`.
+	r.HandleFunc(eventsAPIPathV1, s.limitRequests(s.requireScope(ScopeSend, s.EventsAPIV1Handler)))
+	r.HandleFunc(eventsAPIPathV2, s.limitRequests(s.requireScope(ScopeSend, s.EventsAPIV2Handler)))
+
+	r.MethodNotAllowedHandler = methodNotAllowedHandler(api)
 
 	r.Use(loggingMiddleware(s.logger))
+	r.Use(metricsMiddleware)
 	r.Use(authMiddleware(s))
+	r.Use(bodyLimitMiddleware(s))
 
 	return r
 }
+
+// methodNotAllowedHandler responds with the methods the requested path
+// allows.
+func methodNotAllowedHandler(api *mux.Router) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var allowed []string
+		_ = api.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			var match mux.RouteMatch
+			if route.Match(req, &match) || match.MatchErr == mux.ErrMethodMismatch {
+				methods, _ := route.GetMethods()
+				allowed = append(allowed, methods...)
+			}
+			return nil
+		})
+
+		rw.Header().Set("Allow", strings.Join(allowed, ", "))
+		errorResp(rw, 405, []string{fmt.Sprintf("Method %v isn't allowed for %v.", req.Method, req.URL.Path)})
+	})
+}
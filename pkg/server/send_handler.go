@@ -1,35 +1,145 @@
 package server
 
 import (
-	"io/ioutil"
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/metrics"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
 )
 
+// Default and maximum times to wait for an event to be sent, when asked to.
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 60 * time.Second
+)
+
+// SendHandler queues an event, responding with its key.
+//
+// With `wait=true`, it then waits up to `timeout` (30s by default, at most
+// 60s) for the event to be sent, responding with its status and PagerDuty's
+// response. If it's still pending once the timeout elapses, it responds with
+// a 202 and the event remains queued.
 func (s *Server) SendHandler(rw http.ResponseWriter, req *http.Request) {
-	body, err := ioutil.ReadAll(req.Body)
+	wait, timeout, err := parseWait(req)
 	if err != nil {
+		metrics.SendRequests.WithLabelValues("rejected").Inc()
 		errorResp(rw, 400, []string{err.Error()})
 		return
 	}
 
+	body, err := readBody(req)
+	if err != nil {
+		metrics.SendRequests.WithLabelValues("rejected").Inc()
+		writeError(rw, err)
+		return
+	}
+
 	s.logger.Debugf("/send payload: %v", string(body))
 
-	eventContainer := eventsapi.EventContainer{
-		EventVersion: eventsapi.StringToEventVersion[req.Header["Pd-Event-Version"][0]],
-		EventData:    body,
+	version, err := sendEventVersion(req, body)
+	if err != nil {
+		metrics.SendRequests.WithLabelValues("rejected").Inc()
+		writeError(rw, err)
+		return
+	}
+	eventContainer := eventsapi.EventContainer{EventVersion: version, EventData: body}
+
+	event, err := eventContainer.UnmarshalEvent()
+	if err == nil {
+		err = event.Validate()
+	}
+	if err != nil {
+		metrics.SendRequests.WithLabelValues("rejected").Inc()
+		errorResp(rw, 400, []string{fmt.Sprintf("Invalid event: %v", err)})
+		return
 	}
 
-	key, err := s.Queue.Enqueue(&eventContainer)
+	var options []persistentqueue.EventOption
+	if token := requestToken(req); token != nil {
+		if !s.authorizeRoutingKey(rw, req, event.GetRoutingKey()) {
+			metrics.SendRequests.WithLabelValues("rejected").Inc()
+			return
+		}
+		options = append(options, persistentqueue.WithToken(token.Name))
+	}
+
+	key, err := s.Queue.Enqueue(&eventContainer, options...)
 	if err != nil {
+		metrics.SendRequests.WithLabelValues("rejected").Inc()
 		errorResp(rw, 500, []string{err.Error()})
 		return
 	}
+	metrics.SendRequests.WithLabelValues("accepted").Inc()
+
+	if !wait {
+		okResp(rw, SendResponse{Key: key})
+		return
+	}
 
-	okResp(rw, SendResponse{Key: key})
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+
+	e, err := s.Queue.Wait(ctx, key)
+	if err == context.DeadlineExceeded || err == context.Canceled {
+		jsonResp(rw, 202, SendResponse{Key: key, Status: persistentqueue.StatusPending})
+		return
+	} else if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	okResp(rw, SendResponse{Key: key, Status: e.Status, Response: e.ResponseBody})
+}
+
+// sendEventVersion returns the version given by the request's
+// `Pd-Event-Version` header, or determined by the event's fields without
+// one.
+func sendEventVersion(req *http.Request, body []byte) (eventsapi.EventVersion, error) {
+	header := req.Header.Get("Pd-Event-Version")
+	if header == "" {
+		version, err := batchEventVersion(body)
+		if err != nil {
+			return "", &APIError{Status: 400, Message: fmt.Sprintf("Invalid event: %v", err)}
+		}
+		return version, nil
+	}
+
+	version, ok := eventsapi.StringToEventVersion[header]
+	if !ok {
+		return "", &APIError{Status: 400, Message: fmt.Sprintf("Unknown Pd-Event-Version %q, expected v1 or v2.", header)}
+	}
+	return version, nil
+}
+
+// parseWait parses the `wait` and `timeout` query parameters.
+func parseWait(req *http.Request) (bool, time.Duration, error) {
+	query := req.URL.Query()
+	if query.Get("wait") != "true" {
+		return false, 0, nil
+	}
+
+	timeout := defaultWaitTimeout
+	if t := query.Get("timeout"); t != "" {
+		var err error
+		if timeout, err = time.ParseDuration(t); err != nil || timeout <= 0 {
+			return false, 0, fmt.Errorf("Invalid timeout %q, expected a positive duration, e.g. 10s.", t)
+		}
+	}
+	if timeout > maxWaitTimeout {
+		timeout = maxWaitTimeout
+	}
+	return true, timeout, nil
 }
 
+// SendResponse holds a queued event's key and, when waiting for it to be
+// sent, its status and PagerDuty's response.
 type SendResponse struct {
-	Key string `json:"key"`
+	Key      string          `json:"key"`
+	Status   string          `json:"status,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
 }
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/stretchr/testify/assert"
+)
+
+func postSend(s *Server, query, body string) (int, SendResponse) {
+	req := httptest.NewRequest("POST", "/send?"+query, strings.NewReader(body))
+	req.Header.Set("Authorization", "token secret")
+	req.Header.Set("Pd-Event-Version", "v2")
+	rw := httptest.NewRecorder()
+	s.HTTPServer.Handler.ServeHTTP(rw, req)
+
+	var resp SendResponse
+	_ = json.Unmarshal(rw.Body.Bytes(), &resp)
+	return rw.Code, resp
+}
+
+func TestSendHandlerWait(t *testing.T) {
+	s, q := newTestServer("secret")
+	event := `{"routing_key": "` + testRoutingKey + `", "event_action": "trigger"}`
+
+	code, resp := postSend(s, "", event)
+	assert.Equal(t, 200, code)
+	assert.NotEmpty(t, resp.Key)
+	assert.Empty(t, resp.Status)
+
+	code, resp = postSend(s, "wait=true&timeout=10ms", event)
+	assert.Equal(t, 202, code)
+	assert.NotEmpty(t, resp.Key)
+	assert.Equal(t, persistentqueue.StatusPending, resp.Status)
+
+	q.sent = &persistentqueue.Event{
+		Status:       persistentqueue.StatusSuccess,
+		ResponseBody: []byte(`{"status":"success","dedup_key":"srv01/HTTP"}`),
+	}
+	code, resp = postSend(s, "wait=true", event)
+	assert.Equal(t, 200, code)
+	assert.Equal(t, persistentqueue.StatusSuccess, resp.Status)
+	assert.JSONEq(t, `{"status":"success","dedup_key":"srv01/HTTP"}`, string(resp.Response))
+
+	code, _ = postSend(s, "wait=true&timeout=soon", event)
+	assert.Equal(t, 400, code)
+	assert.Len(t, q.events, 3)
+}
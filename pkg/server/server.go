@@ -2,25 +2,40 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/PagerDuty/go-pdagent/pkg/common"
 	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
 	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/webhook"
 	"go.uber.org/zap"
 )
 
 type Queue interface {
-	Enqueue(*eventsapi.EventContainer) (string, error)
+	Backup(string) (string, error)
+	Drain(context.Context) error
+	Enqueue(*eventsapi.EventContainer, ...persistentqueue.EventOption) (string, error)
+	EnqueueBatch([]*eventsapi.EventContainer, ...persistentqueue.EventOption) ([]string, error)
+	Health() persistentqueue.Health
+	RebuildStatus() (int, error)
+	Reencrypt() (int, error)
 	Retry(string) (int, error)
+	SetExpiry(persistentqueue.ExpiryPolicy)
 	Shutdown() error
+	Size() (int64, error)
+	Skip(string) (int, error)
 	Start() error
 	Status(string) ([]persistentqueue.StatusItem, error)
+	Subscribe(string, uint64) *persistentqueue.Subscription
+	Wait(context.Context, string) (*persistentqueue.Event, error)
 }
 
 type Server struct {
@@ -28,14 +43,64 @@ type Server struct {
 	Queue      Queue
 	Heartbeat  Heartbeat
 
-	pidfile string
-	secret  string
-	logger  *zap.SugaredLogger
+	pidfile   string
+	socket    SocketConfig
+	receivers []Receiver
+	started   time.Time
+	tokens    tokenStore
+	logger    *zap.SugaredLogger
+
+	// Guards settings that may be changed while running, see `Settings`.
+	mu           sync.RWMutex
+	alertmanager AlertmanagerConfig
+	api          APIConfig
+	eventsAPI    EventsAPIConfig
+	health       HealthConfig
+	secret       string
+	webhooks     map[string]*webhook.Webhook
+
+	limiter rateLimiter
+
+	reloadFunc ReloadFunc
+	reloadMu   sync.Mutex
+
+	// Whether systemd has been told the server is ready, and so should be
+	// told about reloads. Guarded by reloadMu.
+	systemdReady bool
+
+	// Receives how long to drain for when the server is asked to stop.
+	stopRequests chan time.Duration
+
+	// Closed when the server shuts down, ending open streams.
+	shutdown chan struct{}
 }
 
 type Option func(*Server)
 
-func NewServer(address, secret, pidfile string, queue Queue) *Server {
+// Receiver accepts events other than via the server's API, e.g. from syslog,
+// queuing them while the server is running.
+type Receiver interface {
+	Start() error
+	Shutdown() error
+}
+
+// WithReceivers runs the receivers alongside the server, starting them once
+// its queue has started and stopping them along with its API.
+func WithReceivers(receivers ...Receiver) Option {
+	return func(s *Server) {
+		s.receivers = append(s.receivers, receivers...)
+	}
+}
+
+// WithTLS serves HTTPS using the given configuration, which must include the
+// server's certificate.
+func WithTLS(config *tls.Config) Option {
+	return func(s *Server) {
+		s.HTTPServer.TLSConfig = config
+	}
+}
+
+func NewServer(address, secret, pidfile string, queue Queue, options ...Option) *Server {
 	logger := common.Logger.Named("Server")
 	heartbeat := NewHeartbeat()
 
@@ -43,21 +108,31 @@ func NewServer(address, secret, pidfile string, queue Queue) *Server {
 		HTTPServer: &http.Server{
 			Addr:           address,
 			ReadTimeout:    10 * time.Second,
-			WriteTimeout:   10 * time.Second,
+			WriteTimeout:   maxWaitTimeout + 10*time.Second,
 			MaxHeaderBytes: 1 << 20,
 		},
-		Queue:     queue,
-		Heartbeat: heartbeat,
-		pidfile:   pidfile,
-		secret:    secret,
-		logger:    logger,
+		Queue:        queue,
+		Heartbeat:    heartbeat,
+		pidfile:      pidfile,
+		secret:       secret,
+		logger:       logger,
+		stopRequests: make(chan time.Duration, 1),
+		shutdown:     make(chan struct{}),
 	}
 
 	server.HTTPServer.Handler = Router(&server)
+	server.HTTPServer.RegisterOnShutdown(func() { close(server.shutdown) })
+	_ = server.SetTokens(nil)
+
+	for _, option := range options {
+		option(&server)
+	}
 
 	return &server
 }
 
+// Start runs the server until it's stopped by SIGINT, SIGTERM or `Stop`,
+// returning once it has shut down.
 func (s *Server) Start() error {
 	s.logger.Infof("Server starting at %v", s.HTTPServer.Addr)
 
@@ -65,26 +140,59 @@ func (s *Server) Start() error {
 		return err
 	}
 
+	listeners, err := s.listen()
+	if err != nil {
+		s.logger.Error("Failed to listen: ", err)
+		return err
+	}
+
 	if err := s.Queue.Start(); err != nil {
 		s.logger.Error("Failed to start server's queue.")
+		for _, l := range listeners {
+			l.Close()
+		}
+		return err
+	}
+
+	if err := s.startReceivers(); err != nil {
+		s.logger.Error("Failed to start receivers: ", err)
+		for _, l := range listeners {
+			l.Close()
+		}
+		_ = s.Queue.Shutdown()
 		return err
 	}
 
+	s.started = time.Now()
 	s.Heartbeat.Start()
 
-	go func() {
-		s.logger.Info(s.HTTPServer.ListenAndServe())
-	}()
+	// Serving may itself set a TLS configuration for HTTP/2, so check first.
+	secure := s.HTTPServer.TLSConfig != nil
+	for _, l := range listeners {
+		go s.serve(l, secure)
+	}
+
+	stopSystemd := make(chan struct{})
+	go s.superviseSystemd(stopSystemd)
 
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
-	<-stop
+	drain := s.waitForStop()
+	s.stopSystemd(stopSystemd)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := s.HTTPServer.Shutdown(ctx); err != nil {
 		s.logger.Error(err)
 	}
+	s.shutdownReceivers(s.receivers)
+
+	if drain > 0 {
+		s.logger.Infof("Draining queued events for up to %v.", drain)
+		ctx, cancel := context.WithTimeout(context.Background(), drain)
+		if err := s.Queue.Drain(ctx); err != nil {
+			s.logger.Warnf("Events still in flight after %v, leaving them pending.", drain)
+		}
+		cancel()
+	}
 
 	if err := s.Queue.Shutdown(); err != nil {
 		s.logger.Error("Error shutting down server's queue.")
@@ -93,15 +201,90 @@ func (s *Server) Start() error {
 
 	s.Heartbeat.Shutdown()
 
-	if err := common.RemovePidfile(s.pidfile); err != nil {
-		return err
+	if s.pidfile == "" {
+		return nil
 	}
+	return common.RemovePidfile(s.pidfile)
+}
 
-	os.Exit(0)
+// startReceivers starts each receiver, stopping those already started if any
+// fail.
+func (s *Server) startReceivers() error {
+	for i, receiver := range s.receivers {
+		if err := receiver.Start(); err != nil {
+			s.shutdownReceivers(s.receivers[:i])
+			return err
+		}
+	}
 	return nil
 }
 
+func (s *Server) shutdownReceivers(receivers []Receiver) {
+	for _, receiver := range receivers {
+		if err := receiver.Shutdown(); err != nil {
+			s.logger.Error("Error shutting down receiver: ", err)
+		}
+	}
+}
+
+// Stop stops a running server, causing `Start` to return. New events are
+// refused immediately, then events already queued are sent for up to the
+// drain duration before the queue stops, leaving any remaining events
+// pending.
+//
+// Returns false if the server is already stopping.
+func (s *Server) Stop(drain time.Duration) bool {
+	select {
+	case s.stopRequests <- drain:
+		return true
+	default:
+		return false
+	}
+}
+
+// waitForStop blocks until the server is stopped, by signal or `Stop`,
+// reloading its configuration on SIGHUP meanwhile. Returns how long to drain
+// queued events for.
+func (s *Server) waitForStop() time.Duration {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	// Reload is a no-op without a reload function, so only listen when set.
+	reload := make(chan os.Signal, 1)
+	if s.reloadFunc != nil {
+		signal.Notify(reload, syscall.SIGHUP)
+		defer signal.Stop(reload)
+	}
+
+	for {
+		select {
+		case <-reload:
+			s.logger.Info("Received SIGHUP, reloading configuration.")
+			_, _ = s.Reload()
+		case sig := <-signals:
+			s.logger.Infof("Received %v, stopping.", sig)
+			return 0
+		case drain := <-s.stopRequests:
+			s.logger.Info("Stop requested, stopping.")
+			return drain
+		}
+	}
+}
+
+func (s *Server) serve(l net.Listener, secure bool) {
+	if secure {
+		s.logger.Info(s.HTTPServer.ServeTLS(l, "", ""))
+		return
+	}
+	s.logger.Info(s.HTTPServer.Serve(l))
+}
+
 func (s *Server) initPidfile() error {
+	if s.pidfile == "" {
+		return nil
+	}
+
 	if err := os.MkdirAll(path.Dir(s.pidfile), 0744); err != nil {
 		return err
 	}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lifecycleQueue records how it was started and stopped.
+type lifecycleQueue struct {
+	mockQueue
+	started  bool
+	drained  bool
+	deadline time.Time
+	shutdown bool
+}
+
+func (q *lifecycleQueue) Start() error {
+	q.started = true
+	return nil
+}
+
+func (q *lifecycleQueue) Drain(ctx context.Context) error {
+	q.drained = true
+	q.deadline, _ = ctx.Deadline()
+	return nil
+}
+
+func (q *lifecycleQueue) Shutdown() error {
+	q.shutdown = true
+	return nil
+}
+
+type nopHeartbeat struct{}
+
+func (nopHeartbeat) Start()                  {}
+func (nopHeartbeat) Shutdown()               {}
+func (nopHeartbeat) Status() HeartbeatStatus { return HeartbeatStatus{} }
+
+func TestServerStop(t *testing.T) {
+	q := &lifecycleQueue{}
+	s := NewServer("127.0.0.1:0", "secret", "", q)
+	s.Heartbeat = nopHeartbeat{}
+
+	done := make(chan error)
+	go func() {
+		done <- s.Start()
+	}()
+
+	assert.True(t, s.Stop(time.Minute))
+
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected server to stop.")
+	}
+
+	assert.True(t, q.started)
+	assert.True(t, q.drained)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), q.deadline, 10*time.Second)
+	assert.True(t, q.shutdown)
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+)
+
+func (s *Server) SkipHandler(rw http.ResponseWriter, req *http.Request) {
+	rk := req.URL.Query().Get("rk")
+
+	if rk == "" {
+		s.logger.Debugf("Skipping failed events for all routing keys.")
+	} else {
+		s.logger.Debugf("Skipping failed events for routing key %v", rk)
+	}
+
+	if !s.authorizeRoutingKey(rw, req, rk) {
+		return
+	}
+
+	count, err := s.Queue.Skip(rk)
+	if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	okResp(rw, SkipResponse{fmt.Sprintf("Skipped %v events.", count)})
+}
+
+type SkipResponse struct {
+	Message string `json:"message"`
+}
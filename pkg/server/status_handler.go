@@ -1,6 +1,7 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
@@ -15,15 +16,51 @@ func (s *Server) StatusHandler(rw http.ResponseWriter, req *http.Request) {
 		s.logger.Debugf("Status for routing key %v", rk)
 	}
 
+	token := requestToken(req)
+	if rk != "" && !s.authorizeRoutingKey(rw, req, rk) {
+		return
+	}
+
 	statusItems, err := s.Queue.Status(rk)
 	if err != nil {
 		errorResp(rw, 500, []string{err.Error()})
 		return
 	}
 
+	// Tokens restricted to routing keys only see their own.
+	if token != nil && rk == "" {
+		var allowed []persistentqueue.StatusItem
+		for _, item := range statusItems {
+			if token.AllowsRoutingKey(item.RoutingKey) {
+				allowed = append(allowed, item)
+			}
+		}
+		statusItems = allowed
+	}
+
 	okResp(rw, StatusResponse{StatusItems: statusItems})
 }
 
 type StatusResponse struct {
 	StatusItems []persistentqueue.StatusItem `json:"status_items,omitempty"`
 }
+
+func (s *Server) RebuildStatusHandler(rw http.ResponseWriter, req *http.Request) {
+	s.logger.Debugf("Rebuilding status counters.")
+
+	if !s.authorizeRoutingKey(rw, req, "") {
+		return
+	}
+
+	count, err := s.Queue.RebuildStatus()
+	if err != nil {
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+
+	okResp(rw, RebuildStatusResponse{fmt.Sprintf("Rebuilt status from %v events.", count)})
+}
+
+type RebuildStatusResponse struct {
+	Message string `json:"message"`
+}
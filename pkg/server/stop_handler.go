@@ -0,0 +1,35 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// StopHandler stops the server, first draining queued events for up to the
+// `drain` duration if given.
+func (s *Server) StopHandler(rw http.ResponseWriter, req *http.Request) {
+	var drain time.Duration
+	if d := req.URL.Query().Get("drain"); d != "" {
+		var err error
+		if drain, err = time.ParseDuration(d); err != nil || drain < 0 {
+			errorResp(rw, 400, []string{fmt.Sprintf("Invalid drain %q, expected a duration, e.g. 60s.", d)})
+			return
+		}
+	}
+
+	if !s.Stop(drain) {
+		errorResp(rw, 409, []string{"Server is already stopping."})
+		return
+	}
+
+	if drain > 0 {
+		okResp(rw, StopResponse{fmt.Sprintf("Stopping, draining queued events for up to %v.", drain)})
+		return
+	}
+	okResp(rw, StopResponse{"Stopping."})
+}
+
+type StopResponse struct {
+	Message string `json:"message"`
+}
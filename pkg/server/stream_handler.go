@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+)
+
+// How long a stream lasts before the server ends it, within the server's
+// write timeout, how often idle streams are sent a comment to keep them
+// open, and how soon clients should reconnect.
+const (
+	maxStreamDuration = maxWaitTimeout
+	streamKeepAlive   = 15 * time.Second
+	streamRetry       = time.Second
+)
+
+// StreamHandler streams transitions of queued events as server-sent events,
+// for a routing key if given.
+//
+// Streams end after a minute, or if the client falls too far behind. Clients
+// resume by reconnecting with the last transition's ID in the
+// `Last-Event-ID` header, as browsers' `EventSource` does, receiving any
+// recent transitions they missed.
+func (s *Server) StreamHandler(rw http.ResponseWriter, req *http.Request) {
+	rk := req.URL.Query().Get("rk")
+	if rk == "" {
+		s.logger.Debugf("Streaming transitions for all routing keys.")
+	} else {
+		s.logger.Debugf("Streaming transitions for routing key %v", rk)
+	}
+
+	token := requestToken(req)
+	if rk != "" && !s.authorizeRoutingKey(rw, req, rk) {
+		return
+	}
+
+	after := uint64(persistentqueue.SubscribeNew)
+	if id := req.Header.Get("Last-Event-ID"); id != "" {
+		var err error
+		if after, err = strconv.ParseUint(id, 10, 64); err != nil {
+			errorResp(rw, 400, []string{fmt.Sprintf("Invalid Last-Event-ID %q, expected a transition ID.", id)})
+			return
+		}
+	}
+
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		errorResp(rw, 500, []string{"Streaming isn't supported by this connection."})
+		return
+	}
+
+	sub := s.Queue.Subscribe(rk, after)
+	defer sub.Cancel()
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(200)
+	_, _ = fmt.Fprintf(rw, "retry: %v\n", int64(streamRetry/time.Millisecond))
+	if after > sub.Since {
+		// Clients without a current ID resume from here, including those
+		// whose ID is from before the server restarted.
+		_, _ = fmt.Fprintf(rw, "id: %v\n", sub.Since)
+	}
+	_, _ = fmt.Fprint(rw, "\n")
+	flusher.Flush()
+
+	end := time.NewTimer(maxStreamDuration)
+	defer end.Stop()
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case t, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			// Tokens restricted to routing keys only see their own.
+			if token != nil && !token.AllowsRoutingKey(t.RoutingKey) {
+				continue
+			}
+
+			data, err := json.Marshal(t)
+			if err != nil {
+				s.logger.Error(err)
+				continue
+			}
+			_, _ = fmt.Fprintf(rw, "id: %v\nevent: %v\ndata: %s\n\n", t.ID, t.Type, data)
+			flusher.Flush()
+		case <-keepAlive.C:
+			_, _ = fmt.Fprint(rw, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-end.C:
+			return
+		case <-s.shutdown:
+			return
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
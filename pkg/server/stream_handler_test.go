@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamHandler(t *testing.T) {
+	s, q := newTestServer("secret")
+	err := s.SetTokens([]Token{{Name: "reader", Token: "read-token", Scopes: []string{ScopeReadStatus}, RoutingKeys: []string{testRoutingKey}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.transitions = []persistentqueue.Transition{
+		{ID: 8, Type: persistentqueue.TransitionAccepted, Key: "one", RoutingKey: testRoutingKey, Status: persistentqueue.StatusPending},
+		{ID: 9, Type: persistentqueue.TransitionAccepted, Key: "two", RoutingKey: "a0000000000000000000000000000000", Status: persistentqueue.StatusPending},
+		{ID: 10, Type: persistentqueue.TransitionSucceeded, Key: "one", RoutingKey: testRoutingKey, Status: persistentqueue.StatusSuccess, Response: "success: Event processed"},
+	}
+
+	stream := func(token, lastEventID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/queue/stream", nil)
+		req.Header.Set("Authorization", "token "+token)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		rw := httptest.NewRecorder()
+		s.HTTPServer.Handler.ServeHTTP(rw, req)
+		return rw
+	}
+
+	rw := stream("read-token", "7")
+	assert.Equal(t, 200, rw.Code)
+	assert.Equal(t, "text/event-stream", rw.Header().Get("Content-Type"))
+	assert.Equal(t, []interface{}{"", uint64(7)}, q.subscribed)
+
+	// Tokens restricted to routing keys only see their own events.
+	body := rw.Body.String()
+	assert.True(t, strings.HasPrefix(body, "retry: 1000\n\n"))
+	assert.Contains(t, body, "id: 8\nevent: accepted\ndata: {\"id\":8,\"type\":\"accepted\",\"key\":\"one\"")
+	assert.Contains(t, body, "id: 10\nevent: succeeded\n")
+	assert.Contains(t, body, `"response":"success: Event processed"`)
+	assert.NotContains(t, body, "id: 9\n")
+
+	rw = stream("secret", "")
+	assert.Equal(t, []interface{}{"", uint64(persistentqueue.SubscribeNew)}, q.subscribed)
+	assert.True(t, strings.HasPrefix(rw.Body.String(), "retry: 1000\nid: 12\n\n"))
+	assert.Contains(t, rw.Body.String(), "id: 9\n")
+
+	rw = stream("secret", "latest")
+	assert.Equal(t, 400, rw.Code)
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/systemd"
+)
+
+// How often the server's status is reported to systemd, when its watchdog
+// isn't enabled.
+const systemdStatusInterval = 30 * time.Second
+
+// superviseSystemd notifies systemd the server is ready, then keeps reporting
+// its status until stop is closed. If systemd's watchdog is enabled, it's
+// pinged as long as the server passes its liveness checks.
+func (s *Server) superviseSystemd(stop <-chan struct{}) {
+	if !systemd.Notifying() {
+		return
+	}
+
+	watchdog, err := systemd.WatchdogInterval()
+	if err != nil {
+		s.logger.Errorf("Ignoring systemd watchdog: %v", err)
+	}
+
+	interval := systemdStatusInterval
+	if watchdog > 0 {
+		s.logger.Infof("Pinging systemd watchdog every %v.", watchdog/2)
+		interval = watchdog / 2
+	}
+
+	s.reloadMu.Lock()
+	select {
+	case <-stop:
+		s.reloadMu.Unlock()
+		return
+	default:
+	}
+	s.notifySystemd(watchdog > 0, systemd.Ready)
+	s.systemdReady = true
+	s.reloadMu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.notifySystemd(watchdog > 0)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// stopSystemd stops reporting the server's status to systemd and notifies it
+// the server is stopping.
+func (s *Server) stopSystemd(stop chan<- struct{}) {
+	close(stop)
+
+	s.reloadMu.Lock()
+	s.systemdReady = false
+	s.reloadMu.Unlock()
+
+	s.notify(systemd.Stopping)
+}
+
+// notifySystemd sends notifications to systemd along with the server's
+// status, including a watchdog ping if enabled and the server is live.
+func (s *Server) notifySystemd(watchdog bool, states ...string) {
+	live, _ := s.checkHealth()
+	if watchdog && live.Status != HealthFail {
+		states = append(states, systemd.Watchdog)
+	} else if watchdog {
+		s.logger.Warn("Not pinging systemd watchdog, liveness checks are failing.")
+	}
+
+	states = append(states, s.systemdStatus())
+	s.notify(states...)
+}
+
+// notify sends notifications to systemd, if it expects them.
+func (s *Server) notify(states ...string) {
+	if err := systemd.Notify(states...); err != nil {
+		s.logger.Errorf("Error notifying systemd: %v", err)
+	}
+}
+
+// systemdStatus describes the queue's state, for `systemctl status`.
+func (s *Server) systemdStatus() string {
+	h := s.Queue.Health()
+	if !h.Replay.Done {
+		return systemd.Status("Replaying pending events: %v of %v.", h.Replay.Replayed, h.Replay.Total)
+	}
+
+	items, err := s.Queue.Status("")
+	if err != nil {
+		return systemd.Status("Error reading queue status: %v", err)
+	}
+
+	pending := 0
+	for _, item := range items {
+		pending += item.Pending
+	}
+	return systemd.Status("%v events pending, %v in flight.", pending, h.Inflight)
+}
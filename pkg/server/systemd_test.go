@@ -0,0 +1,76 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/stretchr/testify/assert"
+)
+
+// idleQueue reports an empty, started queue.
+type idleQueue struct {
+	mockQueue
+}
+
+func (q *idleQueue) Health() persistentqueue.Health {
+	return persistentqueue.Health{Started: true, Replay: persistentqueue.ReplayProgress{Done: true}}
+}
+
+func (q *idleQueue) Status(string) ([]persistentqueue.StatusItem, error) {
+	return nil, nil
+}
+
+func TestReloadNotifiesSystemdOnceReady(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pdagent-systemd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", socket)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	read := func() string {
+		buf := make([]byte, 1024)
+		_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, _ := conn.Read(buf)
+		return string(buf[:n])
+	}
+
+	s := NewServer("127.0.0.1:0", "secret", "", &idleQueue{})
+	s.Heartbeat = nopHeartbeat{}
+	WithReload(func(*Server) (ReloadResult, error) {
+		return ReloadResult{}, nil
+	})(s)
+
+	// Reloads while starting mustn't tell systemd the server is ready.
+	_, _ = s.Reload()
+	assert.Empty(t, read())
+
+	stop := make(chan struct{})
+	go s.superviseSystemd(stop)
+	assert.True(t, strings.HasPrefix(read(), "READY=1\n"))
+
+	_, _ = s.Reload()
+	assert.Equal(t, "RELOADING=1", read())
+	assert.Equal(t, "READY=1", read())
+
+	s.stopSystemd(stop)
+	assert.Equal(t, "STOPPING=1", read())
+
+	_, _ = s.Reload()
+	assert.Empty(t, read())
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// ScopeSend allows sending events.
+	ScopeSend = "send"
+
+	// ScopeReadStatus allows reading queue status.
+	ScopeReadStatus = "read-status"
+
+	// ScopeAdmin allows everything, including managing the queue and
+	// database.
+	ScopeAdmin = "admin"
+)
+
+// Name of the token created from the server's `secret`, which has the admin
+// scope.
+const secretTokenName = "secret"
+
+// Token is a named API token, granting its scopes for any routing key or
+// only those listed.
+type Token struct {
+	Name        string   `mapstructure:"name"`
+	Token       string   `mapstructure:"token"`
+	Scopes      []string `mapstructure:"scopes"`
+	RoutingKeys []string `mapstructure:"routing_keys"`
+}
+
+// Validate checks a token is usable.
+func (t *Token) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("token must have a name")
+	}
+	if t.Token == "" {
+		return fmt.Errorf("token %v must have a value", t.Name)
+	}
+	for _, scope := range t.Scopes {
+		if scope != ScopeSend && scope != ScopeReadStatus && scope != ScopeAdmin {
+			return fmt.Errorf("token %v has unknown scope %q", t.Name, scope)
+		}
+	}
+	return nil
+}
+
+// HasScope returns true if the token grants the scope, which admin tokens
+// always do.
+func (t *Token) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsRoutingKey returns true if the token may be used with the routing
+// key. Restricted tokens never allow an empty routing key, which stands for
+// all routing keys.
+func (t *Token) AllowsRoutingKey(routingKey string) bool {
+	if len(t.RoutingKeys) == 0 {
+		return true
+	}
+	for _, rk := range t.RoutingKeys {
+		if routingKey != "" && strings.EqualFold(rk, routingKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokenStore holds the server's tokens, which may be replaced while the
+// server is running.
+type tokenStore struct {
+	mu     sync.RWMutex
+	tokens []hashedToken
+}
+
+type hashedToken struct {
+	Token
+	hash [sha256.Size]byte
+}
+
+func (s *tokenStore) set(tokens []Token) {
+	hashed := make([]hashedToken, len(tokens))
+	for i, t := range tokens {
+		hashed[i] = hashedToken{t, sha256.Sum256([]byte(t.Token))}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = hashed
+}
+
+func (s *tokenStore) empty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.tokens) == 0
+}
+
+// find returns the token matching value, if any.
+//
+// Values are compared in constant time, by hash so as not to reveal their
+// lengths, and every token is compared regardless of matches.
+func (s *tokenStore) find(value string) *Token {
+	hash := sha256.Sum256([]byte(value))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found *Token
+	for i := range s.tokens {
+		if subtle.ConstantTimeCompare(hash[:], s.tokens[i].hash[:]) == 1 {
+			t := s.tokens[i].Token
+			found = &t
+		}
+	}
+	return found
+}
+
+// SetTokens replaces the tokens accepted by the server, alongside its secret.
+//
+// Safe to call while the server is running, e.g. when configuration changes.
+func (s *Server) SetTokens(tokens []Token) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens, err := withSecret(s.secret, tokens)
+	if err != nil {
+		return err
+	}
+
+	s.tokens.set(tokens)
+	s.logger.Infof("Loaded %v API tokens.", len(tokens))
+	return nil
+}
+
+// withSecret validates tokens, returning them along with a token for the
+// secret, if any.
+func withSecret(secret string, tokens []Token) ([]Token, error) {
+	for _, t := range tokens {
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if secret != "" {
+		tokens = append([]Token{{
+			Name:   secretTokenName,
+			Token:  secret,
+			Scopes: []string{ScopeAdmin},
+		}}, tokens...)
+	}
+	return tokens, nil
+}
+
+type tokenContextKey struct{}
+
+// requestToken returns the token that authenticated a request, or nil if
+// authentication is disabled.
+func requestToken(req *http.Request) *Token {
+	token, _ := req.Context().Value(tokenContextKey{}).(*Token)
+	return token
+}
+
+func withToken(req *http.Request, token *Token) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), tokenContextKey{}, token))
+}
+
+// requireScope only allows requests authenticated with a token granting the
+// scope.
+func (s *Server) requireScope(scope string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if token := requestToken(req); token != nil && !token.HasScope(scope) {
+			s.logger.Infof("Token %v lacks scope %v for %v.", token.Name, scope, req.URL.Path)
+			errorResp(rw, 403, []string{fmt.Sprintf("Forbidden, token lacks the %q scope.", scope)})
+			return
+		}
+		handler(rw, req)
+	}
+}
+
+// authorizeRoutingKey checks the request's token may be used with the
+// routing key, where an empty routing key means all routing keys, responding
+// with an error if not.
+func (s *Server) authorizeRoutingKey(rw http.ResponseWriter, req *http.Request, routingKey string) bool {
+	token := requestToken(req)
+	if token == nil || token.AllowsRoutingKey(routingKey) {
+		return true
+	}
+
+	s.logger.Infof("Token %v isn't allowed routing key %q for %v.", token.Name, routingKey, req.URL.Path)
+	if routingKey == "" {
+		errorResp(rw, 403, []string{"Forbidden, token is restricted to specific routing keys."})
+	} else {
+		errorResp(rw, 403, []string{fmt.Sprintf("Forbidden, token isn't allowed routing key %v.", routingKey)})
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenScopes(t *testing.T) {
+	send := Token{Name: "send", Scopes: []string{ScopeSend}}
+	admin := Token{Name: "admin", Scopes: []string{ScopeAdmin}}
+
+	assert.True(t, send.HasScope(ScopeSend))
+	assert.False(t, send.HasScope(ScopeReadStatus))
+	assert.False(t, send.HasScope(ScopeAdmin))
+	assert.True(t, admin.HasScope(ScopeSend))
+	assert.True(t, admin.HasScope(ScopeReadStatus))
+
+	assert.Error(t, (&Token{Name: "bad", Token: "x", Scopes: []string{"write"}}).Validate())
+	assert.Error(t, (&Token{Name: "empty"}).Validate())
+}
+
+func TestTokenRoutingKeys(t *testing.T) {
+	unrestricted := Token{}
+	restricted := Token{RoutingKeys: []string{"ABC"}}
+
+	assert.True(t, unrestricted.AllowsRoutingKey(""))
+	assert.True(t, unrestricted.AllowsRoutingKey("xyz"))
+	assert.True(t, restricted.AllowsRoutingKey("abc"))
+	assert.False(t, restricted.AllowsRoutingKey("xyz"))
+	assert.False(t, restricted.AllowsRoutingKey(""))
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	s := Server{secret: "secret", logger: common.Logger}
+	err := s.SetTokens([]Token{
+		{Name: "sender", Token: "send-token", Scopes: []string{ScopeSend}},
+		{Name: "reader", Token: "read-token", Scopes: []string{ScopeReadStatus}, RoutingKeys: []string{"abc"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen *Token
+	handler := authMiddleware(&s)(s.requireScope(ScopeReadStatus, func(rw http.ResponseWriter, req *http.Request) {
+		seen = requestToken(req)
+		if s.authorizeRoutingKey(rw, req, req.URL.Query().Get("rk")) {
+			rw.WriteHeader(200)
+		}
+	}))
+
+	request := func(token, rk string) int {
+		seen = nil
+		req := httptest.NewRequest("GET", "/queue/status?rk="+rk, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "token "+token)
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		return rw.Code
+	}
+
+	assert.Equal(t, 401, request("", "abc"))
+	assert.Equal(t, 401, request("wrong", "abc"))
+	assert.Equal(t, 403, request("send-token", "abc"))
+	assert.Equal(t, 403, request("read-token", "xyz"))
+
+	assert.Equal(t, 200, request("read-token", "abc"))
+	assert.Equal(t, "reader", seen.Name)
+
+	assert.Equal(t, 200, request("secret", "xyz"))
+	assert.Equal(t, secretTokenName, seen.Name)
+
+	// Tokens may be replaced while running.
+	if err := s.SetTokens(nil); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 401, request("read-token", "abc"))
+}
@@ -0,0 +1,16 @@
+// +build !windows
+
+package server
+
+import "syscall"
+
+// withUmask runs fn with the process's umask set to mask, restoring the
+// previous umask afterwards.
+//
+// The umask is shared by the whole process, but is only ever made more
+// restrictive here.
+func withUmask(mask int, fn func()) {
+	old := syscall.Umask(mask)
+	defer syscall.Umask(old)
+	fn()
+}
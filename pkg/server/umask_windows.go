@@ -0,0 +1,6 @@
+package server
+
+// withUmask just runs fn on Windows, which doesn't have a umask.
+func withUmask(mask int, fn func()) {
+	fn()
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/PagerDuty/go-pdagent/pkg/metrics"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/PagerDuty/go-pdagent/pkg/webhook"
+	"github.com/gorilla/mux"
+)
+
+// compileWebhooks compiles each webhook's mappings, keyed by name.
+func compileWebhooks(configs []webhook.Config) (map[string]*webhook.Webhook, error) {
+	webhooks := make(map[string]*webhook.Webhook, len(configs))
+	for _, config := range configs {
+		w, err := webhook.New(config)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := webhooks[w.Name]; ok {
+			return nil, fmt.Errorf("duplicate webhook %v", w.Name)
+		}
+		webhooks[w.Name] = w
+	}
+	return webhooks, nil
+}
+
+// WebhookHandler accepts an arbitrary JSON body at
+// `/integrations/webhook/{name}`, queuing the event produced by the named
+// webhook's mappings and responding with its key.
+func (s *Server) WebhookHandler(rw http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+
+	s.mu.RLock()
+	w := s.webhooks[name]
+	s.mu.RUnlock()
+
+	if w == nil {
+		errorResp(rw, 404, []string{fmt.Sprintf("No webhook named %q is configured.", name)})
+		return
+	}
+
+	body, err := readBody(req)
+	if err != nil {
+		metrics.SendRequests.WithLabelValues("rejected").Inc()
+		writeError(rw, err)
+		return
+	}
+
+	s.logger.Debugf("/integrations/webhook/%v payload: %v", name, string(body))
+
+	event, err := w.Event(body)
+	if err == nil {
+		err = event.Validate()
+	}
+	if err != nil {
+		metrics.SendRequests.WithLabelValues("rejected").Inc()
+		errorResp(rw, 400, []string{err.Error()})
+		return
+	}
+
+	var options []persistentqueue.EventOption
+	if token := requestToken(req); token != nil {
+		if !s.authorizeRoutingKey(rw, req, event.RoutingKey) {
+			metrics.SendRequests.WithLabelValues("rejected").Inc()
+			return
+		}
+		options = append(options, persistentqueue.WithToken(token.Name))
+	}
+
+	key, err := persistentqueue.EnqueueEvent(s.Queue, event, options...)
+	if err != nil {
+		metrics.SendRequests.WithLabelValues("rejected").Inc()
+		errorResp(rw, 500, []string{err.Error()})
+		return
+	}
+	metrics.SendRequests.WithLabelValues("accepted").Inc()
+
+	okResp(rw, SendResponse{Key: key})
+}
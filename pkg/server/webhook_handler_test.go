@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func postWebhook(s *Server, name, token, body string) (int, SendResponse) {
+	req := httptest.NewRequest("POST", "/integrations/webhook/"+name, strings.NewReader(body))
+	req.Header.Set("Authorization", "token "+token)
+	rw := httptest.NewRecorder()
+	s.HTTPServer.Handler.ServeHTTP(rw, req)
+
+	var resp SendResponse
+	_ = json.Unmarshal(rw.Body.Bytes(), &resp)
+	return rw.Code, resp
+}
+
+func TestWebhookHandler(t *testing.T) {
+	s, q := newTestServer("secret")
+	if err := s.Reconfigure(Settings{
+		Secret: "secret",
+		Tokens: []Token{{Name: "ci", Token: "ci-token", Scopes: []string{ScopeSend}, RoutingKeys: []string{testRoutingKey}}},
+		Webhooks: []webhook.Config{{
+			Name:       "ci",
+			RoutingKey: "$.routing_key",
+			Summary:    "Build {{ .build }} failed",
+			DedupKey:   "build-{{ .build }}",
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	code, resp := postWebhook(s, "ci", "ci-token", `{"routing_key": "`+testRoutingKey+`", "build": 42}`)
+	assert.Equal(t, 200, code)
+	assert.NotEmpty(t, resp.Key)
+	assert.Len(t, q.events, 1)
+
+	var event eventsapi.EventV2
+	assert.NoError(t, json.Unmarshal(q.events[0].EventData, &event))
+	assert.Equal(t, "Build 42 failed", event.Payload.Summary)
+	assert.Equal(t, "build-42", event.DedupKey)
+
+	code, _ = postWebhook(s, "ci", "ci-token", `{"routing_key": "00000000000000000000000000000000", "build": 42}`)
+	assert.Equal(t, 403, code)
+
+	code, _ = postWebhook(s, "ci", "ci-token", `{"build": 42}`)
+	assert.Equal(t, 400, code)
+
+	code, _ = postWebhook(s, "unknown", "ci-token", `{}`)
+	assert.Equal(t, 404, code)
+	assert.Len(t, q.events, 1)
+}
+
+func TestReconfigureInvalidWebhook(t *testing.T) {
+	s, _ := newTestServer("secret")
+
+	err := s.Reconfigure(Settings{Webhooks: []webhook.Config{
+		{Name: "ci", RoutingKey: "$.routing_key", Summary: "summary"},
+		{Name: "ci", RoutingKey: "$.routing_key", Summary: "summary"},
+	}})
+	assert.EqualError(t, err, "duplicate webhook ci")
+}
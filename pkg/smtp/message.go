@@ -0,0 +1,122 @@
+// Package smtp receives alert emails over a minimal SMTP listener, queuing
+// events for those sent to configured recipient addresses.
+package smtp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Longest body included in an event, the rest being truncated.
+const maxBodyLength = 16 * 1024
+
+// Email is a parsed email, with its body as text.
+type Email struct {
+	From    string
+	Subject string
+	Date    time.Time
+	Headers map[string]string
+	Body    string
+}
+
+// ParseEmail parses an email, decoding its subject and using its first text
+// part as its body, preferring plain text to HTML.
+func ParseEmail(data []byte) (*Email, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := mime.WordDecoder{}
+	email := Email{Headers: map[string]string{}}
+	for name, values := range msg.Header {
+		value := strings.Join(values, ", ")
+		if decoded, err := decoder.DecodeHeader(value); err == nil {
+			value = decoded
+		}
+		email.Headers[name] = value
+	}
+	email.Subject = strings.TrimSpace(email.Headers["Subject"])
+
+	email.From = email.Headers["From"]
+	if from, err := mail.ParseAddress(email.From); err == nil {
+		email.From = from.Address
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		email.Date = date
+	}
+
+	body, err := textBody(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading email body: %v", err)
+	}
+	body = strings.TrimSpace(strings.Replace(body, "\r\n", "\n", -1))
+	if len(body) > maxBodyLength {
+		body = body[:maxBodyLength]
+	}
+	email.Body = body
+
+	return &email, nil
+}
+
+// textBody returns the text of a body, searching multipart bodies for a
+// text/plain or, failing that, text/html part.
+func textBody(contentType, encoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if contentType == "" || err != nil {
+		mediaType = "text/plain"
+	}
+
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		if !strings.HasPrefix(mediaType, "text/") {
+			return "", nil
+		}
+		b, err := ioutil.ReadAll(decodeTransfer(encoding, body))
+		return string(b), err
+	}
+
+	var html string
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return html, nil
+		} else if err != nil {
+			return "", err
+		}
+
+		partType := part.Header.Get("Content-Type")
+		text, err := textBody(partType, part.Header.Get("Content-Transfer-Encoding"), part)
+		if err != nil {
+			return "", err
+		}
+		if partType == "" || strings.HasPrefix(partType, "text/plain") || strings.HasPrefix(partType, "multipart/") {
+			if text != "" {
+				return text, nil
+			}
+		} else if html == "" && strings.HasPrefix(partType, "text/html") {
+			html = text
+		}
+	}
+}
+
+// decodeTransfer decodes a body's quoted-printable or base64 transfer
+// encoding.
+func decodeTransfer(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	}
+	return body
+}
@@ -0,0 +1,72 @@
+package smtp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func crlf(s string) []byte {
+	return []byte(strings.Replace(s, "\n", "\r\n", -1))
+}
+
+func TestParseEmailPlain(t *testing.T) {
+	email, err := ParseEmail(crlf(`From: UPS Monitor <ups@example.com>
+To: alerts@pdagent.local
+Subject: =?UTF-8?Q?Battery_low_=E2=80=93_UPS_3?=
+Date: Mon, 02 Jan 2006 15:04:05 -0700
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+Alarm ID: 4711
+Battery at 10%=0Aremaining.
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, "ups@example.com", email.From)
+	assert.Equal(t, "Battery low – UPS 3", email.Subject)
+	assert.True(t, email.Date.Equal(time.Date(2006, 1, 2, 22, 4, 5, 0, time.UTC)))
+	assert.Equal(t, "Alarm ID: 4711\nBattery at 10%\nremaining.", email.Body)
+	assert.Equal(t, "alerts@pdagent.local", email.Headers["To"])
+}
+
+func TestParseEmailMultipart(t *testing.T) {
+	email, err := ParseEmail(crlf(`From: nas@example.com
+Subject: Disk failed
+Content-Type: multipart/alternative; boundary="b1"
+
+--b1
+Content-Type: text/html
+
+<p>Disk 3 failed</p>
+--b1
+Content-Type: text/plain
+Content-Transfer-Encoding: base64
+
+RGlzayAzIGZh
+aWxlZA==
+--b1--
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, "Disk 3 failed", email.Body)
+
+	email, err = ParseEmail(crlf(`Subject: HTML only
+Content-Type: multipart/mixed; boundary="b2"
+
+--b2
+Content-Type: text/html
+
+<p>Fan failed</p>
+--b2
+Content-Type: application/octet-stream
+
+binary
+--b2--
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, "<p>Fan failed</p>", email.Body)
+
+	_, err = ParseEmail([]byte("not an email"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,401 @@
+package smtp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/metrics"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"go.uber.org/zap"
+)
+
+// Defaults for the largest email accepted and how many clients may be
+// connected at once, and how long a client may take to send each command.
+const (
+	defaultMaxSize     = 1024 * 1024
+	defaultMaxSessions = 20
+	commandTimeout     = 5 * time.Minute
+)
+
+// Longest command line accepted, well above the 512 bytes SMTP allows.
+const maxLineLength = 4096
+
+var errLineTooLong = errors.New("line too long")
+
+// Most recipients accepted for a single email.
+const maxRecipients = 100
+
+// Config configures the SMTP receiver, listening at `Address`, e.g.
+// `127.0.0.1:2525`. It accepts emails only for the configured recipients,
+// up to `MaxSize` bytes each, from up to `MaxSessions` clients at once.
+// `Hostname` is announced to clients, and defaults to the machine's hostname.
+//
+// The receiver doesn't support TLS or authentication, so should only listen
+// on addresses reachable by trusted senders.
+type Config struct {
+	Address     string            `mapstructure:"address"`
+	Hostname    string            `mapstructure:"hostname"`
+	MaxSize     int               `mapstructure:"max_size"`
+	MaxSessions int               `mapstructure:"max_sessions"`
+	Recipients  []RecipientConfig `mapstructure:"recipients"`
+}
+
+// Receiver accepts emails over SMTP, queuing an event for each recipient.
+type Receiver struct {
+	config     Config
+	recipients map[string]*recipient
+	queue      persistentqueue.Enqueuer
+	logger     *zap.SugaredLogger
+
+	listener net.Listener
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewReceiver compiles the receiver's recipients, returning an error if any
+// are invalid.
+func NewReceiver(config Config, queue persistentqueue.Enqueuer) (*Receiver, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("SMTP receiver needs an address")
+	}
+	if config.MaxSize <= 0 {
+		config.MaxSize = defaultMaxSize
+	}
+	if config.MaxSessions <= 0 {
+		config.MaxSessions = defaultMaxSessions
+	}
+	if config.Hostname == "" {
+		config.Hostname, _ = os.Hostname()
+	}
+
+	r := Receiver{
+		config:     config,
+		recipients: map[string]*recipient{},
+		queue:      queue,
+		logger:     common.Logger.Named("SMTP"),
+		conns:      map[net.Conn]struct{}{},
+	}
+	for _, recipientConfig := range config.Recipients {
+		rcpt, err := newRecipient(recipientConfig)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := r.recipients[rcpt.address]; ok {
+			return nil, fmt.Errorf("duplicate SMTP recipient %v", rcpt.address)
+		}
+		r.recipients[rcpt.address] = rcpt
+	}
+	return &r, nil
+}
+
+// Start listens for connections until `Shutdown`.
+func (r *Receiver) Start() error {
+	listener, err := net.Listen("tcp", r.config.Address)
+	if err != nil {
+		return err
+	}
+	r.listener = listener
+	r.logger.Infof("Listening for SMTP connections on %v", listener.Addr())
+
+	r.wg.Add(1)
+	go r.serve()
+	return nil
+}
+
+// Shutdown stops listening and closes open connections, waiting for any
+// email being handled.
+func (r *Receiver) Shutdown() error {
+	r.mu.Lock()
+	r.closed = true
+	for conn := range r.conns {
+		conn.Close()
+	}
+	r.mu.Unlock()
+
+	if r.listener != nil {
+		r.listener.Close()
+	}
+	r.wg.Wait()
+	return nil
+}
+
+func (r *Receiver) serve() {
+	defer r.wg.Done()
+
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			r.mu.Lock()
+			closed := r.closed
+			r.mu.Unlock()
+			if !closed {
+				r.logger.Errorf("Error accepting SMTP connection: %v", err)
+			}
+			return
+		}
+
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			conn.Close()
+			return
+		}
+		if len(r.conns) >= r.config.MaxSessions {
+			r.mu.Unlock()
+			r.logger.Warnf("Refusing SMTP connection from %v, already serving %v clients.", conn.RemoteAddr(), r.config.MaxSessions)
+			r.refuse(conn)
+			continue
+		}
+		r.conns[conn] = struct{}{}
+		r.wg.Add(1)
+		r.mu.Unlock()
+
+		go r.serveConn(conn)
+	}
+}
+
+// refuse tells a client the receiver is busy and closes its connection,
+// without waiting long for a slow client.
+func (r *Receiver) refuse(conn net.Conn) {
+	_ = conn.SetWriteDeadline(time.Now().Add(time.Second))
+	s := session{conn: conn}
+	s.reply(421, "%v Too many connections, try again later", r.config.Hostname)
+	conn.Close()
+}
+
+// session is the state of a single SMTP connection.
+type session struct {
+	conn       net.Conn
+	reader     *bufio.Reader
+	text       *textproto.Reader
+	from       string
+	recipients []*recipient
+}
+
+func (r *Receiver) serveConn(conn net.Conn) {
+	defer r.wg.Done()
+	defer func() {
+		r.mu.Lock()
+		delete(r.conns, conn)
+		r.mu.Unlock()
+		conn.Close()
+	}()
+
+	// Bounded so a client can't grow a command line without limit, with
+	// emails' data separately limited to `MaxSize`.
+	reader := bufio.NewReaderSize(conn, maxLineLength)
+	s := session{conn: conn, reader: reader, text: textproto.NewReader(reader)}
+	s.reply(220, "%v pdagent ESMTP ready", r.config.Hostname)
+
+	for {
+		_ = conn.SetDeadline(time.Now().Add(commandTimeout))
+		line, err := s.readLine()
+		if err == errLineTooLong {
+			s.reply(500, "Line too long")
+			return
+		} else if err != nil {
+			return
+		}
+
+		verb, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			verb, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			s.reset()
+			s.reply(250, "%v", r.config.Hostname)
+		case "EHLO":
+			s.reset()
+			s.reply(250, "%v\nSIZE %v\n8BITMIME", r.config.Hostname, r.config.MaxSize)
+		case "MAIL":
+			r.mail(&s, arg)
+		case "RCPT":
+			r.rcpt(&s, arg)
+		case "DATA":
+			r.data(&s)
+		case "RSET":
+			s.reset()
+			s.reply(250, "OK")
+		case "NOOP":
+			s.reply(250, "OK")
+		case "VRFY":
+			s.reply(252, "Cannot verify users")
+		case "QUIT":
+			s.reply(221, "Bye")
+			return
+		default:
+			s.reply(502, "Command not implemented")
+		}
+	}
+}
+
+func (r *Receiver) mail(s *session, arg string) {
+	if !strings.HasPrefix(strings.ToUpper(arg), "FROM:") {
+		s.reply(501, "Syntax: MAIL FROM:<address>")
+		return
+	}
+	s.reset()
+	s.from = pathAddress(arg[len("FROM:"):])
+	s.reply(250, "OK")
+}
+
+func (r *Receiver) rcpt(s *session, arg string) {
+	if !strings.HasPrefix(strings.ToUpper(arg), "TO:") {
+		s.reply(501, "Syntax: RCPT TO:<address>")
+		return
+	}
+	if len(s.recipients) >= maxRecipients {
+		s.reply(452, "Too many recipients")
+		return
+	}
+
+	address := strings.ToLower(pathAddress(arg[len("TO:"):]))
+	rcpt, ok := r.recipients[address]
+	if !ok {
+		s.reply(550, "No such recipient")
+		return
+	}
+	s.recipients = append(s.recipients, rcpt)
+	s.reply(250, "OK")
+}
+
+func (r *Receiver) data(s *session) {
+	if len(s.recipients) == 0 {
+		s.reply(503, "Need RCPT before DATA")
+		return
+	}
+	s.reply(354, "End data with <CR><LF>.<CR><LF>")
+
+	dot := s.text.DotReader()
+	data, err := ioutil.ReadAll(io.LimitReader(dot, int64(r.config.MaxSize)+1))
+	if err != nil {
+		return
+	}
+	defer s.reset()
+
+	if len(data) > r.config.MaxSize {
+		// Discard the rest of the message so the connection stays usable.
+		if _, err := io.Copy(ioutil.Discard, dot); err != nil {
+			return
+		}
+		metrics.ReceivedMessages.WithLabelValues("smtp", "invalid").Inc()
+		s.reply(552, "Message exceeds maximum size of %v bytes", r.config.MaxSize)
+		return
+	}
+
+	email, err := ParseEmail(data)
+	if err != nil {
+		metrics.ReceivedMessages.WithLabelValues("smtp", "invalid").Inc()
+		r.logger.Infof("Rejecting invalid email from %v: %v", s.from, err)
+		s.reply(554, "Invalid message: %v", err)
+		return
+	}
+	if email.From == "" {
+		email.From = s.from
+	}
+
+	var keys []string
+	queueFailed := false
+	for _, rcpt := range s.recipients {
+		event, err := rcpt.event(email)
+		if err != nil {
+			metrics.ReceivedMessages.WithLabelValues("smtp", "invalid").Inc()
+			r.logger.Infof("Ignoring email from %v to %v: %v", email.From, rcpt.address, err)
+			continue
+		}
+
+		key, err := r.enqueue(rcpt, event)
+		if err != nil {
+			metrics.ReceivedMessages.WithLabelValues("smtp", "rejected").Inc()
+			r.logger.Errorf("Error queuing event for email to %v: %v", rcpt.address, err)
+			queueFailed = true
+			continue
+		}
+		metrics.ReceivedMessages.WithLabelValues("smtp", "queued").Inc()
+		keys = append(keys, key)
+	}
+
+	// Senders retry temporary failures, so they're only reported if nothing
+	// was queued, to avoid duplicating the events that were.
+	if len(keys) > 0 {
+		s.reply(250, "Queued as %v", strings.Join(keys, ", "))
+	} else if queueFailed {
+		s.reply(451, "Unable to queue event, try again later")
+	} else {
+		s.reply(554, "No event could be created from the message")
+	}
+}
+
+func (r *Receiver) enqueue(rcpt *recipient, event *eventsapi.EventV2) (string, error) {
+	key, err := persistentqueue.EnqueueEvent(r.queue, event)
+	if err != nil {
+		return "", err
+	}
+	r.logger.Debugf("Queued event %v for email to %v.", key, rcpt.address)
+	return key, nil
+}
+
+// readLine reads a command line, returning `errLineTooLong` if it doesn't fit
+// in the session's buffer.
+func (s *session) readLine() (string, error) {
+	line, err := s.reader.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return "", errLineTooLong
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// reset clears the current email's sender and recipients.
+func (s *session) reset() {
+	s.from = ""
+	s.recipients = nil
+}
+
+// reply sends a reply, with each line of a multi-line message prefixed by the
+// code.
+func (s *session) reply(code int, format string, args ...interface{}) {
+	lines := strings.Split(fmt.Sprintf(format, args...), "\n")
+	w := bufio.NewWriter(s.conn)
+	for i, line := range lines {
+		separator := "-"
+		if i == len(lines)-1 {
+			separator = " "
+		}
+		fmt.Fprintf(w, "%d%v%v\r\n", code, separator, line)
+	}
+	_ = w.Flush()
+}
+
+// pathAddress extracts the address from a MAIL or RCPT path, e.g.
+// `<user@example.com> SIZE=123`.
+func pathAddress(path string) string {
+	path = strings.TrimSpace(path)
+	if i := strings.IndexByte(path, '>'); strings.HasPrefix(path, "<") && i > 0 {
+		return path[1:i]
+	}
+	if addr, err := mail.ParseAddress(path); err == nil {
+		return addr.Address
+	}
+	if i := strings.IndexByte(path, ' '); i > 0 {
+		return path[:i]
+	}
+	return path
+}
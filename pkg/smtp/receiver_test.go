@@ -0,0 +1,141 @@
+package smtp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	netsmtp "net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/stretchr/testify/assert"
+)
+
+const testRoutingKey = "11863b592c824bfc8989d9cba76abcde"
+
+type mockQueue struct {
+	mu     sync.Mutex
+	events []eventsapi.EventV2
+}
+
+func (q *mockQueue) Enqueue(eventContainer *eventsapi.EventContainer, _ ...persistentqueue.EventOption) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var event eventsapi.EventV2
+	if err := json.Unmarshal(eventContainer.EventData, &event); err != nil {
+		return "", err
+	}
+	q.events = append(q.events, event)
+	return fmt.Sprint(len(q.events)), nil
+}
+
+func (q *mockQueue) received() []eventsapi.EventV2 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]eventsapi.EventV2(nil), q.events...)
+}
+
+func TestReceiver(t *testing.T) {
+	q := &mockQueue{}
+	r, err := NewReceiver(Config{
+		Address: "127.0.0.1:0",
+		MaxSize: 1024,
+		Recipients: []RecipientConfig{{
+			Address:    "UPS@pdagent.local",
+			RoutingKey: testRoutingKey,
+			Severity:   "critical",
+			Resolve:    `(?i)\b(cleared|resolved)\b`,
+			DedupKey:   `Alarm ID: (\d+)`,
+		}},
+	}, q)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Start())
+	defer r.Shutdown()
+
+	addr := r.listener.Addr().String()
+	send := func(to, subject, body string) error {
+		message := fmt.Sprintf("From: ups@example.com\r\nSubject: %v\r\n\r\n%v\r\n", subject, body)
+		return netsmtp.SendMail(addr, nil, "ups@example.com", []string{to}, []byte(message))
+	}
+
+	assert.NoError(t, send("ups@pdagent.local", "Battery low", "Alarm ID: 4711"))
+	assert.NoError(t, send("ups@pdagent.local", "Alarm cleared", "Alarm ID: 4711"))
+	assert.Error(t, send("unknown@pdagent.local", "Battery low", "Alarm ID: 4711"))
+	assert.Error(t, send("ups@pdagent.local", "Alarm cleared", "No ID"))
+
+	err = send("ups@pdagent.local", "Too big", strings.Repeat("x", 2048))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "552")
+
+	events := q.received()
+	assert.Len(t, events, 2)
+	assert.Equal(t, "trigger", events[0].EventAction)
+	assert.Equal(t, "4711", events[0].DedupKey)
+	assert.Equal(t, "Battery low", events[0].Payload.Summary)
+	assert.Equal(t, "ups@example.com", events[0].Payload.Source)
+	assert.Equal(t, "critical", events[0].Payload.Severity)
+	assert.Equal(t, "resolve", events[1].EventAction)
+	assert.Equal(t, "4711", events[1].DedupKey)
+}
+
+func TestReceiverLimits(t *testing.T) {
+	r, err := NewReceiver(Config{
+		Address:     "127.0.0.1:0",
+		MaxSessions: 1,
+		Recipients:  []RecipientConfig{{Address: "ups@pdagent.local", RoutingKey: testRoutingKey}},
+	}, &mockQueue{})
+	assert.NoError(t, err)
+	assert.NoError(t, r.Start())
+	defer r.Shutdown()
+
+	dial := func() *textproto.Conn {
+		conn, err := net.Dial("tcp", r.listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return textproto.NewConn(conn)
+	}
+
+	first := dial()
+	defer first.Close()
+	_, _, err = first.ReadResponse(220)
+	assert.NoError(t, err)
+
+	// Clients beyond the limit are turned away until a session ends.
+	second := dial()
+	defer second.Close()
+	_, _, err = second.ReadResponse(220)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "421")
+
+	// Overlong command lines end the session.
+	assert.NoError(t, first.PrintfLine("HELO %v", strings.Repeat("x", maxLineLength)))
+	_, _, err = first.ReadResponse(250)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "500")
+	_, err = first.ReadLine()
+	assert.Error(t, err)
+
+	third := dial()
+	defer third.Close()
+	_, _, err = third.ReadResponse(220)
+	assert.NoError(t, err)
+}
+
+func TestNewReceiverInvalid(t *testing.T) {
+	for _, config := range []Config{
+		{},
+		{Address: ":0", Recipients: []RecipientConfig{{RoutingKey: testRoutingKey}}},
+		{Address: ":0", Recipients: []RecipientConfig{{Address: "a@b", RoutingKey: "short"}}},
+		{Address: ":0", Recipients: []RecipientConfig{{Address: "a@b", RoutingKey: testRoutingKey, Resolve: "("}}},
+		{Address: ":0", Recipients: []RecipientConfig{{Address: "a@b", RoutingKey: testRoutingKey}, {Address: "A@b", RoutingKey: testRoutingKey}}},
+	} {
+		_, err := NewReceiver(config, &mockQueue{})
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,146 @@
+package smtp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// RecipientConfig maps emails sent to an address to events for a routing
+// key.
+//
+// Emails matching `Resolve` or `Acknowledge` resolve or acknowledge an
+// incident, and others trigger one. `DedupKey` extracts the dedup key from
+// the email, as its first group or else its whole match. Each regex is
+// matched against the subject, then the body.
+type RecipientConfig struct {
+	Address     string `mapstructure:"address"`
+	RoutingKey  string `mapstructure:"routing_key"`
+	Severity    string `mapstructure:"severity"`
+	Resolve     string `mapstructure:"resolve"`
+	Acknowledge string `mapstructure:"acknowledge"`
+	DedupKey    string `mapstructure:"dedup_key"`
+}
+
+// recipient is a compiled recipient configuration.
+type recipient struct {
+	address     string
+	routingKey  string
+	severity    string
+	resolve     *regexp.Regexp
+	acknowledge *regexp.Regexp
+	dedupKey    *regexp.Regexp
+}
+
+func newRecipient(config RecipientConfig) (*recipient, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("SMTP recipients must have an address")
+	}
+	fail := func(format string, args ...interface{}) (*recipient, error) {
+		return nil, fmt.Errorf("SMTP recipient %v: %v", config.Address, fmt.Sprintf(format, args...))
+	}
+
+	r := recipient{address: strings.ToLower(config.Address), routingKey: config.RoutingKey}
+	if err := (&eventsapi.EventV2{RoutingKey: config.RoutingKey}).Validate(); err != nil {
+		return fail("%v", err)
+	}
+
+	r.severity = strings.ToLower(config.Severity)
+	if r.severity == "" {
+		r.severity = "error"
+	}
+	if err := eventsapi.CheckSeverity(r.severity); err != nil {
+		return fail("%v", err)
+	}
+
+	for _, field := range []struct {
+		name string
+		expr string
+		re   **regexp.Regexp
+	}{
+		{"resolve", config.Resolve, &r.resolve},
+		{"acknowledge", config.Acknowledge, &r.acknowledge},
+		{"dedup_key", config.DedupKey, &r.dedupKey},
+	} {
+		if field.expr == "" {
+			continue
+		}
+		var err error
+		if *field.re, err = regexp.Compile(field.expr); err != nil {
+			return fail("invalid %v regex: %v", field.name, err)
+		}
+	}
+
+	return &r, nil
+}
+
+// event converts an email to an event. Emails that would resolve or
+// acknowledge an incident need a dedup key, so return an error without one.
+func (r *recipient) event(email *Email) (*eventsapi.EventV2, error) {
+	action := "trigger"
+	if r.resolve != nil && matchEmail(r.resolve, email) != nil {
+		action = "resolve"
+	} else if r.acknowledge != nil && matchEmail(r.acknowledge, email) != nil {
+		action = "acknowledge"
+	}
+
+	var dedupKey string
+	if r.dedupKey != nil {
+		if match := matchEmail(r.dedupKey, email); match != nil {
+			dedupKey = match[0]
+			if len(match) > 1 {
+				dedupKey = match[1]
+			}
+		}
+	}
+	if dedupKey == "" && action != "trigger" {
+		return nil, fmt.Errorf("no dedup key found in email to %v", action)
+	}
+
+	summary := email.Subject
+	if summary == "" {
+		summary = strings.SplitN(email.Body, "\n", 2)[0]
+	}
+	if summary == "" {
+		summary = "Email from " + email.From
+	}
+	summary = eventsapi.TruncateSummary(summary)
+
+	source := email.From
+	if source == "" {
+		source = r.address
+	}
+
+	event := eventsapi.EventV2{
+		RoutingKey:  r.routingKey,
+		EventAction: action,
+		DedupKey:    dedupKey,
+		Payload: eventsapi.PayloadV2{
+			Summary:  summary,
+			Source:   source,
+			Severity: r.severity,
+			CustomDetails: map[string]interface{}{
+				"from":    email.From,
+				"to":      r.address,
+				"subject": email.Subject,
+				"body":    email.Body,
+				"headers": email.Headers,
+			},
+		},
+	}
+	if !email.Date.IsZero() {
+		event.Payload.Timestamp = email.Date.Format(time.RFC3339)
+	}
+	return &event, nil
+}
+
+// matchEmail matches a regex against an email's subject, then its body.
+func matchEmail(re *regexp.Regexp, email *Email) []string {
+	if match := re.FindStringSubmatch(email.Subject); match != nil {
+		return match
+	}
+	return re.FindStringSubmatch(email.Body)
+}
@@ -0,0 +1,177 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BER tags used by SNMP, see RFC 1157 and RFC 3416.
+const (
+	tagInteger        = 0x02
+	tagOctetString    = 0x04
+	tagNull           = 0x05
+	tagOID            = 0x06
+	tagSequence       = 0x30
+	tagIPAddress      = 0x40
+	tagCounter32      = 0x41
+	tagGauge32        = 0x42
+	tagTimeTicks      = 0x43
+	tagOpaque         = 0x44
+	tagCounter64      = 0x46
+	tagNoSuchObject   = 0x80
+	tagNoSuchInstance = 0x81
+	tagEndOfMibView   = 0x82
+
+	tagGetResponse = 0xa2
+	tagTrapV1      = 0xa4
+	tagInform      = 0xa6
+	tagTrapV2      = 0xa7
+)
+
+var ErrTruncated = errors.New("truncated BER element")
+
+// element is a single BER-encoded type, length and value.
+type element struct {
+	tag     byte
+	content []byte
+}
+
+// readElement reads an element, returning the data following it.
+func readElement(data []byte) (element, []byte, error) {
+	if len(data) < 2 {
+		return element{}, nil, ErrTruncated
+	}
+	tag := data[0]
+	if tag&0x1f == 0x1f {
+		return element{}, nil, fmt.Errorf("unsupported multi-byte BER tag")
+	}
+
+	length := int(data[1])
+	data = data[2:]
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 4 || len(data) < n {
+			return element{}, nil, fmt.Errorf("unsupported BER length")
+		}
+		length = 0
+		for _, b := range data[:n] {
+			length = length<<8 | int(b)
+		}
+		data = data[n:]
+	}
+	if length < 0 || length > len(data) {
+		return element{}, nil, ErrTruncated
+	}
+	return element{tag: tag, content: data[:length]}, data[length:], nil
+}
+
+// readElements reads the elements of a constructed element, checking their
+// tags if any are given.
+func readElements(data []byte, tags ...byte) ([]element, error) {
+	var elements []element
+	for len(data) > 0 {
+		e, rest, err := readElement(data)
+		if err != nil {
+			return nil, err
+		}
+		if i := len(elements); i < len(tags) && tags[i] != e.tag {
+			return nil, fmt.Errorf("expected BER tag 0x%02x, got 0x%02x", tags[i], e.tag)
+		}
+		elements = append(elements, e)
+		data = rest
+	}
+	if len(elements) < len(tags) {
+		return nil, ErrTruncated
+	}
+	return elements, nil
+}
+
+// integer decodes a signed integer.
+func (e element) integer() (int64, error) {
+	if len(e.content) == 0 || len(e.content) > 8 {
+		return 0, fmt.Errorf("invalid BER integer")
+	}
+	n := int64(int8(e.content[0]))
+	for _, b := range e.content[1:] {
+		n = n<<8 | int64(b)
+	}
+	return n, nil
+}
+
+// unsigned decodes an unsigned integer, as used by counters and time ticks.
+func (e element) unsigned() (uint64, error) {
+	content := e.content
+	if len(content) > 1 && content[0] == 0 {
+		content = content[1:]
+	}
+	if len(content) == 0 || len(content) > 8 {
+		return 0, fmt.Errorf("invalid BER unsigned integer")
+	}
+	var n uint64
+	for _, b := range content {
+		n = n<<8 | uint64(b)
+	}
+	return n, nil
+}
+
+// oid decodes an object identifier in dotted form, e.g. 1.3.6.1.
+func (e element) oid() (string, error) {
+	if len(e.content) == 0 {
+		return "", fmt.Errorf("invalid BER object identifier")
+	}
+
+	var parts []string
+	var n uint64
+	for i, b := range e.content {
+		n = n<<7 | uint64(b&0x7f)
+		if b&0x80 != 0 {
+			if i == len(e.content)-1 {
+				return "", fmt.Errorf("invalid BER object identifier")
+			}
+			continue
+		}
+		if parts == nil {
+			// The first value combines the first two parts.
+			first := n / 40
+			if first > 2 {
+				first = 2
+			}
+			parts = append(parts, strconv.FormatUint(first, 10), strconv.FormatUint(n-first*40, 10))
+		} else {
+			parts = append(parts, strconv.FormatUint(n, 10))
+		}
+		n = 0
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// encode BER-encodes an element.
+func encode(tag byte, content []byte) []byte {
+	var length []byte
+	switch n := len(content); {
+	case n < 0x80:
+		length = []byte{byte(n)}
+	case n <= 0xff:
+		length = []byte{0x81, byte(n)}
+	default:
+		length = []byte{0x82, byte(n >> 8), byte(n)}
+	}
+
+	b := append([]byte{tag}, length...)
+	return append(b, content...)
+}
+
+// encodeInteger BER-encodes a signed integer in as few bytes as possible.
+func encodeInteger(n int64) []byte {
+	var content []byte
+	for {
+		content = append([]byte{byte(n)}, content...)
+		if (n >= -0x80 && n < 0x80) || len(content) == 8 {
+			break
+		}
+		n >>= 8
+	}
+	return encode(tagInteger, content)
+}
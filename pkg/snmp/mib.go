@@ -0,0 +1,89 @@
+package snmp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var validOID = regexp.MustCompile(`^\d+(\.\d+)+$`)
+
+// MIBName names an object identifier, e.g. `linkDown` for
+// 1.3.6.1.6.3.1.1.5.3, as a MIB would.
+type MIBName struct {
+	OID  string `mapstructure:"oid"`
+	Name string `mapstructure:"name"`
+}
+
+// Names of standard traps and the variables commonly sent with them, from
+// SNMPv2-MIB and IF-MIB.
+var standardNames = []MIBName{
+	{"1.3.6.1.6.3.1.1.5.1", "coldStart"},
+	{"1.3.6.1.6.3.1.1.5.2", "warmStart"},
+	{"1.3.6.1.6.3.1.1.5.3", "linkDown"},
+	{"1.3.6.1.6.3.1.1.5.4", "linkUp"},
+	{"1.3.6.1.6.3.1.1.5.5", "authenticationFailure"},
+	{"1.3.6.1.6.3.1.1.5.6", "egpNeighborLoss"},
+	{"1.3.6.1.2.1.1.1", "sysDescr"},
+	{"1.3.6.1.2.1.1.3", "sysUpTime"},
+	{"1.3.6.1.2.1.1.5", "sysName"},
+	{"1.3.6.1.2.1.2.2.1.1", "ifIndex"},
+	{"1.3.6.1.2.1.2.2.1.2", "ifDescr"},
+	{"1.3.6.1.2.1.2.2.1.7", "ifAdminStatus"},
+	{"1.3.6.1.2.1.2.2.1.8", "ifOperStatus"},
+	{"1.3.6.1.2.1.31.1.1.1.1", "ifName"},
+	{"1.3.6.1.2.1.31.1.1.1.18", "ifAlias"},
+	{"1.3.6.1.6.3.1.1.4.3", "snmpTrapEnterprise"},
+}
+
+// mib maps object identifiers to names and back.
+type mib struct {
+	names map[string]string
+	oids  map[string]string
+}
+
+// newMIB combines the standard names with those configured, which take
+// precedence.
+func newMIB(configured []MIBName) (*mib, error) {
+	m := mib{names: map[string]string{}, oids: map[string]string{}}
+	for _, names := range [][]MIBName{standardNames, configured} {
+		for _, n := range names {
+			oid := strings.TrimPrefix(n.OID, ".")
+			if !validOID.MatchString(oid) || n.Name == "" {
+				return nil, fmt.Errorf("invalid MIB name %q for OID %q", n.Name, n.OID)
+			}
+			m.names[oid] = n.Name
+			m.oids[n.Name] = oid
+		}
+	}
+	return &m, nil
+}
+
+// name returns the name of an object identifier, including its unnamed
+// suffix for instances of named objects, e.g. `ifDescr.2`. It returns the OID
+// itself if it has no name.
+func (m *mib) name(oid string) string {
+	for prefix := oid; prefix != ""; {
+		if name, ok := m.names[prefix]; ok {
+			return name + oid[len(prefix):]
+		}
+		i := strings.LastIndexByte(prefix, '.')
+		if i == -1 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	return oid
+}
+
+// resolve returns the object identifier for a name or OID.
+func (m *mib) resolve(nameOrOID string) (string, error) {
+	oid := strings.TrimPrefix(nameOrOID, ".")
+	if validOID.MatchString(oid) {
+		return oid, nil
+	}
+	if oid, ok := m.oids[nameOrOID]; ok {
+		return oid, nil
+	}
+	return "", fmt.Errorf("unknown OID name %q", nameOrOID)
+}
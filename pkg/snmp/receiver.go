@@ -0,0 +1,191 @@
+package snmp
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/metrics"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"go.uber.org/zap"
+)
+
+// Largest datagram accepted.
+const maxMessageSize = 64 * 1024
+
+// Config configures the SNMP trap receiver, listening on UDP at `Address`,
+// e.g. `0.0.0.0:162`.
+//
+// Traps are only accepted with one of `Communities`, if any are given. Each
+// trap is checked against the rules in order, producing an event for the
+// first it matches. `MIB` names OIDs in addition to the standard traps and
+// interface variables.
+type Config struct {
+	Address     string       `mapstructure:"address"`
+	Communities []string     `mapstructure:"communities"`
+	MIB         []MIBName    `mapstructure:"mib"`
+	Rules       []RuleConfig `mapstructure:"rules"`
+}
+
+// Receiver listens for SNMP traps, queuing events for those matching its
+// rules.
+type Receiver struct {
+	config Config
+	mib    *mib
+	rules  []*Rule
+	queue  persistentqueue.Enqueuer
+	logger *zap.SugaredLogger
+
+	conn   net.PacketConn
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewReceiver compiles the receiver's MIB names and rules, returning an
+// error if any are invalid.
+func NewReceiver(config Config, queue persistentqueue.Enqueuer) (*Receiver, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("SNMP receiver needs an address")
+	}
+
+	m, err := newMIB(config.MIB)
+	if err != nil {
+		return nil, err
+	}
+
+	r := Receiver{
+		config: config,
+		mib:    m,
+		queue:  queue,
+		logger: common.Logger.Named("SNMP"),
+	}
+	for _, ruleConfig := range config.Rules {
+		rule, err := newRule(ruleConfig, m)
+		if err != nil {
+			return nil, err
+		}
+		r.rules = append(r.rules, rule)
+	}
+	return &r, nil
+}
+
+// Start listens for traps until `Shutdown`.
+func (r *Receiver) Start() error {
+	conn, err := net.ListenPacket("udp", r.config.Address)
+	if err != nil {
+		return err
+	}
+	r.conn = conn
+	r.logger.Infof("Listening for SNMP traps on udp://%v", conn.LocalAddr())
+
+	r.wg.Add(1)
+	go r.serve()
+	return nil
+}
+
+// Shutdown stops listening, waiting for any trap being handled.
+func (r *Receiver) Shutdown() error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+
+	if r.conn != nil {
+		r.conn.Close()
+	}
+	r.wg.Wait()
+	return nil
+}
+
+func (r *Receiver) serve() {
+	defer r.wg.Done()
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			r.mu.Lock()
+			closed := r.closed
+			r.mu.Unlock()
+			if !closed {
+				r.logger.Errorf("Error reading SNMP trap: %v", err)
+			}
+			return
+		}
+
+		trap, err := r.handle(buf[:n], addr)
+		if err == nil && trap.inform {
+			if _, err := r.conn.WriteTo(trap.response(), addr); err != nil {
+				r.logger.Warnf("Error acknowledging SNMP inform from %v: %v", addr, err)
+			}
+		}
+	}
+}
+
+// handle queues an event for a trap if it matches a rule. Traps that aren't
+// accepted return an error, so informs are only acknowledged once handled.
+func (r *Receiver) handle(data []byte, addr net.Addr) (*Trap, error) {
+	trap, err := ParseTrap(data)
+	if err != nil {
+		metrics.ReceivedMessages.WithLabelValues("snmp", "invalid").Inc()
+		r.logger.Debugf("Ignoring invalid SNMP message from %v: %v", addr, err)
+		return nil, err
+	}
+
+	if !r.allowsCommunity(trap.Community) {
+		metrics.ReceivedMessages.WithLabelValues("snmp", "unauthorized").Inc()
+		r.logger.Infof("Ignoring SNMP trap from %v with an unknown community.", addr)
+		return nil, fmt.Errorf("unknown community")
+	}
+
+	if trap.Agent == "" || trap.Agent == "0.0.0.0" {
+		if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+			trap.Agent = host
+		}
+	}
+
+	for _, rule := range r.rules {
+		if !rule.matches(trap) {
+			continue
+		}
+
+		if err := r.enqueue(rule, trap); err != nil {
+			metrics.ReceivedMessages.WithLabelValues("snmp", "rejected").Inc()
+			r.logger.Errorf("Error queuing event for SNMP rule %v: %v", rule.Name, err)
+			return nil, err
+		}
+		metrics.ReceivedMessages.WithLabelValues("snmp", "queued").Inc()
+		return trap, nil
+	}
+
+	metrics.ReceivedMessages.WithLabelValues("snmp", "unmatched").Inc()
+	r.logger.Debugf("Ignoring SNMP trap %v from %v matching no rule.", r.mib.name(trap.TrapOID), trap.Agent)
+	return trap, nil
+}
+
+func (r *Receiver) allowsCommunity(community string) bool {
+	if len(r.config.Communities) == 0 {
+		return true
+	}
+	for _, c := range r.config.Communities {
+		if c == community {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Receiver) enqueue(rule *Rule, trap *Trap) error {
+	event, err := rule.event(trap, r.mib)
+	if err != nil {
+		return err
+	}
+
+	key, err := persistentqueue.EnqueueEvent(r.queue, event)
+	if err != nil {
+		return err
+	}
+	r.logger.Debugf("Queued event %v for SNMP rule %v.", key, rule.Name)
+	return nil
+}
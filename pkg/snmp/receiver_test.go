@@ -0,0 +1,120 @@
+package snmp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/stretchr/testify/assert"
+)
+
+const testRoutingKey = "11863b592c824bfc8989d9cba76abcde"
+
+type mockQueue struct {
+	mu     sync.Mutex
+	events []eventsapi.EventV2
+}
+
+func (q *mockQueue) Enqueue(eventContainer *eventsapi.EventContainer, _ ...persistentqueue.EventOption) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var event eventsapi.EventV2
+	if err := json.Unmarshal(eventContainer.EventData, &event); err != nil {
+		return "", err
+	}
+	q.events = append(q.events, event)
+	return fmt.Sprint(len(q.events)), nil
+}
+
+func (q *mockQueue) received() []eventsapi.EventV2 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]eventsapi.EventV2(nil), q.events...)
+}
+
+var testConfig = Config{
+	Address:     "127.0.0.1:0",
+	Communities: []string{"public"},
+	Rules: []RuleConfig{
+		{
+			Name:       "link-down",
+			TrapOID:    "linkDown",
+			RoutingKey: testRoutingKey,
+			Summary:    "{{ .Agent }}: {{ .Values.ifDescr }} is down",
+			DedupKey:   "{{ .Agent }}/{{ .Values.ifIndex }}",
+			Severity:   "critical",
+		},
+		{
+			Name:        "link-up",
+			TrapOID:     "1.3.6.1.6.3.1.1.5.4",
+			RoutingKey:  testRoutingKey,
+			EventAction: "resolve",
+			DedupKey:    "{{ .Agent }}/{{ .Values.ifIndex }}",
+		},
+	},
+}
+
+func TestReceiver(t *testing.T) {
+	q := &mockQueue{}
+	r, err := NewReceiver(testConfig, q)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Start())
+	defer r.Shutdown()
+
+	conn, err := net.Dial("udp", r.conn.LocalAddr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	interfaceVarbinds := [][]byte{
+		varbind("1.3.6.1.2.1.2.2.1.1.2", encodeInteger(2)),
+		varbind("1.3.6.1.2.1.2.2.1.2.2", encode(tagOctetString, []byte("eth1"))),
+	}
+	_, _ = conn.Write(trapV2(tagTrapV2, "public", "1.3.6.1.6.3.1.1.5.3", interfaceVarbinds...))
+	_, _ = conn.Write(trapV2(tagTrapV2, "wrong", "1.3.6.1.6.3.1.1.5.3", interfaceVarbinds...))
+	_, _ = conn.Write(trapV2(tagTrapV2, "public", "1.3.6.1.6.3.1.1.5.1"))
+
+	// Informs are acknowledged once queued.
+	_, _ = conn.Write(trapV2(tagInform, "public", "1.3.6.1.6.3.1.1.5.4", interfaceVarbinds...))
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, maxMessageSize)
+	n, err := conn.Read(buf)
+	assert.NoError(t, err)
+	message, _, err := readElement(buf[:n])
+	assert.NoError(t, err)
+	fields, err := readElements(message.content, tagInteger, tagOctetString, tagGetResponse)
+	assert.NoError(t, err)
+	assert.Len(t, fields, 3)
+
+	events := q.received()
+	assert.Len(t, events, 2)
+	assert.Equal(t, "trigger", events[0].EventAction)
+	assert.Equal(t, "127.0.0.1: eth1 is down", events[0].Payload.Summary)
+	assert.Equal(t, "127.0.0.1/2", events[0].DedupKey)
+	assert.Equal(t, "critical", events[0].Payload.Severity)
+	assert.Equal(t, "127.0.0.1", events[0].Payload.Source)
+	assert.Equal(t, "linkDown", events[0].Payload.Class)
+	assert.Equal(t, map[string]interface{}{"ifIndex.2": float64(2), "ifDescr.2": "eth1"}, events[0].Payload.CustomDetails["varbinds"])
+	assert.Equal(t, "resolve", events[1].EventAction)
+	assert.Equal(t, "127.0.0.1/2", events[1].DedupKey)
+	assert.Equal(t, "SNMP trap linkUp from 127.0.0.1", events[1].Payload.Summary)
+}
+
+func TestNewReceiverInvalid(t *testing.T) {
+	for _, config := range []Config{
+		{},
+		{Address: ":0", Rules: []RuleConfig{{RoutingKey: testRoutingKey}}},
+		{Address: ":0", Rules: []RuleConfig{{Name: "key", RoutingKey: "short"}}},
+		{Address: ":0", Rules: []RuleConfig{{Name: "oid", RoutingKey: testRoutingKey, TrapOID: "unknownTrap"}}},
+		{Address: ":0", Rules: []RuleConfig{{Name: "resolve", RoutingKey: testRoutingKey, EventAction: "resolve"}}},
+		{Address: ":0", Rules: []RuleConfig{{Name: "template", RoutingKey: testRoutingKey, Summary: "{{"}}},
+	} {
+		_, err := NewReceiver(config, &mockQueue{})
+		assert.Error(t, err)
+	}
+}
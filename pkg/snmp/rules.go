@@ -0,0 +1,170 @@
+package snmp
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// RuleConfig configures a rule producing an event from traps with a given
+// trap OID, or from any trap if none is given. The trap OID may be given by
+// name, e.g. `linkDown`.
+//
+// The summary and dedup key are Go templates executed against the trap, with
+// its variables available by name in `.Varbinds`, e.g. `ifDescr.2`, and by
+// name without their instance suffix in `.Values`, e.g. `ifDescr`.
+type RuleConfig struct {
+	Name        string `mapstructure:"name"`
+	TrapOID     string `mapstructure:"trap_oid"`
+	RoutingKey  string `mapstructure:"routing_key"`
+	EventAction string `mapstructure:"event_action"`
+	Summary     string `mapstructure:"summary"`
+	DedupKey    string `mapstructure:"dedup_key"`
+	Severity    string `mapstructure:"severity"`
+}
+
+// Rule is a compiled rule.
+type Rule struct {
+	Name string
+
+	trapOID    string
+	routingKey string
+	action     string
+	summary    *template.Template
+	dedupKey   *template.Template
+	severity   string
+}
+
+// newRule compiles a rule, resolving its trap OID's name with the MIB.
+func newRule(config RuleConfig, m *mib) (*Rule, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("SNMP rules must have a name")
+	}
+	fail := func(format string, args ...interface{}) (*Rule, error) {
+		return nil, fmt.Errorf("SNMP rule %v: %v", config.Name, fmt.Sprintf(format, args...))
+	}
+
+	r := Rule{Name: config.Name, routingKey: config.RoutingKey}
+	if err := (&eventsapi.EventV2{RoutingKey: config.RoutingKey}).Validate(); err != nil {
+		return fail("%v", err)
+	}
+
+	if config.TrapOID != "" {
+		oid, err := m.resolve(config.TrapOID)
+		if err != nil {
+			return fail("%v", err)
+		}
+		r.trapOID = oid
+	}
+
+	r.action = strings.ToLower(config.EventAction)
+	switch r.action {
+	case "":
+		r.action = "trigger"
+	case "trigger", "resolve":
+	default:
+		return fail("unknown event_action %q, expected trigger or resolve", config.EventAction)
+	}
+	if r.action == "resolve" && config.DedupKey == "" {
+		return fail("resolving rules need a dedup_key")
+	}
+
+	r.severity = strings.ToLower(config.Severity)
+	if r.severity == "" {
+		r.severity = "error"
+	}
+	if err := eventsapi.CheckSeverity(r.severity); err != nil {
+		return fail("%v", err)
+	}
+
+	summary := config.Summary
+	if summary == "" {
+		summary = "SNMP trap {{ .TrapName }} from {{ .Agent }}"
+	}
+	var err error
+	if r.summary, err = template.New("summary").Option("missingkey=zero").Parse(summary); err != nil {
+		return fail("invalid summary template: %v", err)
+	}
+	if config.DedupKey != "" {
+		if r.dedupKey, err = template.New("dedup_key").Option("missingkey=zero").Parse(config.DedupKey); err != nil {
+			return fail("invalid dedup_key template: %v", err)
+		}
+	}
+
+	return &r, nil
+}
+
+// matches returns true if the rule applies to the trap.
+func (r *Rule) matches(t *Trap) bool {
+	return r.trapOID == "" || r.trapOID == t.TrapOID
+}
+
+// templateData is what rule templates are executed against.
+type templateData struct {
+	Version  string
+	TrapOID  string
+	TrapName string
+	Agent    string
+	Uptime   string
+	Varbinds map[string]interface{}
+	Values   map[string]interface{}
+}
+
+// event builds the rule's event for a trap, naming its OIDs with the MIB.
+func (r *Rule) event(t *Trap, m *mib) (*eventsapi.EventV2, error) {
+	data := templateData{
+		Version:  t.Version,
+		TrapOID:  t.TrapOID,
+		TrapName: m.name(t.TrapOID),
+		Agent:    t.Agent,
+		Uptime:   t.Uptime.String(),
+		Varbinds: map[string]interface{}{},
+		Values:   map[string]interface{}{},
+	}
+	for _, v := range t.Varbinds {
+		name := m.name(v.OID)
+		data.Varbinds[name] = v.Value
+		if name != v.OID {
+			if i := strings.IndexByte(name, '.'); i > 0 {
+				name = name[:i]
+			}
+			data.Values[name] = v.Value
+		}
+	}
+
+	var summary strings.Builder
+	if err := r.summary.Execute(&summary, data); err != nil {
+		return nil, err
+	}
+	var dedupKey strings.Builder
+	if r.dedupKey != nil {
+		if err := r.dedupKey.Execute(&dedupKey, data); err != nil {
+			return nil, err
+		}
+	}
+
+	summaryText := summary.String()
+	summaryText = eventsapi.TruncateSummary(summaryText)
+
+	return &eventsapi.EventV2{
+		RoutingKey:  r.routingKey,
+		EventAction: r.action,
+		DedupKey:    dedupKey.String(),
+		Payload: eventsapi.PayloadV2{
+			Summary:  summaryText,
+			Source:   t.Agent,
+			Severity: r.severity,
+			Class:    data.TrapName,
+			CustomDetails: map[string]interface{}{
+				"version":   t.Version,
+				"trap_oid":  t.TrapOID,
+				"trap_name": data.TrapName,
+				"agent":     t.Agent,
+				"uptime":    data.Uptime,
+				"varbinds":  data.Varbinds,
+			},
+		},
+	}, nil
+}
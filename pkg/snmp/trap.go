@@ -0,0 +1,231 @@
+// Package snmp receives SNMP v1 and v2c traps over UDP, queuing events for
+// those matching configured rules.
+package snmp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Object identifiers of the variables describing v2c traps.
+const (
+	oidSysUpTime   = "1.3.6.1.2.1.1.3.0"
+	oidSnmpTrapOID = "1.3.6.1.6.3.1.1.4.1.0"
+)
+
+// Trap is a decoded SNMP v1 or v2c trap, or v2c inform. V1 traps are
+// converted to their v2c equivalents as described in RFC 3584, so have a
+// trap OID in the same form.
+type Trap struct {
+	Version   string
+	Community string
+	TrapOID   string
+	Agent     string
+	Uptime    time.Duration
+	Varbinds  []Varbind
+
+	// Informs expect a response, acknowledging the request.
+	inform    bool
+	requestID int64
+	varbinds  []byte
+}
+
+// Varbind is a variable sent with a trap, and its value as a string, number
+// or nil.
+type Varbind struct {
+	OID   string
+	Value interface{}
+}
+
+// ParseTrap decodes an SNMP message containing a trap or inform.
+func ParseTrap(data []byte) (*Trap, error) {
+	message, _, err := readElement(data)
+	if err != nil {
+		return nil, err
+	}
+	if message.tag != tagSequence {
+		return nil, fmt.Errorf("not an SNMP message")
+	}
+
+	fields, err := readElements(message.content, tagInteger, tagOctetString)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("not an SNMP message")
+	}
+
+	version, err := fields[0].integer()
+	if err != nil {
+		return nil, err
+	}
+	trap := Trap{Community: string(fields[1].content)}
+	pdu := fields[2]
+
+	switch {
+	case version == 0 && pdu.tag == tagTrapV1:
+		trap.Version = "v1"
+		err = trap.parseV1(pdu.content)
+	case version == 1 && (pdu.tag == tagTrapV2 || pdu.tag == tagInform):
+		trap.Version = "v2c"
+		trap.inform = pdu.tag == tagInform
+		err = trap.parseV2(pdu.content)
+	case version != 0 && version != 1:
+		err = fmt.Errorf("unsupported SNMP version %v", version)
+	default:
+		err = fmt.Errorf("not a trap, PDU type 0x%02x", pdu.tag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &trap, nil
+}
+
+// parseV1 decodes a v1 Trap-PDU.
+func (t *Trap) parseV1(data []byte) error {
+	fields, err := readElements(data, tagOID, tagIPAddress, tagInteger, tagInteger, tagTimeTicks, tagSequence)
+	if err != nil {
+		return err
+	}
+
+	enterprise, err := fields[0].oid()
+	if err != nil {
+		return err
+	}
+	if len(fields[1].content) == 4 {
+		t.Agent = net.IP(fields[1].content).String()
+	}
+	generic, err := fields[2].integer()
+	if err != nil {
+		return err
+	}
+	specific, err := fields[3].integer()
+	if err != nil {
+		return err
+	}
+	ticks, err := fields[4].unsigned()
+	if err != nil {
+		return err
+	}
+	t.Uptime = time.Duration(ticks) * 10 * time.Millisecond
+
+	if generic >= 0 && generic < 6 {
+		t.TrapOID = "1.3.6.1.6.3.1.1.5." + strconv.FormatInt(generic+1, 10)
+	} else {
+		t.TrapOID = enterprise + ".0." + strconv.FormatInt(specific, 10)
+	}
+
+	t.Varbinds, err = parseVarbinds(fields[5].content)
+	return err
+}
+
+// parseV2 decodes a v2c SNMPv2-Trap-PDU or InformRequest-PDU, whose first
+// variables are the uptime and trap OID.
+func (t *Trap) parseV2(data []byte) error {
+	fields, err := readElements(data, tagInteger, tagInteger, tagInteger, tagSequence)
+	if err != nil {
+		return err
+	}
+	if t.requestID, err = fields[0].integer(); err != nil {
+		return err
+	}
+	t.varbinds = fields[3].content
+
+	varbinds, err := parseVarbinds(fields[3].content)
+	if err != nil {
+		return err
+	}
+	for _, v := range varbinds {
+		switch v.OID {
+		case oidSysUpTime:
+			if ticks, ok := v.Value.(uint64); ok {
+				t.Uptime = time.Duration(ticks) * 10 * time.Millisecond
+			}
+		case oidSnmpTrapOID:
+			t.TrapOID, _ = v.Value.(string)
+		default:
+			t.Varbinds = append(t.Varbinds, v)
+		}
+	}
+	if t.TrapOID == "" {
+		return fmt.Errorf("trap has no snmpTrapOID")
+	}
+	return nil
+}
+
+// response acknowledges an inform, with a Response-PDU echoing its request ID
+// and variables.
+func (t *Trap) response() []byte {
+	pdu := encodeInteger(t.requestID)
+	pdu = append(pdu, encodeInteger(0)...)
+	pdu = append(pdu, encodeInteger(0)...)
+	pdu = append(pdu, encode(tagSequence, t.varbinds)...)
+
+	message := encodeInteger(1)
+	message = append(message, encode(tagOctetString, []byte(t.Community))...)
+	message = append(message, encode(tagGetResponse, pdu)...)
+	return encode(tagSequence, message)
+}
+
+// parseVarbinds decodes a sequence of variable bindings.
+func parseVarbinds(data []byte) ([]Varbind, error) {
+	elements, err := readElements(data)
+	if err != nil {
+		return nil, err
+	}
+
+	varbinds := make([]Varbind, 0, len(elements))
+	for _, e := range elements {
+		if e.tag != tagSequence {
+			return nil, fmt.Errorf("invalid variable binding")
+		}
+		fields, err := readElements(e.content, tagOID)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid variable binding")
+		}
+
+		oid, err := fields[0].oid()
+		if err != nil {
+			return nil, err
+		}
+		value, err := fields[1].value()
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %v: %v", oid, err)
+		}
+		varbinds = append(varbinds, Varbind{OID: oid, Value: value})
+	}
+	return varbinds, nil
+}
+
+// value decodes a variable's value. Octet strings that aren't valid UTF-8 are
+// shown in hex.
+func (e element) value() (interface{}, error) {
+	switch e.tag {
+	case tagInteger:
+		return e.integer()
+	case tagOctetString, tagOpaque:
+		if utf8.Valid(e.content) {
+			return string(e.content), nil
+		}
+		return hex.EncodeToString(e.content), nil
+	case tagOID:
+		return e.oid()
+	case tagIPAddress:
+		if len(e.content) != 4 {
+			return nil, fmt.Errorf("invalid IP address")
+		}
+		return net.IP(e.content).String(), nil
+	case tagCounter32, tagGauge32, tagTimeTicks, tagCounter64:
+		return e.unsigned()
+	case tagNull, tagNoSuchObject, tagNoSuchInstance, tagEndOfMibView:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported type 0x%02x", e.tag)
+}
@@ -0,0 +1,136 @@
+package snmp
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// encodeOID BER-encodes an object identifier in dotted form.
+func encodeOID(oid string) []byte {
+	var parts []uint64
+	for _, part := range strings.Split(oid, ".") {
+		n, _ := strconv.ParseUint(part, 10, 64)
+		parts = append(parts, n)
+	}
+
+	content := []byte{byte(parts[0]*40 + parts[1])}
+	for _, n := range parts[2:] {
+		b := []byte{byte(n & 0x7f)}
+		for n >>= 7; n > 0; n >>= 7 {
+			b = append([]byte{byte(n&0x7f) | 0x80}, b...)
+		}
+		content = append(content, b...)
+	}
+	return encode(tagOID, content)
+}
+
+func varbind(oid string, value []byte) []byte {
+	return encode(tagSequence, append(encodeOID(oid), value...))
+}
+
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, part := range parts {
+		b = append(b, part...)
+	}
+	return b
+}
+
+// trapV2 builds a v2c trap or inform with the given trap OID and variables.
+func trapV2(tag byte, community, trapOID string, varbinds ...[]byte) []byte {
+	all := concat(
+		varbind(oidSysUpTime, encode(tagTimeTicks, []byte{0x01, 0x00})),
+		varbind(oidSnmpTrapOID, encodeOID(trapOID)),
+		concat(varbinds...),
+	)
+	pdu := concat(encodeInteger(1234), encodeInteger(0), encodeInteger(0), encode(tagSequence, all))
+	return encode(tagSequence, concat(encodeInteger(1), encode(tagOctetString, []byte(community)), encode(tag, pdu)))
+}
+
+func TestParseTrapV2(t *testing.T) {
+	data := trapV2(tagTrapV2, "public", "1.3.6.1.6.3.1.1.5.3",
+		varbind("1.3.6.1.2.1.2.2.1.1.2", encodeInteger(2)),
+		varbind("1.3.6.1.2.1.2.2.1.2.2", encode(tagOctetString, []byte("eth1"))),
+		varbind("1.3.6.1.2.1.2.2.1.8.2", encodeInteger(2)),
+		varbind("1.3.6.1.4.1.9999.1", encode(tagOctetString, []byte{0xff, 0x00})),
+		varbind("1.3.6.1.4.1.9999.2", encode(tagCounter64, []byte{0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})),
+		varbind("1.3.6.1.4.1.9999.3", encode(tagIPAddress, []byte{10, 0, 0, 1})),
+	)
+
+	trap, err := ParseTrap(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "v2c", trap.Version)
+	assert.Equal(t, "public", trap.Community)
+	assert.Equal(t, "1.3.6.1.6.3.1.1.5.3", trap.TrapOID)
+	assert.Equal(t, 2560*time.Millisecond, trap.Uptime)
+	assert.False(t, trap.inform)
+	assert.Equal(t, []Varbind{
+		{"1.3.6.1.2.1.2.2.1.1.2", int64(2)},
+		{"1.3.6.1.2.1.2.2.1.2.2", "eth1"},
+		{"1.3.6.1.2.1.2.2.1.8.2", int64(2)},
+		{"1.3.6.1.4.1.9999.1", "ff00"},
+		{"1.3.6.1.4.1.9999.2", uint64(1<<64 - 1)},
+		{"1.3.6.1.4.1.9999.3", "10.0.0.1"},
+	}, trap.Varbinds)
+
+	for i := range data {
+		_, err := ParseTrap(data[:i])
+		assert.Error(t, err, i)
+	}
+}
+
+func TestParseTrapV1(t *testing.T) {
+	pdu := concat(
+		encodeOID("1.3.6.1.4.1.9999"),
+		encode(tagIPAddress, []byte{192, 168, 1, 10}),
+		encodeInteger(6),
+		encodeInteger(17),
+		encode(tagTimeTicks, []byte{0x64}),
+		encode(tagSequence, varbind("1.3.6.1.4.1.9999.1.0", encodeInteger(-300))),
+	)
+	data := encode(tagSequence, concat(encodeInteger(0), encode(tagOctetString, []byte("private")), encode(tagTrapV1, pdu)))
+
+	trap, err := ParseTrap(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", trap.Version)
+	assert.Equal(t, "1.3.6.1.4.1.9999.0.17", trap.TrapOID)
+	assert.Equal(t, "192.168.1.10", trap.Agent)
+	assert.Equal(t, time.Second, trap.Uptime)
+	assert.Equal(t, []Varbind{{"1.3.6.1.4.1.9999.1.0", int64(-300)}}, trap.Varbinds)
+}
+
+func TestInformResponse(t *testing.T) {
+	trap, err := ParseTrap(trapV2(tagInform, "public", "1.3.6.1.6.3.1.1.5.4"))
+	assert.NoError(t, err)
+	assert.True(t, trap.inform)
+
+	message, _, err := readElement(trap.response())
+	assert.NoError(t, err)
+	fields, err := readElements(message.content, tagInteger, tagOctetString, tagGetResponse)
+	assert.NoError(t, err)
+	pdu, err := readElements(fields[2].content, tagInteger, tagInteger, tagInteger, tagSequence)
+	assert.NoError(t, err)
+	requestID, _ := pdu[0].integer()
+	assert.Equal(t, int64(1234), requestID)
+}
+
+func TestMIBName(t *testing.T) {
+	m, err := newMIB([]MIBName{{OID: ".1.3.6.1.4.1.9999.0.17", Name: "fanFailure"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "ifDescr.2", m.name("1.3.6.1.2.1.2.2.1.2.2"))
+	assert.Equal(t, "fanFailure", m.name("1.3.6.1.4.1.9999.0.17"))
+	assert.Equal(t, "1.3.6.1.4.1.8888", m.name("1.3.6.1.4.1.8888"))
+
+	oid, err := m.resolve("linkDown")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.3.6.1.6.3.1.1.5.3", oid)
+	_, err = m.resolve("unknown")
+	assert.Error(t, err)
+
+	_, err = newMIB([]MIBName{{OID: "not.an.oid", Name: "bad"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,194 @@
+// Package syslog receives syslog messages over UDP and TCP, queuing events
+// for those matching configured rules.
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidPriority = errors.New("message doesn't start with a valid <PRI>")
+	ErrInvalidHeader   = errors.New("message has an invalid RFC 5424 header")
+)
+
+// Facility names, indexed by code.
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// Severity names, indexed by code.
+var severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// Message is a parsed syslog message in either the RFC 3164 or RFC 5424
+// format. Fields missing from the message are empty.
+type Message struct {
+	Facility       int
+	Severity       int
+	Timestamp      time.Time
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData string
+	Message        string
+}
+
+// FacilityName returns the name of the message's facility, e.g. local0.
+func (m *Message) FacilityName() string {
+	return facilities[m.Facility]
+}
+
+// SeverityName returns the name of the message's severity, e.g. err.
+func (m *Message) SeverityName() string {
+	return severities[m.Severity]
+}
+
+// Parse parses a syslog message, as RFC 5424 if it has a version after its
+// priority and otherwise as RFC 3164. As RFC 3164 messages vary widely in
+// practice, any that lack the expected timestamp are kept whole as the
+// message.
+func Parse(data []byte) (*Message, error) {
+	data = bytes.TrimRight(data, "\r\n\x00")
+
+	m, rest, err := parsePriority(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(rest, "1 ") {
+		return m, parse5424(m, rest[2:])
+	}
+	parse3164(m, rest)
+	return m, nil
+}
+
+// parsePriority parses the leading `<PRI>`, returning the rest of the
+// message.
+func parsePriority(data string) (*Message, string, error) {
+	end := strings.IndexByte(data, '>')
+	if !strings.HasPrefix(data, "<") || end < 2 || end > 4 {
+		return nil, "", ErrInvalidPriority
+	}
+	pri, err := strconv.Atoi(data[1:end])
+	if err != nil || pri < 0 || pri >= len(facilities)*len(severities) {
+		return nil, "", ErrInvalidPriority
+	}
+	return &Message{Facility: pri / 8, Severity: pri % 8}, data[end+1:], nil
+}
+
+// parse5424 parses the header, structured data and message following an RFC
+// 5424 message's version.
+func parse5424(m *Message, rest string) error {
+	fields := strings.SplitN(rest, " ", 6)
+	if len(fields) < 6 {
+		return ErrInvalidHeader
+	}
+
+	if fields[0] != "-" {
+		timestamp, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return fmt.Errorf("%v: %v", ErrInvalidHeader, err)
+		}
+		m.Timestamp = timestamp
+	}
+	m.Hostname = nilValue(fields[1])
+	m.AppName = nilValue(fields[2])
+	m.ProcID = nilValue(fields[3])
+	m.MsgID = nilValue(fields[4])
+
+	sd, msg, err := splitStructuredData(fields[5])
+	if err != nil {
+		return err
+	}
+	m.StructuredData = nilValue(sd)
+	m.Message = strings.TrimPrefix(msg, "\xef\xbb\xbf")
+	return nil
+}
+
+// splitStructuredData splits an RFC 5424 message's structured data, either
+// `-` or a sequence of `[...]` elements, from the message following it.
+func splitStructuredData(rest string) (string, string, error) {
+	if strings.HasPrefix(rest, "-") {
+		return "-", strings.TrimPrefix(rest[1:], " "), nil
+	}
+
+	i := 0
+	for i < len(rest) && rest[i] == '[' {
+		quoted := false
+		for i++; i < len(rest); i++ {
+			if rest[i] == '\\' {
+				i++
+			} else if rest[i] == '"' {
+				quoted = !quoted
+			} else if rest[i] == ']' && !quoted {
+				break
+			}
+		}
+		if i >= len(rest) {
+			return "", "", fmt.Errorf("%v: unterminated structured data", ErrInvalidHeader)
+		}
+		i++
+	}
+	if i == 0 {
+		return "", "", fmt.Errorf("%v: invalid structured data", ErrInvalidHeader)
+	}
+	return rest[:i], strings.TrimPrefix(rest[i:], " "), nil
+}
+
+// parse3164 parses the timestamp, hostname and tag of an RFC 3164 message,
+// e.g. `Jan  2 15:04:05 host app[123]: message`.
+func parse3164(m *Message, rest string) {
+	if len(rest) < len(time.Stamp)+1 {
+		m.Message = rest
+		return
+	}
+	timestamp, err := time.ParseInLocation(time.Stamp, rest[:len(time.Stamp)], time.Local)
+	if err != nil {
+		m.Message = rest
+		return
+	}
+	// Timestamps have no year, so assume the most recent.
+	now := time.Now()
+	timestamp = timestamp.AddDate(now.Year(), 0, 0)
+	if timestamp.After(now.AddDate(0, 0, 1)) {
+		timestamp = timestamp.AddDate(-1, 0, 0)
+	}
+	m.Timestamp = timestamp
+
+	rest = strings.TrimLeft(rest[len(time.Stamp):], " ")
+	if i := strings.IndexByte(rest, ' '); i > 0 {
+		m.Hostname, rest = rest[:i], rest[i+1:]
+	} else {
+		m.Hostname, rest = rest, ""
+	}
+
+	// The tag is the app name, optionally followed by its process ID, and
+	// ends with a colon.
+	if i := strings.IndexAny(rest, ":[ "); i > 0 && rest[i] != ' ' {
+		m.AppName = rest[:i]
+		rest = rest[i:]
+		if strings.HasPrefix(rest, "[") {
+			if end := strings.IndexByte(rest, ']'); end > 0 {
+				m.ProcID = rest[1:end]
+				rest = rest[end+1:]
+			}
+		}
+		rest = strings.TrimPrefix(rest, ":")
+	}
+	m.Message = strings.TrimLeft(rest, " ")
+}
+
+// nilValue returns an empty string for RFC 5424's nil value, `-`.
+func nilValue(field string) string {
+	if field == "-" {
+		return ""
+	}
+	return field
+}
@@ -0,0 +1,62 @@
+package syslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse5424(t *testing.T) {
+	m, err := Parse([]byte(`<165>1 2020-03-01T22:14:15.003Z switch-1.example.com ifmgr 1234 ID47 [exampleSDID@32473 iut="3" eventSource="App]lication"][other@1 a="b"] Interface eth0 down` + "\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "local4", m.FacilityName())
+	assert.Equal(t, "notice", m.SeverityName())
+	assert.Equal(t, time.Date(2020, 3, 1, 22, 14, 15, 3000000, time.UTC), m.Timestamp)
+	assert.Equal(t, "switch-1.example.com", m.Hostname)
+	assert.Equal(t, "ifmgr", m.AppName)
+	assert.Equal(t, "1234", m.ProcID)
+	assert.Equal(t, "ID47", m.MsgID)
+	assert.Equal(t, `[exampleSDID@32473 iut="3" eventSource="App]lication"][other@1 a="b"]`, m.StructuredData)
+	assert.Equal(t, "Interface eth0 down", m.Message)
+
+	m, err = Parse([]byte("<11>1 - - - - - - \xef\xbb\xbfmessage"))
+	assert.NoError(t, err)
+	assert.True(t, m.Timestamp.IsZero())
+	assert.Empty(t, m.Hostname)
+	assert.Empty(t, m.StructuredData)
+	assert.Equal(t, "message", m.Message)
+
+	_, err = Parse([]byte("<11>1 yesterday host app - - - message"))
+	assert.Error(t, err)
+	_, err = Parse([]byte("<11>1 - host app - - [unterminated"))
+	assert.Error(t, err)
+}
+
+func TestParse3164(t *testing.T) {
+	m, err := Parse([]byte("<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8"))
+	assert.NoError(t, err)
+	assert.Equal(t, "auth", m.FacilityName())
+	assert.Equal(t, "crit", m.SeverityName())
+	assert.Equal(t, time.October, m.Timestamp.Month())
+	assert.Equal(t, "mymachine", m.Hostname)
+	assert.Equal(t, "su", m.AppName)
+	assert.Equal(t, "230", m.ProcID)
+	assert.Equal(t, "'su root' failed for lonvick on /dev/pts/8", m.Message)
+
+	m, err = Parse([]byte("<13>Feb  5 17:32:18 10.0.0.99 Use the BFG!"))
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.99", m.Hostname)
+	assert.Empty(t, m.AppName)
+	assert.Equal(t, "Use the BFG!", m.Message)
+
+	m, err = Parse([]byte("<13>no header here"))
+	assert.NoError(t, err)
+	assert.Empty(t, m.Hostname)
+	assert.Equal(t, "no header here", m.Message)
+
+	for _, data := range []string{"no priority", "<>x", "<192>x", "<1234>x"} {
+		_, err := Parse([]byte(data))
+		assert.Equal(t, ErrInvalidPriority, err, data)
+	}
+}
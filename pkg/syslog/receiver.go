@@ -0,0 +1,319 @@
+package syslog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/common"
+	"github.com/PagerDuty/go-pdagent/pkg/metrics"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"go.uber.org/zap"
+)
+
+// Largest message accepted, over either UDP or TCP.
+const maxMessageSize = 64 * 1024
+
+// Most digits in a TCP message's length prefix, enough for `maxMessageSize`.
+const maxLengthDigits = 5
+
+// Defaults for how many TCP clients may be connected at once, and how long a
+// client may take to send each message.
+const (
+	defaultMaxConnections = 100
+	frameTimeout          = 5 * time.Minute
+)
+
+// Config configures the syslog receiver. It listens on whichever of `UDP`
+// and `TCP` are set, e.g. `0.0.0.0:514`, accepting up to `MaxConnections`
+// TCP clients at once.
+//
+// Each message is checked against the rules in order, producing an event for
+// the first it matches. Messages matching no rule are ignored.
+type Config struct {
+	UDP            string       `mapstructure:"udp"`
+	TCP            string       `mapstructure:"tcp"`
+	MaxConnections int          `mapstructure:"max_connections"`
+	Rules          []RuleConfig `mapstructure:"rules"`
+}
+
+// Receiver listens for syslog messages, queuing events for those matching
+// its rules.
+type Receiver struct {
+	config Config
+	rules  []*Rule
+	queue  persistentqueue.Enqueuer
+	logger *zap.SugaredLogger
+
+	udp net.PacketConn
+	tcp net.Listener
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewReceiver compiles the receiver's rules, returning an error if any are
+// invalid.
+func NewReceiver(config Config, queue persistentqueue.Enqueuer) (*Receiver, error) {
+	if config.UDP == "" && config.TCP == "" {
+		return nil, fmt.Errorf("syslog receiver needs a udp or tcp address")
+	}
+	if config.MaxConnections <= 0 {
+		config.MaxConnections = defaultMaxConnections
+	}
+
+	r := Receiver{
+		config: config,
+		queue:  queue,
+		logger: common.Logger.Named("Syslog"),
+		conns:  map[net.Conn]struct{}{},
+	}
+	for _, ruleConfig := range config.Rules {
+		rule, err := NewRule(ruleConfig)
+		if err != nil {
+			return nil, err
+		}
+		r.rules = append(r.rules, rule)
+	}
+	return &r, nil
+}
+
+// Start listens on the configured addresses, receiving messages until
+// `Shutdown`.
+func (r *Receiver) Start() error {
+	if r.config.UDP != "" {
+		udp, err := net.ListenPacket("udp", r.config.UDP)
+		if err != nil {
+			return err
+		}
+		r.udp = udp
+		r.logger.Infof("Listening for syslog messages on udp://%v", udp.LocalAddr())
+
+		r.wg.Add(1)
+		go r.serveUDP()
+	}
+
+	if r.config.TCP != "" {
+		tcp, err := net.Listen("tcp", r.config.TCP)
+		if err != nil {
+			if r.udp != nil {
+				r.udp.Close()
+				r.wg.Wait()
+			}
+			return err
+		}
+		r.tcp = tcp
+		r.logger.Infof("Listening for syslog messages on tcp://%v", tcp.Addr())
+
+		r.wg.Add(1)
+		go r.serveTCP()
+	}
+
+	return nil
+}
+
+// Shutdown stops listening, closing open connections, and waits for
+// messages being handled.
+func (r *Receiver) Shutdown() error {
+	r.mu.Lock()
+	r.closed = true
+	for conn := range r.conns {
+		conn.Close()
+	}
+	r.mu.Unlock()
+
+	if r.udp != nil {
+		r.udp.Close()
+	}
+	if r.tcp != nil {
+		r.tcp.Close()
+	}
+	r.wg.Wait()
+	return nil
+}
+
+func (r *Receiver) serveUDP() {
+	defer r.wg.Done()
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := r.udp.ReadFrom(buf)
+		if err != nil {
+			if !r.isClosed() {
+				r.logger.Errorf("Error reading syslog message: %v", err)
+			}
+			return
+		}
+		r.handle(buf[:n], hostOf(addr))
+	}
+}
+
+func (r *Receiver) serveTCP() {
+	defer r.wg.Done()
+
+	for {
+		conn, err := r.tcp.Accept()
+		if err != nil {
+			if !r.isClosed() {
+				r.logger.Errorf("Error accepting syslog connection: %v", err)
+			}
+			return
+		}
+
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			conn.Close()
+			return
+		}
+		if len(r.conns) >= r.config.MaxConnections {
+			r.mu.Unlock()
+			r.logger.Warnf("Refusing syslog connection from %v, already serving %v clients.", conn.RemoteAddr(), r.config.MaxConnections)
+			conn.Close()
+			continue
+		}
+		r.conns[conn] = struct{}{}
+		r.wg.Add(1)
+		r.mu.Unlock()
+
+		go r.serveConn(conn)
+	}
+}
+
+// serveConn reads messages from a TCP connection, framed either by a length
+// prefix or by newlines as described in RFC 6587.
+func (r *Receiver) serveConn(conn net.Conn) {
+	defer r.wg.Done()
+	defer func() {
+		r.mu.Lock()
+		delete(r.conns, conn)
+		r.mu.Unlock()
+		conn.Close()
+	}()
+
+	addr := hostOf(conn.RemoteAddr())
+	reader := bufio.NewReaderSize(conn, maxMessageSize)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(frameTimeout))
+		message, err := readFrame(reader)
+		if err == io.EOF || (err != nil && r.isClosed()) {
+			return
+		} else if err != nil {
+			r.logger.Warnf("Closing syslog connection from %v: %v", addr, err)
+			return
+		}
+		r.handle(message, addr)
+	}
+}
+
+// readFrame reads a single message from a TCP stream. Messages starting with
+// a digit are prefixed by their length, and others end with a newline.
+func readFrame(reader *bufio.Reader) ([]byte, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] < '0' || first[0] > '9' {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			return nil, fmt.Errorf("message longer than %v bytes", maxMessageSize)
+		} else if err == io.EOF && len(line) > 0 {
+			return line, nil
+		}
+		return line, err
+	}
+
+	// Read a digit at a time, so a prefix without a space can't be read
+	// without limit.
+	length := 0
+	for digits := 0; ; digits++ {
+		c, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if c == ' ' && digits > 0 {
+			break
+		}
+		if c < '0' || c > '9' || digits == maxLengthDigits {
+			return nil, fmt.Errorf("invalid message length prefix")
+		}
+		length = length*10 + int(c-'0')
+	}
+	if length <= 0 || length > maxMessageSize {
+		return nil, fmt.Errorf("invalid message length %v", length)
+	}
+	message := make([]byte, length)
+	_, err = io.ReadFull(reader, message)
+	return message, err
+}
+
+// handle queues an event for a message if it matches a rule.
+func (r *Receiver) handle(data []byte, addr string) {
+	m, err := Parse(data)
+	if err != nil {
+		metrics.ReceivedMessages.WithLabelValues("syslog", "invalid").Inc()
+		r.logger.Debugf("Ignoring invalid syslog message from %v: %v", addr, err)
+		return
+	}
+
+	for _, rule := range r.rules {
+		matched, groups := rule.match(m)
+		if !matched {
+			continue
+		}
+
+		allowed, first := rule.limiter.allow(time.Now())
+		if !allowed {
+			metrics.ReceivedMessages.WithLabelValues("syslog", "rate_limited").Inc()
+			if first {
+				r.logger.Warnf("Syslog rule %v reached its rate limit, dropping further events.", rule.Name)
+			}
+			return
+		}
+
+		if err := r.enqueue(rule, m, groups, addr); err != nil {
+			metrics.ReceivedMessages.WithLabelValues("syslog", "rejected").Inc()
+			r.logger.Errorf("Error queuing event for syslog rule %v: %v", rule.Name, err)
+			return
+		}
+		metrics.ReceivedMessages.WithLabelValues("syslog", "queued").Inc()
+		return
+	}
+
+	metrics.ReceivedMessages.WithLabelValues("syslog", "unmatched").Inc()
+}
+
+func (r *Receiver) enqueue(rule *Rule, m *Message, groups map[string]string, addr string) error {
+	event, err := rule.event(m, groups, addr)
+	if err != nil {
+		return err
+	}
+
+	key, err := persistentqueue.EnqueueEvent(r.queue, event)
+	if err != nil {
+		return err
+	}
+	r.logger.Debugf("Queued event %v for syslog rule %v.", key, rule.Name)
+	return nil
+}
+
+func (r *Receiver) isClosed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
+
+// hostOf returns an address's host, without its port.
+func hostOf(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
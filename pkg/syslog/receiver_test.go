@@ -0,0 +1,191 @@
+package syslog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+	"github.com/PagerDuty/go-pdagent/pkg/persistentqueue"
+	"github.com/stretchr/testify/assert"
+)
+
+const testRoutingKey = "11863b592c824bfc8989d9cba76abcde"
+
+type mockQueue struct {
+	mu     sync.Mutex
+	events []eventsapi.EventV2
+}
+
+func (q *mockQueue) Enqueue(eventContainer *eventsapi.EventContainer, _ ...persistentqueue.EventOption) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var event eventsapi.EventV2
+	if err := json.Unmarshal(eventContainer.EventData, &event); err != nil {
+		return "", err
+	}
+	q.events = append(q.events, event)
+	return fmt.Sprint(len(q.events)), nil
+}
+
+func (q *mockQueue) received() []eventsapi.EventV2 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]eventsapi.EventV2(nil), q.events...)
+}
+
+var testRules = []RuleConfig{
+	{
+		Name: "link-down",
+		Match: MatchConfig{
+			Facilities: []string{"local4"},
+			Hostname:   "^switch-",
+			Message:    `Interface (?P<interface>\S+) down`,
+		},
+		RoutingKey: testRoutingKey,
+		Summary:    "{{ .Hostname }}: {{ .Match.interface }} is down",
+		DedupKey:   "{{ .Hostname }}/{{ .Match.interface }}",
+		RateLimit:  RateLimitConfig{Events: 2, Interval: time.Hour},
+	},
+	{
+		Name:        "link-up",
+		Match:       MatchConfig{AppName: "^ifmgr$", Message: `Interface (?P<interface>\S+) up`},
+		RoutingKey:  testRoutingKey,
+		EventAction: "resolve",
+		DedupKey:    "{{ .Hostname }}/{{ .Match.interface }}",
+	},
+}
+
+func TestReceiverHandle(t *testing.T) {
+	q := &mockQueue{}
+	r, err := NewReceiver(Config{UDP: "127.0.0.1:0", Rules: testRules}, q)
+	assert.NoError(t, err)
+
+	r.handle([]byte("<163>1 - switch-1 ifmgr - - - Interface eth0 down"), "10.0.0.1")
+	r.handle([]byte("<165>1 - switch-1 ifmgr - - - Interface eth0 up"), "10.0.0.1")
+	r.handle([]byte("<165>1 - router-1 ifmgr - - - Interface eth1 down"), "10.0.0.2")
+	r.handle([]byte("<165>1 - switch-1 other - - - Interface eth0 up"), "10.0.0.1")
+	r.handle([]byte("not syslog"), "10.0.0.1")
+
+	events := q.received()
+	assert.Len(t, events, 2)
+	assert.Equal(t, "trigger", events[0].EventAction)
+	assert.Equal(t, "switch-1: eth0 is down", events[0].Payload.Summary)
+	assert.Equal(t, "switch-1/eth0", events[0].DedupKey)
+	assert.Equal(t, "error", events[0].Payload.Severity)
+	assert.Equal(t, "switch-1", events[0].Payload.Source)
+	assert.Equal(t, "link-down", events[0].Payload.Class)
+	assert.Equal(t, "resolve", events[1].EventAction)
+	assert.Equal(t, "switch-1/eth0", events[1].DedupKey)
+	assert.Equal(t, "Interface eth0 up", events[1].Payload.Summary)
+
+	// The link-down rule allows two events an hour, one of which was used.
+	for i := 0; i < 3; i++ {
+		r.handle([]byte("<163>1 - switch-2 ifmgr - - - Interface eth0 down"), "10.0.0.3")
+	}
+	assert.Len(t, q.received(), 3)
+}
+
+func TestReceiverListen(t *testing.T) {
+	q := &mockQueue{}
+	r, err := NewReceiver(Config{UDP: "127.0.0.1:0", TCP: "127.0.0.1:0", Rules: testRules}, q)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Start())
+
+	udp, err := net.Dial("udp", r.udp.LocalAddr().String())
+	assert.NoError(t, err)
+	_, _ = udp.Write([]byte("<163>Oct 11 22:14:15 switch-1 ifmgr: Interface eth0 down"))
+	udp.Close()
+
+	message := "<165>1 - switch-1 ifmgr - - - Interface eth0 up"
+	tcp, err := net.Dial("tcp", r.tcp.Addr().String())
+	assert.NoError(t, err)
+	_, _ = fmt.Fprintf(tcp, "%v %v<163>1 - switch-2 ifmgr - - - Interface eth1 down\n", len(message), message)
+
+	assert.Eventually(t, func() bool { return len(q.received()) == 3 }, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, r.Shutdown())
+	tcp.Close()
+}
+
+func TestReceiverMaxConnections(t *testing.T) {
+	q := &mockQueue{}
+	r, err := NewReceiver(Config{TCP: "127.0.0.1:0", MaxConnections: 1, Rules: testRules}, q)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Start())
+	defer r.Shutdown()
+
+	connected := func(n int) func() bool {
+		return func() bool {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			return len(r.conns) == n
+		}
+	}
+
+	first, err := net.Dial("tcp", r.tcp.Addr().String())
+	assert.NoError(t, err)
+	assert.Eventually(t, connected(1), time.Second, 10*time.Millisecond)
+
+	// Clients beyond the limit are disconnected until another leaves.
+	second, err := net.Dial("tcp", r.tcp.Addr().String())
+	assert.NoError(t, err)
+	defer second.Close()
+	_ = second.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = second.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+
+	first.Close()
+	assert.Eventually(t, connected(0), time.Second, 10*time.Millisecond)
+
+	third, err := net.Dial("tcp", r.tcp.Addr().String())
+	assert.NoError(t, err)
+	defer third.Close()
+	_, _ = fmt.Fprint(third, "<163>1 - switch-2 ifmgr - - - Interface eth1 down\n")
+	assert.Eventually(t, func() bool { return len(q.received()) == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestReadFrame(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("5 hello<14>newline\n"))
+	frame, err := readFrame(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(frame))
+	frame, err = readFrame(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "<14>newline\n", string(frame))
+
+	for _, stream := range []string{
+		"0 ",
+		"99999 ",
+		"12x ",
+		strings.Repeat("1", maxLengthDigits+1) + " <14>too long",
+		strings.Repeat("1", 1024),
+	} {
+		_, err := readFrame(bufio.NewReader(strings.NewReader(stream)))
+		assert.Error(t, err, stream)
+		assert.Contains(t, err.Error(), "invalid message length", stream)
+	}
+}
+
+func TestNewRuleInvalid(t *testing.T) {
+	for _, config := range []RuleConfig{
+		{RoutingKey: testRoutingKey},
+		{Name: "short-key", RoutingKey: "short"},
+		{Name: "facility", RoutingKey: testRoutingKey, Match: MatchConfig{Facilities: []string{"local9"}}},
+		{Name: "regex", RoutingKey: testRoutingKey, Match: MatchConfig{Message: "("}},
+		{Name: "resolve", RoutingKey: testRoutingKey, EventAction: "resolve"},
+		{Name: "severity", RoutingKey: testRoutingKey, Severity: "major"},
+		{Name: "template", RoutingKey: testRoutingKey, Summary: "{{ .Message"},
+		{Name: "rate", RoutingKey: testRoutingKey, RateLimit: RateLimitConfig{Events: 1}},
+	} {
+		_, err := NewRule(config)
+		assert.Error(t, err, config.Name)
+	}
+}
@@ -0,0 +1,323 @@
+package syslog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// RuleConfig configures a rule producing an event from matching messages.
+//
+// The summary and dedup key are Go templates executed against the message's
+// fields, with the message regex's named groups available as `.Match`, e.g.
+// `{{ .Hostname }}: {{ .Match.interface }} is down`. The summary defaults to
+// the message, and severity to one corresponding to the message's severity.
+type RuleConfig struct {
+	Name        string          `mapstructure:"name"`
+	Match       MatchConfig     `mapstructure:"match"`
+	RoutingKey  string          `mapstructure:"routing_key"`
+	EventAction string          `mapstructure:"event_action"`
+	Summary     string          `mapstructure:"summary"`
+	DedupKey    string          `mapstructure:"dedup_key"`
+	Severity    string          `mapstructure:"severity"`
+	RateLimit   RateLimitConfig `mapstructure:"rate_limit"`
+}
+
+// MatchConfig selects the messages a rule applies to. Messages must match
+// every criterion given: facilities and severities are lists of names, e.g.
+// `local0` or `err`, and the rest are regular expressions.
+type MatchConfig struct {
+	Facilities []string `mapstructure:"facilities"`
+	Severities []string `mapstructure:"severities"`
+	Hostname   string   `mapstructure:"hostname"`
+	AppName    string   `mapstructure:"app_name"`
+	Message    string   `mapstructure:"message"`
+}
+
+// RateLimitConfig limits how many events a rule produces, at most `Events`
+// per `Interval`. Zero means unlimited.
+type RateLimitConfig struct {
+	Events   int           `mapstructure:"events"`
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// Rule is a compiled rule.
+type Rule struct {
+	Name string
+
+	facilities map[int]bool
+	severities map[int]bool
+	hostname   *regexp.Regexp
+	appName    *regexp.Regexp
+	message    *regexp.Regexp
+
+	routingKey string
+	action     string
+	summary    *template.Template
+	dedupKey   *template.Template
+	severity   string
+
+	limiter *rateLimiter
+}
+
+// NewRule compiles a rule, returning an error if it's invalid.
+func NewRule(config RuleConfig) (*Rule, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("syslog rules must have a name")
+	}
+	fail := func(format string, args ...interface{}) (*Rule, error) {
+		return nil, fmt.Errorf("syslog rule %v: %v", config.Name, fmt.Sprintf(format, args...))
+	}
+
+	r := Rule{Name: config.Name, routingKey: config.RoutingKey}
+	if err := (&eventsapi.EventV2{RoutingKey: config.RoutingKey}).Validate(); err != nil {
+		return fail("%v", err)
+	}
+
+	var err error
+	if r.facilities, err = codes(facilities, config.Match.Facilities); err != nil {
+		return fail("unknown facility %v", err)
+	}
+	if r.severities, err = codes(severities, config.Match.Severities); err != nil {
+		return fail("unknown severity %v", err)
+	}
+	for _, field := range []struct {
+		name string
+		expr string
+		re   **regexp.Regexp
+	}{
+		{"hostname", config.Match.Hostname, &r.hostname},
+		{"app_name", config.Match.AppName, &r.appName},
+		{"message", config.Match.Message, &r.message},
+	} {
+		if field.expr == "" {
+			continue
+		}
+		if *field.re, err = regexp.Compile(field.expr); err != nil {
+			return fail("invalid %v regex: %v", field.name, err)
+		}
+	}
+
+	r.action = strings.ToLower(config.EventAction)
+	switch r.action {
+	case "":
+		r.action = "trigger"
+	case "trigger", "resolve":
+	default:
+		return fail("unknown event_action %q, expected trigger or resolve", config.EventAction)
+	}
+	if r.action == "resolve" && config.DedupKey == "" {
+		return fail("resolving rules need a dedup_key")
+	}
+
+	r.severity = strings.ToLower(config.Severity)
+	if r.severity != "" {
+		if err := eventsapi.CheckSeverity(r.severity); err != nil {
+			return fail("%v", err)
+		}
+	}
+
+	summary := config.Summary
+	if summary == "" {
+		summary = "{{ .Message }}"
+	}
+	if r.summary, err = template.New("summary").Option("missingkey=zero").Parse(summary); err != nil {
+		return fail("invalid summary template: %v", err)
+	}
+	if config.DedupKey != "" {
+		if r.dedupKey, err = template.New("dedup_key").Option("missingkey=zero").Parse(config.DedupKey); err != nil {
+			return fail("invalid dedup_key template: %v", err)
+		}
+	}
+
+	if config.RateLimit.Events > 0 {
+		if config.RateLimit.Interval <= 0 {
+			return fail("rate_limit needs a positive interval")
+		}
+		r.limiter = &rateLimiter{limit: config.RateLimit.Events, interval: config.RateLimit.Interval}
+	}
+
+	return &r, nil
+}
+
+// match returns true if the message matches the rule, along with the named
+// groups matched by its message regex.
+func (r *Rule) match(m *Message) (bool, map[string]string) {
+	if r.facilities != nil && !r.facilities[m.Facility] {
+		return false, nil
+	}
+	if r.severities != nil && !r.severities[m.Severity] {
+		return false, nil
+	}
+	if r.hostname != nil && !r.hostname.MatchString(m.Hostname) {
+		return false, nil
+	}
+	if r.appName != nil && !r.appName.MatchString(m.AppName) {
+		return false, nil
+	}
+
+	groups := map[string]string{}
+	if r.message != nil {
+		submatches := r.message.FindStringSubmatch(m.Message)
+		if submatches == nil {
+			return false, nil
+		}
+		for i, name := range r.message.SubexpNames() {
+			if name != "" {
+				groups[name] = submatches[i]
+			}
+		}
+	}
+	return true, groups
+}
+
+// templateData is what rule templates are executed against, with the
+// message's facility and severity by name.
+type templateData struct {
+	Facility       string
+	Severity       string
+	Timestamp      time.Time
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData string
+	Message        string
+	Match          map[string]string
+}
+
+// event builds the rule's event for a matching message, received from addr.
+func (r *Rule) event(m *Message, groups map[string]string, addr string) (*eventsapi.EventV2, error) {
+	data := templateData{
+		Facility:       m.FacilityName(),
+		Severity:       m.SeverityName(),
+		Timestamp:      m.Timestamp,
+		Hostname:       m.Hostname,
+		AppName:        m.AppName,
+		ProcID:         m.ProcID,
+		MsgID:          m.MsgID,
+		StructuredData: m.StructuredData,
+		Message:        m.Message,
+		Match:          groups,
+	}
+
+	var summary strings.Builder
+	if err := r.summary.Execute(&summary, data); err != nil {
+		return nil, err
+	}
+	var dedupKey strings.Builder
+	if r.dedupKey != nil {
+		if err := r.dedupKey.Execute(&dedupKey, data); err != nil {
+			return nil, err
+		}
+	}
+
+	summaryText := summary.String()
+	summaryText = eventsapi.TruncateSummary(summaryText)
+
+	source := m.Hostname
+	if source == "" {
+		source = addr
+	}
+	severity := r.severity
+	if severity == "" {
+		severity = eventSeverity(m.Severity)
+	}
+
+	event := eventsapi.EventV2{
+		RoutingKey:  r.routingKey,
+		EventAction: r.action,
+		DedupKey:    dedupKey.String(),
+		Payload: eventsapi.PayloadV2{
+			Summary:   summaryText,
+			Source:    source,
+			Severity:  severity,
+			Component: m.AppName,
+			Class:     r.Name,
+			CustomDetails: map[string]interface{}{
+				"facility":        m.FacilityName(),
+				"severity":        m.SeverityName(),
+				"hostname":        m.Hostname,
+				"app_name":        m.AppName,
+				"proc_id":         m.ProcID,
+				"msg_id":          m.MsgID,
+				"structured_data": m.StructuredData,
+				"message":         m.Message,
+				"matches":         groups,
+				"sender":          addr,
+			},
+		},
+	}
+	if !m.Timestamp.IsZero() {
+		event.Payload.Timestamp = m.Timestamp.Format(time.RFC3339Nano)
+	}
+	return &event, nil
+}
+
+// eventSeverity maps a syslog severity to an event's severity.
+func eventSeverity(severity int) string {
+	switch {
+	case severity <= 2:
+		return "critical"
+	case severity == 3:
+		return "error"
+	case severity == 4:
+		return "warning"
+	}
+	return "info"
+}
+
+// codes returns the codes of the named facilities or severities, or nil if
+// none are named.
+func codes(known []string, names []string) (map[int]bool, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	result := map[int]bool{}
+	for _, name := range names {
+		found := false
+		for code, knownName := range known {
+			if strings.EqualFold(name, knownName) {
+				result[code] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%q", name)
+		}
+	}
+	return result, nil
+}
+
+// rateLimiter allows a number of events per fixed interval.
+type rateLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	interval time.Duration
+	start    time.Time
+	count    int
+}
+
+// allow returns whether another event is allowed, and whether it's the first
+// refused in the current interval.
+func (l *rateLimiter) allow(now time.Time) (bool, bool) {
+	if l == nil {
+		return true, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.start) >= l.interval {
+		l.start = now
+		l.count = 0
+	}
+	l.count++
+	return l.count <= l.limit, l.count == l.limit+1
+}
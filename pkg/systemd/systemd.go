@@ -0,0 +1,128 @@
+// Package systemd implements the parts of systemd's service protocols used by
+// the agent: readiness and status notifications, the watchdog, and socket
+// activation.
+//
+// Each is a no-op when the agent isn't run by systemd with the corresponding
+// feature enabled.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// First file descriptor passed by socket activation, after stdin, stdout and
+// stderr.
+const listenFdsStart = 3
+
+// Notifications understood by systemd, see sd_notify(3).
+const (
+	Ready     = "READY=1"
+	Reloading = "RELOADING=1"
+	Stopping  = "STOPPING=1"
+	Watchdog  = "WATCHDOG=1"
+)
+
+// Status returns a notification describing the service's state, shown by
+// `systemctl status`.
+func Status(format string, args ...interface{}) string {
+	return "STATUS=" + fmt.Sprintf(format, args...)
+}
+
+// Notifying returns true if systemd expects notifications from the process,
+// as with `Type=notify`.
+func Notifying() bool {
+	return os.Getenv("NOTIFY_SOCKET") != ""
+}
+
+// Notify sends notifications to systemd, if it expects them.
+func Notify(states ...string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	// Names starting with "@" are in the abstract namespace, which Go handles.
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(strings.Join(states, "\n")))
+	return err
+}
+
+// WatchdogInterval returns how often systemd expects watchdog notifications,
+// or zero if the watchdog isn't enabled for the process.
+func WatchdogInterval() (time.Duration, error) {
+	if os.Getenv("WATCHDOG_PID") != "" && !forProcess("WATCHDOG_PID") {
+		return 0, nil
+	}
+
+	usec := os.Getenv("WATCHDOG_USEC")
+	if usec == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid WATCHDOG_USEC %q", usec)
+	}
+	return time.Duration(n) * time.Microsecond, nil
+}
+
+// Listeners returns listeners for the sockets passed by socket activation, in
+// the order they're configured, or none if the process wasn't socket
+// activated.
+//
+// The environment variables describing the sockets are cleared, so they
+// aren't inherited by child processes.
+func Listeners() ([]net.Listener, error) {
+	if !forProcess("LISTEN_PID") {
+		return nil, nil
+	}
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", os.Getenv("LISTEN_FDS"))
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	var listeners []net.Listener
+	for i := 0; i < count; i++ {
+		fd := listenFdsStart + i
+
+		name := fmt.Sprintf("LISTEN_FD_%v", fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		// The listener uses a duplicate of the descriptor, so it's closed.
+		f := os.NewFile(uintptr(fd), name)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("socket %v isn't a listener: %v", name, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// forProcess returns true if the environment variable holds this process's
+// ID, as systemd sets to show the other variables are meant for it rather
+// than inherited from a parent.
+func forProcess(env string) bool {
+	pid, err := strconv.Atoi(os.Getenv(env))
+	return err == nil && pid == os.Getpid()
+}
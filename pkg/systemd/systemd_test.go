@@ -0,0 +1,84 @@
+package systemd
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotify(t *testing.T) {
+	os.Unsetenv("NOTIFY_SOCKET")
+	assert.False(t, Notifying())
+	assert.Nil(t, Notify(Ready))
+
+	dir, err := ioutil.TempDir("", "pdagent-systemd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", socket)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	assert.True(t, Notifying())
+	if err := Notify(Ready, Status("%v events pending.", 3)); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 1024)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "READY=1\nSTATUS=3 events pending.", string(buf[:n]))
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	defer os.Unsetenv("WATCHDOG_USEC")
+	defer os.Unsetenv("WATCHDOG_PID")
+
+	interval, err := WatchdogInterval()
+	assert.Nil(t, err)
+	assert.Zero(t, interval)
+
+	os.Setenv("WATCHDOG_USEC", "30000000")
+	interval, err = WatchdogInterval()
+	assert.Nil(t, err)
+	assert.Equal(t, 30*time.Second, interval)
+
+	// Meant for another process.
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	interval, err = WatchdogInterval()
+	assert.Nil(t, err)
+	assert.Zero(t, interval)
+
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("WATCHDOG_USEC", "soon")
+	_, err = WatchdogInterval()
+	assert.Error(t, err)
+}
+
+func TestListenersNotActivated(t *testing.T) {
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	os.Setenv("LISTEN_FDS", "1")
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+
+	listeners, err := Listeners()
+	assert.Nil(t, err)
+	assert.Empty(t, listeners)
+}
@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a parsed JSONPath expression, supporting the subset needed to
+// select a single value: child names, as `.name` or `['name']`, and array
+// indexes, as `[0]`. Each step is either a string name or an int index.
+type jsonPath []interface{}
+
+// parsePath parses a JSONPath expression such as `$.alerts[0]['host name']`.
+func parsePath(expr string) (jsonPath, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("expected %q to start with $", expr)
+	}
+
+	var path jsonPath
+	rest := expr[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end == -1 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return nil, fmt.Errorf("empty name in %q", expr)
+			}
+			path = append(path, name)
+			rest = rest[end+1:]
+
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("unclosed [ in %q", expr)
+			}
+			step := strings.TrimSpace(rest[1:end])
+			if len(step) >= 2 && (step[0] == '\'' || step[0] == '"') && step[len(step)-1] == step[0] {
+				path = append(path, step[1:len(step)-1])
+			} else if index, err := strconv.Atoi(step); err == nil && index >= 0 {
+				path = append(path, index)
+			} else {
+				return nil, fmt.Errorf("unsupported step [%v] in %q, expected a quoted name or an index", step, expr)
+			}
+			rest = rest[end+1:]
+
+		default:
+			return nil, fmt.Errorf("unexpected %q in %q", rest[0], expr)
+		}
+	}
+	return path, nil
+}
+
+// lookup returns the value selected by the path, or nil if there's none.
+func (p jsonPath) lookup(data interface{}) (interface{}, error) {
+	for _, step := range p {
+		switch step := step.(type) {
+		case string:
+			object, ok := data.(map[string]interface{})
+			if !ok {
+				return nil, nil
+			}
+			data = object[step]
+		case int:
+			array, ok := data.([]interface{})
+			if !ok || step >= len(array) {
+				return nil, nil
+			}
+			data = array[step]
+		}
+	}
+	return data, nil
+}
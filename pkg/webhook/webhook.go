@@ -0,0 +1,247 @@
+// Package webhook converts the JSON bodies of arbitrary webhooks into V2
+// events, using mappings configured per webhook.
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/PagerDuty/go-pdagent/pkg/eventsapi"
+)
+
+// Webhook names appear in URLs, so are restricted to these characters.
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Config maps a webhook's JSON body to a V2 event.
+//
+// Each mapping is either a JSONPath expression, starting with `$`, or a Go
+// `text/template` executed against the body. `RoutingKey` and `Summary` are
+// required; `EventAction` defaults to trigger, `Severity` to error, `Source`
+// to the webhook's name, and `CustomDetails` to the whole body.
+type Config struct {
+	Name          string            `mapstructure:"name"`
+	RoutingKey    string            `mapstructure:"routing_key"`
+	EventAction   string            `mapstructure:"event_action"`
+	DedupKey      string            `mapstructure:"dedup_key"`
+	Summary       string            `mapstructure:"summary"`
+	Source        string            `mapstructure:"source"`
+	Severity      string            `mapstructure:"severity"`
+	CustomDetails map[string]string `mapstructure:"custom_details"`
+}
+
+// Webhook converts bodies to events using its configured mappings.
+type Webhook struct {
+	Name string
+
+	routingKey    mapping
+	eventAction   mapping
+	dedupKey      mapping
+	summary       mapping
+	source        mapping
+	severity      mapping
+	customDetails map[string]mapping
+}
+
+// New compiles a webhook's mappings, returning an error if any are invalid.
+func New(config Config) (*Webhook, error) {
+	if !validName.MatchString(config.Name) {
+		return nil, fmt.Errorf("invalid webhook name %q, expected letters, digits, - and _", config.Name)
+	}
+	if config.RoutingKey == "" {
+		return nil, fmt.Errorf("webhook %v has no routing_key mapping", config.Name)
+	}
+	if config.Summary == "" {
+		return nil, fmt.Errorf("webhook %v has no summary mapping", config.Name)
+	}
+
+	w := Webhook{Name: config.Name}
+	fields := []struct {
+		name    string
+		expr    string
+		mapping *mapping
+	}{
+		{"routing_key", config.RoutingKey, &w.routingKey},
+		{"event_action", config.EventAction, &w.eventAction},
+		{"dedup_key", config.DedupKey, &w.dedupKey},
+		{"summary", config.Summary, &w.summary},
+		{"source", config.Source, &w.source},
+		{"severity", config.Severity, &w.severity},
+	}
+	for _, field := range fields {
+		m, err := compileMapping(field.name, field.expr)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %v: %v", config.Name, err)
+		}
+		*field.mapping = m
+	}
+
+	if len(config.CustomDetails) > 0 {
+		w.customDetails = make(map[string]mapping, len(config.CustomDetails))
+		for name, expr := range config.CustomDetails {
+			m, err := compileMapping("custom_details."+name, expr)
+			if err != nil {
+				return nil, fmt.Errorf("webhook %v: %v", config.Name, err)
+			}
+			w.customDetails[name] = m
+		}
+	}
+
+	return &w, nil
+}
+
+// Event converts a webhook's body to a V2 event. The event isn't validated.
+func (w *Webhook) Event(body []byte) (*eventsapi.EventV2, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var data interface{}
+	if err := decoder.Decode(&data); err != nil {
+		return nil, fmt.Errorf("body isn't valid JSON: %v", err)
+	}
+
+	fields := map[string]string{}
+	for name, m := range map[string]mapping{
+		"routing_key":  w.routingKey,
+		"event_action": w.eventAction,
+		"dedup_key":    w.dedupKey,
+		"summary":      w.summary,
+		"source":       w.source,
+		"severity":     w.severity,
+	} {
+		value, err := m.string(data)
+		if err != nil {
+			return nil, fmt.Errorf("error mapping %v: %v", name, err)
+		}
+		fields[name] = value
+	}
+
+	action := strings.ToLower(defaultString(fields["event_action"], "trigger"))
+	switch action {
+	case "trigger", "acknowledge", "resolve":
+	default:
+		return nil, fmt.Errorf("unknown event_action %q, expected trigger, acknowledge or resolve", action)
+	}
+
+	severity := strings.ToLower(defaultString(fields["severity"], "error"))
+	if err := eventsapi.CheckSeverity(severity); err != nil {
+		return nil, err
+	}
+
+	customDetails, err := w.details(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventsapi.EventV2{
+		RoutingKey:  fields["routing_key"],
+		EventAction: action,
+		DedupKey:    fields["dedup_key"],
+		Payload: eventsapi.PayloadV2{
+			Summary:       fields["summary"],
+			Source:        defaultString(fields["source"], w.Name),
+			Severity:      severity,
+			CustomDetails: customDetails,
+		},
+	}, nil
+}
+
+// details returns the event's custom details, defaulting to the whole body.
+func (w *Webhook) details(data interface{}) (map[string]interface{}, error) {
+	if w.customDetails == nil {
+		if object, ok := data.(map[string]interface{}); ok {
+			return object, nil
+		}
+		return map[string]interface{}{"body": data}, nil
+	}
+
+	details := make(map[string]interface{}, len(w.customDetails))
+	for name, m := range w.customDetails {
+		value, err := m(data)
+		if err != nil {
+			return nil, fmt.Errorf("error mapping custom_details.%v: %v", name, err)
+		}
+		details[name] = value
+	}
+	return details, nil
+}
+
+// mapping extracts a value from a webhook's decoded body.
+type mapping func(data interface{}) (interface{}, error)
+
+// compileMapping compiles a JSONPath expression or template. Empty
+// expressions map to nothing.
+func compileMapping(name, expr string) (mapping, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(expr, "$") {
+		path, err := parsePath(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JSONPath for %v: %v", name, err)
+		}
+		return path.lookup, nil
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(funcs).Parse(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template for %v: %v", name, err)
+	}
+	return func(data interface{}) (interface{}, error) {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, err
+		}
+		return b.String(), nil
+	}, nil
+}
+
+// string maps a value as a string, encoding anything other than a string as
+// JSON.
+func (m mapping) string(data interface{}) (string, error) {
+	if m == nil {
+		return "", nil
+	}
+
+	value, err := m(data)
+	if err != nil {
+		return "", err
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	default:
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+}
+
+// Functions available to templates, in addition to Go's builtins.
+var funcs = template.FuncMap{
+	"default": func(fallback string, value interface{}) string {
+		if s := fmt.Sprint(value); value != nil && s != "" {
+			return s
+		}
+		return fallback
+	},
+	"json": func(value interface{}) (string, error) {
+		b, err := json.Marshal(value)
+		return string(b), err
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+func defaultString(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,96 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testBody = `{
+	"title": "Disk full",
+	"state": "ALERTING",
+	"id": 12345678901,
+	"tags": {"team": "11863b592c824bfc8989d9cba76abcde", "host name": "db-1"},
+	"matches": [{"metric": "disk", "value": 99.5}]
+}`
+
+func TestParsePath(t *testing.T) {
+	path, err := parsePath(`$.matches[0]['metric']`)
+	assert.NoError(t, err)
+	assert.Equal(t, jsonPath{"matches", 0, "metric"}, path)
+
+	path, err = parsePath("$")
+	assert.NoError(t, err)
+	assert.Empty(t, path)
+
+	for _, expr := range []string{"$.", "$.matches[", "$.matches[*]", "$x"} {
+		_, err := parsePath(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestWebhookEvent(t *testing.T) {
+	w, err := New(Config{
+		Name:        "grafana",
+		RoutingKey:  "$.tags.team",
+		EventAction: `{{ if eq .state "OK" }}resolve{{ else }}trigger{{ end }}`,
+		DedupKey:    "$.id",
+		Summary:     `{{ .title }} on {{ index .tags "host name" }}`,
+		Severity:    `{{ index . "severity" | default "warning" }}`,
+		CustomDetails: map[string]string{
+			"match": "$.matches[0]",
+			"state": "{{ .state | lower }}",
+		},
+	})
+	assert.NoError(t, err)
+
+	event, err := w.Event([]byte(testBody))
+	assert.NoError(t, err)
+	assert.Equal(t, "11863b592c824bfc8989d9cba76abcde", event.RoutingKey)
+	assert.Equal(t, "trigger", event.EventAction)
+	assert.Equal(t, "12345678901", event.DedupKey)
+	assert.Equal(t, "Disk full on db-1", event.Payload.Summary)
+	assert.Equal(t, "grafana", event.Payload.Source)
+	assert.Equal(t, "warning", event.Payload.Severity)
+	assert.Equal(t, "alerting", event.Payload.CustomDetails["state"])
+
+	details, _ := json.Marshal(event.Payload.CustomDetails["match"])
+	assert.JSONEq(t, `{"metric": "disk", "value": 99.5}`, string(details))
+
+	_, err = w.Event([]byte(`{"title": "Disk full"`))
+	assert.Error(t, err)
+
+	// Templates fail on missing fields, so the event isn't silently wrong.
+	_, err = w.Event([]byte(`{"tags": {}}`))
+	assert.Error(t, err)
+}
+
+func TestWebhookDefaults(t *testing.T) {
+	w, err := New(Config{Name: "minimal", RoutingKey: "$.tags.team", Summary: "$.title"})
+	assert.NoError(t, err)
+
+	event, err := w.Event([]byte(testBody))
+	assert.NoError(t, err)
+	assert.Equal(t, "trigger", event.EventAction)
+	assert.Equal(t, "error", event.Payload.Severity)
+	assert.Equal(t, "minimal", event.Payload.Source)
+	assert.Equal(t, "ALERTING", event.Payload.CustomDetails["state"])
+
+	w, _ = New(Config{Name: "minimal", RoutingKey: "$.tags.team", Summary: "$.title", Severity: "$.state"})
+	_, err = w.Event([]byte(testBody))
+	assert.EqualError(t, err, `unknown severity "alerting", expected critical, error, warning or info`)
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, config := range []Config{
+		{Name: "has space", RoutingKey: "key", Summary: "summary"},
+		{Name: "hook", Summary: "summary"},
+		{Name: "hook", RoutingKey: "key"},
+		{Name: "hook", RoutingKey: "{{ .key", Summary: "summary"},
+		{Name: "hook", RoutingKey: "$.key[", Summary: "summary"},
+	} {
+		_, err := New(config)
+		assert.Error(t, err, config)
+	}
+}